@@ -17,11 +17,14 @@ type Outpoint struct {
 }
 
 // An Output is produced by a transaction. It includes the conditions required
-// to spend the output (called the pubkey script, based on Bitcoin).
+// to spend the output (called the pubkey script, based on Bitcoin). Outputs
+// that cannot be spent, and only embed data (called null-data outputs, based
+// on Bitcoin), also include the embedded data.
 type Output struct {
 	Outpoint     `json:"outpoint"`
 	Value        pack.U256  `json:"value"`
 	PubKeyScript pack.Bytes `json:"pubKeyScript"`
+	Data         pack.Bytes `json:"data,omitempty"`
 }
 
 // An Input specifies an existing output, produced by a previous transaction, to
@@ -35,10 +38,34 @@ type Input struct {
 
 // A Recipient specifies an address, and an amount, for which a transaction will
 // produce an output. Depending on the output, the address can take on different
-// formats (e.g. in Bitcoin, addresses can be P2PK, P2PKH, or P2SH).
+// formats (e.g. in Bitcoin, addresses can be P2PK, P2PKH, or P2SH). Instead of
+// an address, a recipient can specify data, for which a transaction will
+// produce a null-data output that embeds the data (e.g. in Bitcoin, an
+// OP_RETURN output). Null-data outputs cannot be spent, so their amount is
+// usually zero, and the maximum size of the data is chain-specific.
 type Recipient struct {
 	To    address.Address `json:"to"`
 	Value pack.U256       `json:"value"`
+	Data  pack.Bytes      `json:"data,omitempty"`
+}
+
+// A SigHashType specifies the parts of a transaction that are committed to by
+// the sighash of an input. Its values are chain-specific (e.g. in Bitcoin, they
+// are the same as the sighash type byte), and the zero value always selects the
+// default sighash type of the chain.
+type SigHashType uint32
+
+// DefaultSigHashType selects the default sighash type of the chain (e.g. in
+// Bitcoin, SIGHASH_ALL).
+const DefaultSigHashType = SigHashType(0)
+
+// An InputSignature is the signature of the sighash of one input, along with
+// the serialized public key that was used to sign it, and the sighash type that
+// was signed.
+type InputSignature struct {
+	Signature   pack.Bytes65 `json:"signature"`
+	PubKey      pack.Bytes   `json:"pubKey"`
+	SigHashType SigHashType  `json:"sigHashType"`
 }
 
 // The Tx interfaces defines the functionality that must be exposed by
@@ -61,7 +88,9 @@ type Tx interface {
 
 	// Sign the transaction by injecting signatures for the required sighashes.
 	// The serialized public key used to sign the sighashes should also be
-	// specified whenever it is available.
+	// specified whenever it is available. For transactions that are also an
+	// InputSigner, it is a convenience for calling SignInputs when all inputs
+	// are signed by the same public key, using the default sighash type.
 	Sign([]pack.Bytes65, pack.Bytes) error
 
 	// Serialize the transaction into bytes. This is the format in which the
@@ -69,6 +98,22 @@ type Tx interface {
 	Serialize() (pack.Bytes, error)
 }
 
+// The InputSigner interface defines the functionality that is exposed by
+// utxo-based transactions whose inputs can be signed by different keys, and
+// with different sighash types. Not all transactions support it, so callers
+// should type-assert the Tx.
+type InputSigner interface {
+	// SetSigHashType sets the sighash type of the input at the given index,
+	// which determines the parts of the transaction that are committed to by
+	// its sighash. It must be called before computing the sighashes.
+	SetSigHashType(int, SigHashType) error
+
+	// SignInputs signs the transaction by injecting one signature for each of
+	// the required sighashes. Each signature specifies the serialized public
+	// key used to sign it, so that inputs can be owned by different keys.
+	SignInputs([]InputSignature) error
+}
+
 // The TxBuilder interface defines the functionality required to build
 // account-based transactions. Most chain implementations require additional
 // information, and this should be accepted during the construction of the
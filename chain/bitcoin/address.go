@@ -28,7 +28,17 @@ func NewAddressEncoder(params *chaincfg.Params) AddressEncoder {
 	return AddressEncoder{params: params}
 }
 
+// EncodeAddress encodes the raw address. P2TR raw addresses are the witness
+// version followed by the output key, and all other raw addresses are base58
+// decoded addresses.
 func (encoder AddressEncoder) EncodeAddress(rawAddr address.RawAddress) (address.Address, error) {
+	if len(rawAddr) == 33 && rawAddr[0] == TaprootWitnessVersion {
+		addr, err := NewAddressTaproot(rawAddr[1:], encoder.params)
+		if err != nil {
+			return address.Address(""), err
+		}
+		return address.Address(addr.EncodeAddress()), nil
+	}
 	encodedAddr := base58.Encode([]byte(rawAddr))
 	if _, err := btcutil.DecodeAddress(encodedAddr, encoder.params); err != nil {
 		// Check that the address is valid.
@@ -45,10 +55,16 @@ func NewAddressDecoder(params *chaincfg.Params) AddressDecoder {
 	return AddressDecoder{params: params}
 }
 
+// DecodeAddress decodes the address into its raw address (see
+// AddressEncoder.EncodeAddress).
 func (decoder AddressDecoder) DecodeAddress(addr address.Address) (pack.Bytes, error) {
-	if _, err := btcutil.DecodeAddress(string(addr), decoder.params); err != nil {
+	decoded, err := DecodeAddress(string(addr), decoder.params)
+	if err != nil {
 		// Check that the address is valid.
 		return nil, err
 	}
+	if decoded, ok := decoded.(*AddressTaproot); ok {
+		return pack.NewBytes(append([]byte{TaprootWitnessVersion}, decoded.ScriptAddress()...)), nil
+	}
 	return pack.NewBytes(base58.Decode(string(addr))), nil
 }
@@ -0,0 +1,210 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+// An OutputResult is the result of reading one of the outputs in a batch.
+type OutputResult struct {
+	Output        utxo.Output
+	Confirmations pack.U64
+	Err           error
+}
+
+// A ConfirmationsResult is the result of reading the confirmations of one of
+// the transactions in a batch.
+type ConfirmationsResult struct {
+	Confirmations int64
+	Err           error
+}
+
+// batchRequest is one of the requests in a JSON-RPC batch.
+type batchRequest struct {
+	method string
+	params []interface{}
+}
+
+// BatchOutputs returns the output associated with each outpoint, and its
+// number of confirmations, using one batch of requests. An error is only
+// returned if the batch cannot be sent, otherwise the result of each outpoint
+// has its own error.
+func (client *client) BatchOutputs(ctx context.Context, outpoints []utxo.Outpoint) ([]OutputResult, error) {
+	reqs := make([]batchRequest, len(outpoints))
+	resps := make([]btcjson.TxRawResult, len(outpoints))
+	for i, outpoint := range outpoints {
+		hash := chainhash.Hash{}
+		copy(hash[:], outpoint.Hash)
+		reqs[i] = batchRequest{method: "getrawtransaction", params: []interface{}{hash.String(), 1}}
+	}
+	errs, err := client.sendBatch(ctx, reqs, func(i int) interface{} { return &resps[i] })
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]OutputResult, len(outpoints))
+	for i, outpoint := range outpoints {
+		if errs[i] != nil {
+			results[i].Err = fmt.Errorf("bad \"getrawtransaction\": %w", errs[i])
+			continue
+		}
+		results[i].Output, results[i].Confirmations, results[i].Err = outputFromTx(resps[i], outpoint)
+	}
+	return results, nil
+}
+
+// BatchConfirmations returns the confirmations of each transaction, using one
+// batch of requests (and a second batch for transactions whose confirmations
+// must be loaded from their block header). An error is only returned if a
+// batch cannot be sent, otherwise the result of each transaction has its own
+// error. ErrTxNotFound is the error of transactions that are not known by the
+// node.
+func (client *client) BatchConfirmations(ctx context.Context, txHashes []pack.Bytes) ([]ConfirmationsResult, error) {
+	reqs := make([]batchRequest, len(txHashes))
+	resps := make([]btcjson.TxRawResult, len(txHashes))
+	for i, txHash := range txHashes {
+		hash := chainhash.Hash{}
+		copy(hash[:], txHash)
+		reqs[i] = batchRequest{method: "getrawtransaction", params: []interface{}{hash.String(), 1}}
+	}
+	errs, err := client.sendBatch(ctx, reqs, func(i int) interface{} { return &resps[i] })
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the confirmations of transactions that are not returned by the
+	// node from the headers of their blocks.
+	results := make([]ConfirmationsResult, len(txHashes))
+	headerReqs := []batchRequest{}
+	headerIndices := []int{}
+	for i := range txHashes {
+		if errs[i] != nil {
+			var rpcErr *RPCError
+			if errors.As(errs[i], &rpcErr) && rpcErr.Code == btcjson.ErrRPCInvalidAddressOrKey {
+				results[i].Err = ErrTxNotFound
+			} else {
+				results[i].Err = fmt.Errorf("bad \"getrawtransaction\": %w", errs[i])
+			}
+			continue
+		}
+		confs, ok := confirmationsFromTx(resps[i])
+		if ok {
+			results[i].Confirmations = confs
+			continue
+		}
+		headerReqs = append(headerReqs, batchRequest{method: "getblockheader", params: []interface{}{resps[i].BlockHash, true}})
+		headerIndices = append(headerIndices, i)
+	}
+	headers := make([]btcjson.GetBlockHeaderVerboseResult, len(headerReqs))
+	errs, err = client.sendBatch(ctx, headerReqs, func(i int) interface{} { return &headers[i] })
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range headerIndices {
+		if errs[j] != nil {
+			results[i].Err = fmt.Errorf("bad \"getblockheader\": %w", errs[j])
+			continue
+		}
+		results[i].Confirmations = confirmationsFromHeader(headers[j])
+	}
+	return results, nil
+}
+
+// sendBatch sends the requests to the node in one JSON-RPC batch. Responses
+// are correlated with requests by their ID, and the result of each request is
+// decoded into the value returned by the given function for its index. The
+// error of each request is returned, or an error if the batch cannot be sent.
+func (client *client) sendBatch(ctx context.Context, reqs []batchRequest, resp func(int) interface{}) ([]error, error) {
+	if len(reqs) == 0 {
+		return []error{}, nil
+	}
+
+	// Encode the requests, using the index of each request as its ID.
+	rawReqs := make([]json.RawMessage, len(reqs))
+	for i, req := range reqs {
+		rawReq, err := encodeRequestWithID(i, req.method, req.params)
+		if err != nil {
+			return nil, err
+		}
+		rawReqs[i] = rawReq
+	}
+	data, err := json.Marshal(rawReqs)
+	if err != nil {
+		return nil, fmt.Errorf("encoding batch: %v", err)
+	}
+
+	var errs []error
+	err = client.post(ctx, data, func(r io.Reader) (err error) {
+		errs, err = decodeBatchResponse(len(reqs), resp, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// decodeBatchResponse decodes the responses to a batch of the given number of
+// requests. The error of each request is returned, or an error if the batch
+// could not be decoded.
+func decodeBatchResponse(n int, resp func(int) interface{}, r io.Reader) ([]error, error) {
+	raw := json.RawMessage{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decoding response: %v", err)
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		// The node rejected the whole batch with a single response.
+		if err := decodeResponse(&json.RawMessage{}, bytes.NewReader(raw)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("decoding response: expected batch")
+	}
+
+	res := []struct {
+		ID     *int             `json:"id"`
+		Result *json.RawMessage `json:"result"`
+		Error  *json.RawMessage `json:"error"`
+	}{}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("decoding response: %v", err)
+	}
+
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = fmt.Errorf("decoding response: missing response")
+	}
+	for _, item := range res {
+		if item.ID == nil || *item.ID < 0 || *item.ID >= n {
+			continue
+		}
+		i := *item.ID
+		if item.Error != nil {
+			rpcErr := RPCError{}
+			if err := json.Unmarshal(*item.Error, &rpcErr); err == nil && rpcErr.Code != 0 {
+				errs[i] = &rpcErr
+			} else {
+				errs[i] = fmt.Errorf("decoding response: %v", string(*item.Error))
+			}
+			continue
+		}
+		if item.Result == nil {
+			errs[i] = fmt.Errorf("decoding result: result is nil")
+			continue
+		}
+		if err := json.Unmarshal(*item.Result, resp(i)); err != nil {
+			errs[i] = fmt.Errorf("decoding result: %v", err)
+			continue
+		}
+		errs[i] = nil
+	}
+	return errs, nil
+}
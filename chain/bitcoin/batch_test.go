@@ -0,0 +1,169 @@
+package bitcoin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newBatchRPCServer returns a server that responds to batches of requests using
+// the result, or error, returned by the handler for each request. Responses are
+// returned in the reverse order of the requests, and the number of batches
+// that have been received is counted.
+func newBatchRPCServer(batches *int, handler func(method string, params []interface{}) (string, string)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs := []struct {
+			ID     int           `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*batches++
+
+		type response struct {
+			ID     int              `json:"id"`
+			Result *json.RawMessage `json:"result"`
+			Error  *json.RawMessage `json:"error"`
+		}
+		res := make([]response, len(reqs))
+		for i, req := range reqs {
+			result, rpcErr := handler(req.Method, req.Params)
+			res[len(reqs)-1-i].ID = req.ID
+			if rpcErr != "" {
+				raw := json.RawMessage(rpcErr)
+				res[len(reqs)-1-i].Error = &raw
+			} else {
+				raw := json.RawMessage(result)
+				res[len(reqs)-1-i].Result = &raw
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+var _ = Describe("Batch", func() {
+	hashes := []chainhash.Hash{{1}, {2}, {3}, {4}}
+	blockHash := chainhash.Hash{5}
+
+	// handler returns a transaction with one output and two confirmations for
+	// the first hash, a transaction in the mempool for the second hash, a
+	// transaction without confirmations for the third hash, and an error for
+	// all other hashes.
+	handler := func(method string, params []interface{}) (string, string) {
+		switch method {
+		case "getrawtransaction":
+			switch params[0] {
+			case hashes[0].String():
+				return `{"blockhash":"` + blockHash.String() + `","confirmations":2,"vout":[{"value":0.0001,"n":0,"scriptPubKey":{"hex":"51"}}]}`, ""
+			case hashes[1].String():
+				return `{"vout":[]}`, ""
+			case hashes[2].String():
+				return `{"blockhash":"` + blockHash.String() + `","vout":[]}`, ""
+			}
+		case "getblockheader":
+			return `{"hash":"` + blockHash.String() + `","confirmations":7}`, ""
+		}
+		return "", `{"code":-5,"message":"No such mempool or blockchain transaction"}`
+	}
+
+	Context("when reading outputs", func() {
+		It("should return the result of each outpoint using one batch", func() {
+			batches := 0
+			server := newBatchRPCServer(&batches, handler)
+			defer server.Close()
+
+			outpoints := []utxo.Outpoint{
+				{Hash: pack.NewBytes(hashes[0][:]), Index: pack.NewU32(0)},
+				{Hash: pack.NewBytes(hashes[3][:]), Index: pack.NewU32(0)},
+				{Hash: pack.NewBytes(hashes[0][:]), Index: pack.NewU32(1)},
+			}
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+			results, err := client.BatchOutputs(context.Background(), outpoints)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batches).To(Equal(1))
+			Expect(results).To(HaveLen(3))
+
+			Expect(results[0].Err).ToNot(HaveOccurred())
+			Expect(results[0].Output.Outpoint).To(Equal(outpoints[0]))
+			Expect(results[0].Output.Value).To(Equal(pack.NewU256FromU64(pack.NewU64(10000))))
+			Expect(results[0].Output.PubKeyScript).To(Equal(pack.Bytes{0x51}))
+			Expect(results[0].Confirmations).To(Equal(pack.NewU64(2)))
+
+			var rpcErr *bitcoin.RPCError
+			Expect(errors.As(results[1].Err, &rpcErr)).To(BeTrue())
+			Expect(rpcErr.Code).To(BeEquivalentTo(-5))
+
+			Expect(results[2].Err).To(HaveOccurred())
+		})
+
+		It("should not send empty batches", func() {
+			batches := 0
+			server := newBatchRPCServer(&batches, handler)
+			defer server.Close()
+
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+			results, err := client.BatchOutputs(context.Background(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(BeEmpty())
+			Expect(batches).To(Equal(0))
+		})
+	})
+
+	Context("when reading confirmations", func() {
+		It("should return the result of each transaction", func() {
+			batches := 0
+			server := newBatchRPCServer(&batches, handler)
+			defer server.Close()
+
+			txHashes := make([]pack.Bytes, len(hashes))
+			for i := range hashes {
+				txHashes[i] = pack.NewBytes(hashes[i][:])
+			}
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+			results, err := client.BatchConfirmations(context.Background(), txHashes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batches).To(Equal(2))
+			Expect(results).To(Equal([]bitcoin.ConfirmationsResult{
+				{Confirmations: 2},
+				{Confirmations: 0},
+				{Confirmations: 7},
+				{Err: bitcoin.ErrTxNotFound},
+			}))
+		})
+
+		It("should require a quorum of nodes to agree when using a multi-client", func() {
+			batches := []int{0, 0}
+			servers := []*httptest.Server{newBatchRPCServer(&batches[0], handler), newBatchRPCServer(&batches[1], handler)}
+			for _, server := range servers {
+				defer server.Close()
+			}
+
+			multiClient, err := bitcoin.NewMultiClient(
+				bitcoin.DefaultMultiClientOptions().WithQuorum(2),
+				bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(servers[0].URL)),
+				bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(servers[1].URL)),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			var client bitcoin.Client = multiClient
+			results, err := client.BatchConfirmations(context.Background(), []pack.Bytes{pack.NewBytes(hashes[0][:]), pack.NewBytes(hashes[3][:])})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]bitcoin.ConfirmationsResult{
+				{Confirmations: 2},
+				{Err: bitcoin.ErrTxNotFound},
+			}))
+		})
+	})
+})
@@ -0,0 +1,142 @@
+package bitcoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// The checksum constants of bech32 (see BIP173) and bech32m (see BIP350).
+// Segwit v0 addresses use bech32, and all later versions use bech32m.
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// EncodeSegwitAddress returns the segwit address of the given witness version
+// and witness program. Version 0 addresses are encoded using bech32, and all
+// later versions are encoded using bech32m.
+func EncodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := checkWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+	data, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data = append([]byte{version}, data...)
+
+	checksumConst := bech32mConst
+	if version == 0 {
+		checksumConst = bech32Const
+	}
+	hrp = strings.ToLower(hrp)
+	data = append(data, bech32CreateChecksum(hrp, data, checksumConst)...)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, b := range data {
+		sb.WriteByte(bech32Charset[b])
+	}
+	return sb.String(), nil
+}
+
+// DecodeSegwitAddress returns the witness version and witness program of the
+// given segwit address. The address must use the expected human readable part,
+// and must use the checksum required by its witness version.
+func DecodeSegwitAddress(hrp string, addr string) (byte, []byte, error) {
+	if len(addr) < 8 || len(addr) > 90 {
+		return 0, nil, fmt.Errorf("bad address length %v", len(addr))
+	}
+	if strings.ToLower(addr) != addr && strings.ToUpper(addr) != addr {
+		return 0, nil, fmt.Errorf("bad address: mixed case")
+	}
+	addr = strings.ToLower(addr)
+	sep := strings.LastIndexByte(addr, '1')
+	if sep < 1 || sep+7 > len(addr) {
+		return 0, nil, fmt.Errorf("bad address: invalid separator index")
+	}
+	if addr[:sep] != strings.ToLower(hrp) {
+		return 0, nil, fmt.Errorf("bad address: expected hrp %v, got hrp %v", hrp, addr[:sep])
+	}
+	data := make([]byte, 0, len(addr)-sep-1)
+	for i := sep + 1; i < len(addr); i++ {
+		b := strings.IndexByte(bech32Charset, addr[i])
+		if b < 0 {
+			return 0, nil, fmt.Errorf("bad address: invalid character %q", addr[i])
+		}
+		data = append(data, byte(b))
+	}
+
+	version := data[0]
+	checksumConst := bech32mConst
+	if version == 0 {
+		checksumConst = bech32Const
+	}
+	if bech32Polymod(addr[:sep], data) != checksumConst {
+		return 0, nil, fmt.Errorf("bad address: invalid checksum")
+	}
+	program, err := bech32.ConvertBits(data[1:len(data)-6], 5, 8, false)
+	if err != nil {
+		return 0, nil, fmt.Errorf("bad address: %v", err)
+	}
+	if err := checkWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+	return version, program, nil
+}
+
+// checkWitnessProgram returns an error if the witness version and witness
+// program are not valid (see BIP141).
+func checkWitnessProgram(version byte, program []byte) error {
+	if version > 16 {
+		return fmt.Errorf("bad witness version %v", version)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("bad witness program length %v", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("bad witness program length %v for witness version 0", len(program))
+	}
+	return nil
+}
+
+func bech32CreateChecksum(hrp string, data []byte, checksumConst int) []byte {
+	values := make([]byte, len(data)+6)
+	copy(values, data)
+	mod := bech32Polymod(hrp, values) ^ checksumConst
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
+
+func bech32Polymod(hrp string, data []byte) int {
+	generator := [5]int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := 1
+	step := func(v int) {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	for i := 0; i < len(hrp); i++ {
+		step(int(hrp[i] >> 5))
+	}
+	step(0)
+	for i := 0; i < len(hrp); i++ {
+		step(int(hrp[i] & 31))
+	}
+	for _, v := range data {
+		step(int(v))
+	}
+	return chk
+}
@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"time"
@@ -18,12 +19,15 @@ import (
 	"github.com/renproject/multichain/api/address"
 	"github.com/renproject/multichain/api/utxo"
 	"github.com/renproject/pack"
+	"go.uber.org/zap"
 )
 
 const (
 	// DefaultClientTimeout used by the Client.
 	DefaultClientTimeout = time.Minute
 	// DefaultClientTimeoutRetry used by the Client.
+	//
+	// Deprecated: Use DefaultBackoffPolicy instead.
 	DefaultClientTimeoutRetry = time.Second
 	// DefaultClientHost used by the Client. This should only be used for local
 	// deployments of the multichain.
@@ -38,10 +42,22 @@ const (
 	DefaultClientPassword = "password"
 )
 
+// ErrTxNotFound is returned when a transaction is neither in the mempool, nor
+// in the blockchain, of the node. Nodes that are not run with -txindex can
+// only find transactions in the blockchain if they are in the wallet of the
+// node, or if the block that contains them is known.
+var ErrTxNotFound = errors.New("transaction not found")
+
 // ClientOptions are used to parameterise the behaviour of the Client.
+//
+// TimeoutRetry is deprecated, and RetryPolicy should be used instead. When it
+// is non-zero, transient errors are retried every TimeoutRetry until the
+// context is done, and RetryPolicy is ignored.
 type ClientOptions struct {
 	Timeout      time.Duration
 	TimeoutRetry time.Duration
+	RetryPolicy  RetryPolicy
+	Logger       *zap.Logger
 	Host         string
 	User         string
 	Password     string
@@ -52,11 +68,12 @@ type ClientOptions struct {
 // multichain. In production, the host, user, and password should be changed.
 func DefaultClientOptions() ClientOptions {
 	return ClientOptions{
-		Timeout:      DefaultClientTimeout,
-		TimeoutRetry: DefaultClientTimeoutRetry,
-		Host:         DefaultClientHost,
-		User:         DefaultClientUser,
-		Password:     DefaultClientPassword,
+		Timeout:     DefaultClientTimeout,
+		RetryPolicy: DefaultBackoffPolicy(),
+		Logger:      zap.NewNop(),
+		Host:        DefaultClientHost,
+		User:        DefaultClientUser,
+		Password:    DefaultClientPassword,
 	}
 }
 
@@ -80,14 +97,53 @@ func (opts ClientOptions) WithPassword(password string) ClientOptions {
 	return opts
 }
 
+// WithRetryPolicy sets the policy that decides whether or not failed requests
+// to the Bitcoin node are retried.
+func (opts ClientOptions) WithRetryPolicy(policy RetryPolicy) ClientOptions {
+	opts.RetryPolicy = policy
+	return opts
+}
+
+// WithLogger sets the logger that is used to log retried requests.
+func (opts ClientOptions) WithLogger(logger *zap.Logger) ClientOptions {
+	opts.Logger = logger
+	return opts
+}
+
 // A Client interacts with an instance of the Bitcoin network using the RPC
 // interface exposed by a Bitcoin node.
 type Client interface {
 	utxo.Client
 	// UnspentOutputs spendable by the given address.
 	UnspentOutputs(ctx context.Context, minConf, maxConf int64, address address.Address) ([]utxo.Output, error)
-	// Confirmations of a transaction in the Bitcoin network.
+	// Confirmations of a transaction in the Bitcoin network. Transactions in
+	// the mempool have zero confirmations, and ErrTxNotFound is returned for
+	// transactions that are not known by the node.
 	Confirmations(ctx context.Context, txHash pack.Bytes) (int64, error)
+	// ConfirmationsInBlock of a transaction in the given block. Unlike
+	// Confirmations, it can find any transaction in the blockchain, even if
+	// the node is not run with -txindex.
+	ConfirmationsInBlock(ctx context.Context, txHash, blockHash pack.Bytes) (int64, error)
+	// BatchOutputs returns the output associated with each outpoint, and its
+	// number of confirmations, using one batch of requests. An error is only
+	// returned if the batch cannot be sent, otherwise the result of each
+	// outpoint has its own error.
+	BatchOutputs(ctx context.Context, outpoints []utxo.Outpoint) ([]OutputResult, error)
+	// BatchConfirmations returns the confirmations of each transaction, using
+	// one batch of requests (and a second batch for transactions whose
+	// confirmations must be loaded from their block header). An error is only
+	// returned if a batch cannot be sent, otherwise the result of each
+	// transaction has its own error.
+	BatchConfirmations(ctx context.Context, txHashes []pack.Bytes) ([]ConfirmationsResult, error)
+	// EstimateSmartFee returns the fee rate that is needed in order for a
+	// transaction to confirm within the given number of blocks.
+	EstimateSmartFee(ctx context.Context, confTarget int64, mode EstimateMode) (EstimateSmartFeeResult, error)
+	// RawTransaction returns the verbose representation of a transaction in
+	// the Bitcoin network.
+	RawTransaction(ctx context.Context, txHash pack.Bytes) (btcjson.TxRawResult, error)
+	// BlockCount returns the height of the most recent block in the longest
+	// chain known by the node.
+	BlockCount(ctx context.Context) (int64, error)
 }
 
 type client struct {
@@ -99,6 +155,21 @@ type client struct {
 func NewClient(opts ClientOptions) Client {
 	httpClient := http.Client{}
 	httpClient.Timeout = opts.Timeout
+	if opts.TimeoutRetry > 0 {
+		opts.RetryPolicy = BackoffPolicy{
+			MaxAttempts:    0,
+			InitialBackoff: opts.TimeoutRetry,
+			MaxBackoff:     opts.TimeoutRetry,
+			Multiplier:     1,
+			Jitter:         0,
+		}
+	}
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = DefaultBackoffPolicy()
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
 	return &client{
 		opts:       opts,
 		httpClient: httpClient,
@@ -111,8 +182,14 @@ func (client *client) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.
 	hash := chainhash.Hash{}
 	copy(hash[:], outpoint.Hash)
 	if err := client.send(ctx, &resp, "getrawtransaction", hash.String(), 1); err != nil {
-		return utxo.Output{}, pack.NewU64(0), fmt.Errorf("bad \"gettxout\": %v", err)
+		return utxo.Output{}, pack.NewU64(0), fmt.Errorf("bad \"gettxout\": %w", err)
 	}
+	return outputFromTx(resp, outpoint)
+}
+
+// outputFromTx returns the output at the given outpoint of the verbose
+// representation of a transaction, and its number of confirmations.
+func outputFromTx(resp btcjson.TxRawResult, outpoint utxo.Outpoint) (utxo.Output, pack.U64, error) {
 	if outpoint.Index.Uint32() >= uint32(len(resp.Vout)) {
 		return utxo.Output{}, pack.NewU64(0), fmt.Errorf("bad index: %v is out of range", outpoint.Index)
 	}
@@ -136,7 +213,10 @@ func (client *client) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.
 	return output, pack.NewU64(resp.Confirmations), nil
 }
 
-// SubmitTx to the Bitcoin network.
+// SubmitTx to the Bitcoin network. Submitting a transaction that is already in
+// the mempool, or in the blockchain, succeeds. Errors returned by the node can
+// be matched against ErrMissingInputs, ErrInsufficientFee, and
+// ErrMempoolConflict using errors.Is.
 func (client *client) SubmitTx(ctx context.Context, tx utxo.Tx) error {
 	serial, err := tx.Serialize()
 	if err != nil {
@@ -144,7 +224,10 @@ func (client *client) SubmitTx(ctx context.Context, tx utxo.Tx) error {
 	}
 	resp := ""
 	if err := client.send(ctx, &resp, "sendrawtransaction", hex.EncodeToString(serial)); err != nil {
-		return fmt.Errorf("bad \"sendrawtransaction\": %v", err)
+		if errors.Is(err, ErrTxAlreadyInChain) || errors.Is(err, ErrTxAlreadyInMempool) {
+			return nil
+		}
+		return fmt.Errorf("bad \"sendrawtransaction\": %w", err)
 	}
 	return nil
 }
@@ -153,7 +236,7 @@ func (client *client) SubmitTx(ctx context.Context, tx utxo.Tx) error {
 func (client *client) UnspentOutputs(ctx context.Context, minConf, maxConf int64, addr address.Address) ([]utxo.Output, error) {
 	resp := []btcjson.ListUnspentResult{}
 	if err := client.send(ctx, &resp, "listunspent", minConf, maxConf, []string{string(addr)}); err != nil && err != io.EOF {
-		return []utxo.Output{}, fmt.Errorf("bad \"listunspent\": %v", err)
+		return []utxo.Output{}, fmt.Errorf("bad \"listunspent\": %w", err)
 	}
 	outputs := make([]utxo.Output, len(resp))
 	for i := range outputs {
@@ -184,25 +267,107 @@ func (client *client) UnspentOutputs(ctx context.Context, minConf, maxConf int64
 	return outputs, nil
 }
 
-// Confirmations of a transaction in the Bitcoin network.
+// Confirmations of a transaction in the Bitcoin network. Transactions in the
+// mempool have zero confirmations, and ErrTxNotFound is returned for
+// transactions that are not known by the node.
 func (client *client) Confirmations(ctx context.Context, txHash pack.Bytes) (int64, error) {
-	resp := btcjson.GetTransactionResult{}
+	hash := chainhash.Hash{}
+	copy(hash[:], txHash)
+	return client.confirmations(ctx, hash.String(), 1)
+}
+
+// ConfirmationsInBlock of a transaction in the given block. Unlike
+// Confirmations, it can find any transaction in the blockchain, even if the
+// node is not run with -txindex.
+func (client *client) ConfirmationsInBlock(ctx context.Context, txHash, blockHash pack.Bytes) (int64, error) {
+	hash := chainhash.Hash{}
+	copy(hash[:], txHash)
+	block := chainhash.Hash{}
+	copy(block[:], blockHash)
+	return client.confirmations(ctx, hash.String(), 1, block.String())
+}
+
+// confirmations of the transaction returned by "getrawtransaction" with the
+// given params. If the node does not return the number of confirmations, they
+// are loaded from the header of the block that contains the transaction.
+func (client *client) confirmations(ctx context.Context, params ...interface{}) (int64, error) {
+	resp := btcjson.TxRawResult{}
+	if err := client.send(ctx, &resp, "getrawtransaction", params...); err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCInvalidAddressOrKey {
+			return 0, ErrTxNotFound
+		}
+		return 0, fmt.Errorf("bad \"getrawtransaction\": %w", err)
+	}
+	if confs, ok := confirmationsFromTx(resp); ok {
+		return confs, nil
+	}
+
+	header := btcjson.GetBlockHeaderVerboseResult{}
+	if err := client.send(ctx, &header, "getblockheader", resp.BlockHash, true); err != nil {
+		return 0, fmt.Errorf("bad \"getblockheader\": %w", err)
+	}
+	return confirmationsFromHeader(header), nil
+}
 
-	size := len(txHash)
-	txHashReversed := make([]byte, size)
-	copy(txHashReversed[:], txHash[:])
-	for i := 0; i < size/2; i++ {
-		txHashReversed[i], txHashReversed[size-1-i] = txHashReversed[size-1-i], txHashReversed[i]
+// confirmationsFromTx returns the confirmations of the verbose representation
+// of a transaction, and true, or false if they must be loaded from the header
+// of the block that contains the transaction.
+func confirmationsFromTx(resp btcjson.TxRawResult) (int64, bool) {
+	if resp.BlockHash == "" {
+		// The transaction is in the mempool.
+		return 0, true
 	}
+	if resp.Confirmations > 0 {
+		return int64(resp.Confirmations), true
+	}
+	return 0, false
+}
+
+// confirmationsFromHeader returns the confirmations of a block header.
+func confirmationsFromHeader(header btcjson.GetBlockHeaderVerboseResult) int64 {
+	if header.Confirmations < 0 {
+		// The block is not in the main chain.
+		return 0
+	}
+	return header.Confirmations
+}
+
+// RawTransaction returns the verbose representation of a transaction in the
+// Bitcoin network.
+func (client *client) RawTransaction(ctx context.Context, txHash pack.Bytes) (btcjson.TxRawResult, error) {
+	resp := btcjson.TxRawResult{}
+	hash := chainhash.Hash{}
+	copy(hash[:], txHash)
+	if err := client.send(ctx, &resp, "getrawtransaction", hash.String(), 1); err != nil {
+		return btcjson.TxRawResult{}, fmt.Errorf("bad \"getrawtransaction\": %w", err)
+	}
+	return resp, nil
+}
+
+// BlockCount returns the height of the most recent block in the longest chain
+// known by the node.
+func (client *client) BlockCount(ctx context.Context) (int64, error) {
+	resp := int64(0)
+	if err := client.send(ctx, &resp, "getblockcount"); err != nil {
+		return 0, fmt.Errorf("bad \"getblockcount\": %w", err)
+	}
+	return resp, nil
+}
 
-	if err := client.send(ctx, &resp, "gettransaction", hex.EncodeToString(txHashReversed)); err != nil {
-		return 0, fmt.Errorf("bad \"gettransaction\": %v", err)
+// EstimateSmartFee returns the fee rate that is needed in order for a
+// transaction to confirm within the given number of blocks. If the mode is
+// unset, the node will use its default mode.
+func (client *client) EstimateSmartFee(ctx context.Context, confTarget int64, mode EstimateMode) (EstimateSmartFeeResult, error) {
+	resp := EstimateSmartFeeResult{}
+	params := []interface{}{confTarget}
+	if mode != EstimateModeUnset {
+		params = append(params, mode)
 	}
-	confirmations := resp.Confirmations
-	if confirmations < 0 {
-		confirmations = 0
+	if err := client.send(ctx, &resp, "estimatesmartfee", params...); err != nil {
+		return EstimateSmartFeeResult{}, fmt.Errorf("bad \"estimatesmartfee\": %w", err)
 	}
-	return confirmations, nil
+	return resp, nil
 }
 
 func (client *client) send(ctx context.Context, resp interface{}, method string, params ...interface{}) error {
@@ -212,24 +377,43 @@ func (client *client) send(ctx context.Context, resp interface{}, method string,
 		return err
 	}
 
-	return retry(ctx, client.opts.TimeoutRetry, func() error {
+	return client.post(ctx, data, func(r io.Reader) error {
+		return decodeResponse(resp, r)
+	})
+}
+
+// post the encoded request to the node, and decode the response. The request is
+// retried according to the retry policy of the client.
+func (client *client) post(ctx context.Context, data []byte, decode func(io.Reader) error) error {
+	return retry(ctx, client.opts.RetryPolicy, client.opts.Logger, func() error {
 		// Create request and add basic authentication headers. The context is
-		// not attached to the request, and instead we all each attempt to run
-		// for the timeout duration, and we keep attempting until success, or
-		// the context is done.
-		req, err := http.NewRequest("POST", client.opts.Host, bytes.NewBuffer(data))
+		// attached to the request, so it is cancelled as soon as the context
+		// is done, and each attempt runs for at most the timeout duration.
+		req, err := http.NewRequestWithContext(ctx, "POST", client.opts.Host, bytes.NewBuffer(data))
 		if err != nil {
 			return fmt.Errorf("building http request: %v", err)
 		}
 		req.SetBasicAuth(client.opts.User, client.opts.Password)
 
-		// Send the request and decode the response.
+		// Send the request and decode the response. Errors are wrapped, so
+		// that the retry policy can tell whether or not they are transient.
 		res, err := client.httpClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("sending http request: %v", err)
+			return fmt.Errorf("sending http request: %w", err)
 		}
 		defer res.Body.Close()
-		if err := decodeResponse(resp, res.Body); err != nil {
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("reading http response: %w", err)
+		}
+		if err := decode(bytes.NewReader(body)); err != nil {
+			var rpcErr *RPCError
+			if errors.As(err, &rpcErr) {
+				return rpcErr
+			}
+			if res.StatusCode < 200 || res.StatusCode > 299 {
+				return &HTTPError{StatusCode: res.StatusCode, Body: string(body)}
+			}
 			return fmt.Errorf("decoding http response: %v", err)
 		}
 		return nil
@@ -237,6 +421,10 @@ func (client *client) send(ctx context.Context, resp interface{}, method string,
 }
 
 func encodeRequest(method string, params []interface{}) ([]byte, error) {
+	return encodeRequestWithID(rand.Int(), method, params)
+}
+
+func encodeRequestWithID(id int, method string, params []interface{}) ([]byte, error) {
 	rawParams, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("encoding params: %v", err)
@@ -248,7 +436,7 @@ func encodeRequest(method string, params []interface{}) ([]byte, error) {
 		Params  json.RawMessage `json:"params"`
 	}{
 		Version: "2.0",
-		ID:      rand.Int(),
+		ID:      id,
 		Method:  method,
 		Params:  rawParams,
 	}
@@ -270,6 +458,10 @@ func decodeResponse(resp interface{}, r io.Reader) error {
 		return fmt.Errorf("decoding response: %v", err)
 	}
 	if res.Error != nil {
+		rpcErr := RPCError{}
+		if err := json.Unmarshal(*res.Error, &rpcErr); err == nil && rpcErr.Code != 0 {
+			return &rpcErr
+		}
 		return fmt.Errorf("decoding response: %v", string(*res.Error))
 	}
 	if res.Result == nil {
@@ -280,18 +472,3 @@ func decodeResponse(resp interface{}, r io.Reader) error {
 	}
 	return nil
 }
-
-func retry(ctx context.Context, dur time.Duration, f func() error) error {
-	ticker := time.NewTicker(dur)
-	err := f()
-	for err != nil {
-		log.Printf("retrying: %v", err)
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			err = f()
-		}
-	}
-	return nil
-}
@@ -0,0 +1,418 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/gas"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+const (
+	// DefaultCoinSelectorMinConf used by the CoinSelector.
+	DefaultCoinSelectorMinConf = int64(1)
+	// DefaultCoinSelectorMaxConf used by the CoinSelector.
+	DefaultCoinSelectorMaxConf = int64(9999999)
+	// DefaultCoinSelectorMaxTries used by the CoinSelector. It is the maximum
+	// number of branches that are explored by branch-and-bound before falling
+	// back to largest-first selection.
+	DefaultCoinSelectorMaxTries = 100000
+)
+
+var (
+	// DefaultCoinSelectorDustThreshold used by the CoinSelector. This is the
+	// dust threshold of P2PKH outputs at the default minimum relay fee.
+	DefaultCoinSelectorDustThreshold = pack.NewU256FromU64(pack.NewU64(546))
+)
+
+// CoinSelectorOptions are used to parameterise the behaviour of the
+// CoinSelector.
+type CoinSelectorOptions struct {
+	MinConf       int64
+	MaxConf       int64
+	DustThreshold pack.U256
+	MaxTries      int
+	SigScript     pack.Bytes
+	InputScript   InputScriptFunc
+}
+
+// DefaultCoinSelectorOptions returns CoinSelectorOptions with the default
+// settings.
+func DefaultCoinSelectorOptions() CoinSelectorOptions {
+	return CoinSelectorOptions{
+		MinConf:       DefaultCoinSelectorMinConf,
+		MaxConf:       DefaultCoinSelectorMaxConf,
+		DustThreshold: DefaultCoinSelectorDustThreshold,
+		MaxTries:      DefaultCoinSelectorMaxTries,
+		SigScript:     nil,
+		InputScript:   InputScriptFromInput,
+	}
+}
+
+// WithMinConf sets the minimum number of confirmations required for unspent
+// outputs to be selected.
+func (opts CoinSelectorOptions) WithMinConf(minConf int64) CoinSelectorOptions {
+	opts.MinConf = minConf
+	return opts
+}
+
+// WithMaxConf sets the maximum number of confirmations allowed for unspent
+// outputs to be selected.
+func (opts CoinSelectorOptions) WithMaxConf(maxConf int64) CoinSelectorOptions {
+	opts.MaxConf = maxConf
+	return opts
+}
+
+// WithDustThreshold sets the value below which outputs are considered dust.
+// Recipients must not receive dust, and change is not produced if it would be
+// dust.
+func (opts CoinSelectorOptions) WithDustThreshold(dustThreshold pack.U256) CoinSelectorOptions {
+	opts.DustThreshold = dustThreshold
+	return opts
+}
+
+// WithMaxTries sets the maximum number of branches that are explored by
+// branch-and-bound before falling back to largest-first selection.
+func (opts CoinSelectorOptions) WithMaxTries(maxTries int) CoinSelectorOptions {
+	opts.MaxTries = maxTries
+	return opts
+}
+
+// WithSigScript sets the sig script of selected inputs. It must be set when
+// selecting the unspent outputs of a P2SH or P2WSH address, and is the redeem
+// script (or witness script) of the address.
+func (opts CoinSelectorOptions) WithSigScript(sigScript pack.Bytes) CoinSelectorOptions {
+	opts.SigScript = sigScript
+	return opts
+}
+
+// WithInputScript sets the function that returns the InputScript of selected
+// inputs, which is used to estimate the size of transactions after they have
+// been signed. By default, InputScripts are inferred from the pubkey scripts
+// and sig scripts of the inputs.
+func (opts CoinSelectorOptions) WithInputScript(inputScript InputScriptFunc) CoinSelectorOptions {
+	opts.InputScript = inputScript
+	return opts
+}
+
+// A CoinSelector builds transactions by selecting the unspent outputs of an
+// address as inputs, paying a fee based on the estimated gas price, and
+// sending any change back to a change address. It can be used with the
+// transaction builder of any Bitcoin-family chain.
+type CoinSelector struct {
+	client       Client
+	txBuilder    utxo.TxBuilder
+	gasEstimator gas.Estimator
+	opts         CoinSelectorOptions
+}
+
+// NewCoinSelector returns a CoinSelector that loads unspent outputs using the
+// given client, estimates SATs-per-byte using the given gas estimator, and
+// builds transactions using the given transaction builder.
+func NewCoinSelector(client Client, txBuilder utxo.TxBuilder, gasEstimator gas.Estimator, opts CoinSelectorOptions) CoinSelector {
+	return CoinSelector{
+		client:       client,
+		txBuilder:    txBuilder,
+		gasEstimator: gasEstimator,
+		opts:         opts,
+	}
+}
+
+// BuildTx returns a transaction that consumes unspent outputs of the "from"
+// address, and sends funds to the given recipients. Change is sent to the
+// change address.
+func (selector CoinSelector) BuildTx(ctx context.Context, from address.Address, recipients []utxo.Recipient, change address.Address) (utxo.Tx, error) {
+	outputs, err := selector.client.UnspentOutputs(ctx, selector.opts.MinConf, selector.opts.MaxConf, from)
+	if err != nil {
+		return nil, fmt.Errorf("loading unspent outputs: %v", err)
+	}
+	satsPerByte, err := selector.gasEstimator.EstimateGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("estimating gas price: %v", err)
+	}
+	return SelectCoins(selector.txBuilder, outputs, recipients, change, satsPerByte, selector.opts)
+}
+
+// SelectCoins returns a transaction that consumes some of the given outputs,
+// and sends funds to the given recipients. Outputs are selected using
+// branch-and-bound, which searches for a set of outputs that does not need a
+// change output. If no such set can be found, outputs are selected
+// largest-first, and a change output is sent to the change address (unless the
+// change would be dust, in which case it is paid as a fee). The fee is computed
+// from the given SATs-per-byte and the virtual size of the transaction after
+// it has been signed. Recipients with data produce null-data outputs, which
+// can have a value of zero.
+//
+// Outputs are selected deterministically, so independent nodes with the same
+// outputs will build identical transactions.
+func SelectCoins(txBuilder utxo.TxBuilder, outputs []utxo.Output, recipients []utxo.Recipient, change address.Address, satsPerByte pack.U256, opts CoinSelectorOptions) (utxo.Tx, error) {
+	if change == "" {
+		return nil, fmt.Errorf("bad change: expected change address")
+	}
+	if !satsPerByte.Int().IsInt64() {
+		return nil, fmt.Errorf("bad sats per byte: %v is too large", satsPerByte)
+	}
+	rate := satsPerByte.Int().Int64()
+	if !opts.DustThreshold.Int().IsInt64() {
+		return nil, fmt.Errorf("bad dust threshold: %v is too large", opts.DustThreshold)
+	}
+	dust := opts.DustThreshold.Int().Int64()
+	inputScript := opts.InputScript
+	if inputScript == nil {
+		inputScript = InputScriptFromInput
+	}
+
+	target := int64(0)
+	for i, recipient := range recipients {
+		if !recipient.Value.Int().IsInt64() {
+			return nil, fmt.Errorf("bad recipient %v: value %v is too large", i, recipient.Value)
+		}
+		value := recipient.Value.Int().Int64()
+		if value < dust && len(recipient.Data) == 0 {
+			return nil, fmt.Errorf("bad recipient %v: value %v is dust", i, value)
+		}
+		target += value
+	}
+
+	// Measure the weight of a transaction without inputs, with and without the
+	// change output.
+	withChange := append(recipients[:len(recipients):len(recipients)], utxo.Recipient{To: change, Value: pack.NewU256FromU64(pack.NewU64(0))})
+	baseWeight, err := unsignedWeight(txBuilder, recipients)
+	if err != nil {
+		return nil, err
+	}
+	withChangeWeight, err := unsignedWeight(txBuilder, withChange)
+	if err != nil {
+		return nil, err
+	}
+	baseFee := divCeil(baseWeight, 4) * rate
+	changeFee := divCeil(withChangeWeight, 4)*rate - baseFee
+	fees := selectionFees{target: target, rate: rate, dust: dust, baseWeight: baseWeight, withChangeWeight: withChangeWeight}
+
+	// Compute the effective value of each output, which is its value minus the
+	// fee required to spend it. Outputs that cost more to spend than they are
+	// worth are ignored.
+	candidates := make([]candidate, 0, len(outputs))
+	for _, output := range outputs {
+		if !output.Value.Int().IsInt64() {
+			return nil, fmt.Errorf("bad output: value %v is too large", output.Value)
+		}
+		value := output.Value.Int().Int64()
+		input := utxo.Input{Output: output, SigScript: opts.SigScript}
+		script, err := inputScript(input)
+		if err != nil {
+			return nil, fmt.Errorf("bad output: %v", err)
+		}
+		weight, err := script.weight()
+		if err != nil {
+			return nil, err
+		}
+		effectiveValue := value - divCeil(int64(weight)*rate, 4)
+		if effectiveValue <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{input: input, value: value, effectiveValue: effectiveValue, weight: int64(weight), witness: script.IsWitness()})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].effectiveValue != candidates[j].effectiveValue {
+			return candidates[i].effectiveValue > candidates[j].effectiveValue
+		}
+		if cmp := bytes.Compare(candidates[i].input.Hash, candidates[j].input.Hash); cmp != 0 {
+			return cmp < 0
+		}
+		return candidates[i].input.Index < candidates[j].input.Index
+	})
+
+	// Search for a set of outputs that does not need change.
+	effectiveValues := make([]int64, len(candidates))
+	for i := range candidates {
+		effectiveValues[i] = candidates[i].effectiveValue
+	}
+	if selection := branchAndBound(effectiveValues, target+baseFee, changeFee+dust, opts.MaxTries); selection != nil {
+		selected := make([]candidate, len(selection))
+		var totals selectionTotals
+		for i, j := range selection {
+			selected[i] = candidates[j]
+			totals.add(candidates[j])
+		}
+		if tx, err := fees.buildTx(txBuilder, selected, totals, recipients, change); err == nil {
+			return tx, nil
+		}
+	}
+
+	// Fall back to selecting the largest outputs first. The value and weight
+	// of the selected outputs are accumulated as each output is added, so that
+	// the transaction is only built once.
+	var totals selectionTotals
+	for i := range candidates {
+		totals.add(candidates[i])
+		if _, _, err := fees.change(totals); err == nil {
+			return fees.buildTx(txBuilder, candidates[:i+1], totals, recipients, change)
+		}
+	}
+	return nil, fmt.Errorf("insufficient funds: expected %v plus fees", target)
+}
+
+// candidate is an output that can be selected as an input.
+type candidate struct {
+	input          utxo.Input
+	value          int64
+	effectiveValue int64
+	weight         int64
+	witness        bool
+}
+
+// selectionTotals accumulates the value of the selected outputs, and the
+// weight that they add to a transaction once they have been signed.
+type selectionTotals struct {
+	n           int
+	nonWitness  int
+	value       int64
+	inputWeight int64
+}
+
+// add a selected output.
+func (totals *selectionTotals) add(c candidate) {
+	totals.n++
+	if !c.witness {
+		totals.nonWitness++
+	}
+	totals.value += c.value
+	totals.inputWeight += c.weight
+}
+
+// weight returns the weight that is added to a transaction by the selected
+// outputs, in the same way as EstimateSize. This includes the input count, and
+// the marker, flag, and empty witnesses of segwit transactions.
+func (totals selectionTotals) weight() int64 {
+	weight := totals.inputWeight + 4*int64(wire.VarIntSerializeSize(uint64(totals.n))-wire.VarIntSerializeSize(0))
+	if totals.nonWitness < totals.n {
+		weight += 2 + int64(totals.nonWitness*wire.VarIntSerializeSize(0))
+	}
+	return weight
+}
+
+// selectionFees computes the fees of transactions that pay the target to the
+// recipients, given the weight of the transaction without inputs (with, and
+// without, a change output).
+type selectionFees struct {
+	target, rate, dust           int64
+	baseWeight, withChangeWeight int64
+}
+
+// change returns the value of the change output that is needed when spending
+// the selected outputs, and whether the change output is needed at all. Change
+// is not needed if it would be dust. An error is returned if the selected
+// outputs cannot pay for the recipients and the fee.
+func (fees selectionFees) change(totals selectionTotals) (int64, bool, error) {
+	fee := divCeil(fees.withChangeWeight+totals.weight(), 4) * fees.rate
+	if changeValue := totals.value - fees.target - fee; changeValue >= fees.dust {
+		return changeValue, true, nil
+	}
+	fee = divCeil(fees.baseWeight+totals.weight(), 4) * fees.rate
+	if totals.value-fees.target < fee {
+		return 0, false, fmt.Errorf("insufficient funds: expected %v, got %v", fees.target+fee, totals.value)
+	}
+	return 0, false, nil
+}
+
+// buildTx returns a transaction that spends the selected outputs. A change
+// output is added if the change is not dust. An error is returned if the
+// selected outputs cannot pay for the recipients and the fee.
+func (fees selectionFees) buildTx(txBuilder utxo.TxBuilder, selected []candidate, totals selectionTotals, recipients []utxo.Recipient, change address.Address) (utxo.Tx, error) {
+	changeValue, hasChange, err := fees.change(totals)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]utxo.Input, len(selected))
+	for i := range selected {
+		inputs[i] = selected[i].input
+	}
+	if hasChange {
+		withChange := append(recipients[:len(recipients):len(recipients)], utxo.Recipient{To: change, Value: pack.NewU256FromU64(pack.NewU64(uint64(changeValue)))})
+		return txBuilder.BuildTx(inputs, withChange)
+	}
+	return txBuilder.BuildTx(inputs, recipients)
+}
+
+// unsignedWeight returns the weight of a transaction that has no inputs, and
+// pays the given recipients.
+func unsignedWeight(txBuilder utxo.TxBuilder, recipients []utxo.Recipient) (int64, error) {
+	tx, err := txBuilder.BuildTx(nil, recipients)
+	if err != nil {
+		return 0, fmt.Errorf("bad tx: %v", err)
+	}
+	serial, err := tx.Serialize()
+	if err != nil {
+		return 0, fmt.Errorf("bad tx: %v", err)
+	}
+	return int64(4 * len(serial)), nil
+}
+
+// branchAndBound returns the indices of a subset of values with a sum in the
+// range [target, target+tolerance], minimising the excess. The values must be
+// positive and sorted in descending order. If no subset can be found within
+// the maximum number of tries, then nil is returned.
+func branchAndBound(values []int64, target, tolerance int64, maxTries int) []int {
+	remaining := int64(0)
+	for _, value := range values {
+		remaining += value
+	}
+	if remaining < target {
+		return nil
+	}
+
+	tries := 0
+	selection := make([]bool, len(values))
+	var best []bool
+	bestExcess := int64(math.MaxInt64)
+
+	var search func(i int, sum, remaining int64)
+	search = func(i int, sum, remaining int64) {
+		if tries >= maxTries || bestExcess == 0 {
+			return
+		}
+		tries++
+		if sum > target+tolerance || sum+remaining < target {
+			return
+		}
+		if sum >= target {
+			if excess := sum - target; excess < bestExcess {
+				bestExcess = excess
+				best = append(best[:0], selection...)
+			}
+			return
+		}
+		if i == len(values) {
+			return
+		}
+		remaining -= values[i]
+		selection[i] = true
+		search(i+1, sum+values[i], remaining)
+		selection[i] = false
+		search(i+1, sum, remaining)
+	}
+	search(0, 0, remaining)
+
+	if best == nil {
+		return nil
+	}
+	indices := []int{}
+	for i := range best {
+		if best[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// divCeil returns x divided by y, rounded up.
+func divCeil(x, y int64) int64 {
+	return (x + y - 1) / y
+}
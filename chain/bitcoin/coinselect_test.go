@@ -0,0 +1,241 @@
+package bitcoin_test
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coin selection", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+	satsPerByte := pack.NewU256FromU64(pack.NewU64(10))
+	opts := bitcoin.DefaultCoinSelectorOptions()
+
+	privKey := id.NewPrivKey()
+	pubKey := (*btcec.PublicKey)(&privKey.PublicKey).SerializeCompressed()
+	from, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	fromScript, err := txscript.PayToAddrScript(from)
+	if err != nil {
+		panic(err)
+	}
+	to := address.Address(from.EncodeAddress())
+	change := address.Address(from.EncodeAddress())
+
+	output := func(i byte, value uint64) utxo.Output {
+		return utxo.Output{
+			Outpoint: utxo.Outpoint{
+				Hash:  pack.NewBytes(append(make([]byte, 31), i)),
+				Index: pack.NewU32(0),
+			},
+			Value:        pack.NewU256FromU64(pack.NewU64(value)),
+			PubKeyScript: pack.NewBytes(fromScript),
+		}
+	}
+	recipient := func(value uint64) utxo.Recipient {
+		return utxo.Recipient{To: to, Value: pack.NewU256FromU64(pack.NewU64(value))}
+	}
+	sum := func(values ...pack.U256) uint64 {
+		total := uint64(0)
+		for _, value := range values {
+			total += value.Int().Uint64()
+		}
+		return total
+	}
+	sign := func(tx utxo.Tx) pack.Bytes {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		signatures := make([]pack.Bytes65, len(sighashes))
+		for i := range sighashes {
+			hash := id.Hash(sighashes[i])
+			signature, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures[i] = pack.NewBytes65(signature)
+		}
+		Expect(tx.Sign(signatures, pack.NewBytes(pubKey))).To(Succeed())
+		serial, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+		return serial
+	}
+	// checkFee checks that the fee is enough to pay for the signed transaction,
+	// and returns the fee.
+	checkFee := func(tx utxo.Tx) uint64 {
+		inputs, err := tx.Inputs()
+		Expect(err).ToNot(HaveOccurred())
+		outputs, err := tx.Outputs()
+		Expect(err).ToNot(HaveOccurred())
+		in := uint64(0)
+		for _, input := range inputs {
+			in += input.Value.Int().Uint64()
+		}
+		out := uint64(0)
+		for _, output := range outputs {
+			out += output.Value.Int().Uint64()
+		}
+		Expect(in).To(BeNumerically(">=", out))
+		fee := in - out
+		Expect(fee).To(BeNumerically(">=", uint64(len(sign(tx)))*satsPerByte.Int().Uint64()))
+		return fee
+	}
+
+	Context("when there is a set of outputs that does not need change", func() {
+		It("should not add a change output", func() {
+			outputs := []utxo.Output{output(1, 100000), output(2, 30000), output(3, 20000), output(4, 5000)}
+			tx, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(50000)}, change, satsPerByte, opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			txOutputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txOutputs).To(HaveLen(1))
+
+			fee := checkFee(tx)
+			Expect(fee).To(BeNumerically("<", uint64(6000)))
+		})
+	})
+
+	Context("when change is needed", func() {
+		It("should add a change output to the change address", func() {
+			outputs := []utxo.Output{output(1, 100000), output(2, 200000)}
+			tx, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(150000), recipient(10000)}, change, satsPerByte, opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(1))
+			Expect(inputs[0].Value).To(Equal(pack.NewU256FromU64(pack.NewU64(200000))))
+
+			txOutputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txOutputs).To(HaveLen(3))
+			Expect(txOutputs[2].PubKeyScript).To(Equal(pack.NewBytes(fromScript)))
+			Expect(sum(txOutputs[2].Value)).To(BeNumerically(">", uint64(30000)))
+
+			fee := checkFee(tx)
+			Expect(fee).To(BeNumerically("<", uint64(3000)))
+		})
+
+		It("should pay the change as a fee if it is dust", func() {
+			outputs := []utxo.Output{output(1, 100000)}
+			tx, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(97800)}, change, satsPerByte, opts.WithMaxTries(0))
+			Expect(err).ToNot(HaveOccurred())
+
+			txOutputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txOutputs).To(HaveLen(1))
+			checkFee(tx)
+		})
+	})
+
+	Context("when there are many outputs", func() {
+		It("should select the largest outputs first", func() {
+			outputs := make([]utxo.Output, 0, 1000)
+			for i := 0; i < cap(outputs); i++ {
+				outputs = append(outputs, output(byte(i), 10000+uint64(i%7)))
+				outputs[i].Index = pack.NewU32(uint32(i))
+			}
+			tx, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(2600000)}, change, satsPerByte, opts.WithMaxTries(0))
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(inputs)).To(BeNumerically(">", 253))
+			for i := 1; i < len(inputs); i++ {
+				Expect(inputs[i-1].Value.Int().Cmp(inputs[i].Value.Int())).To(BeNumerically(">=", 0))
+			}
+
+			// Signatures can be up to 2 bytes smaller than the estimate.
+			fee := checkFee(tx)
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fee).To(BeNumerically("<=", uint64(len(serial)+2*len(inputs))*satsPerByte.Int().Uint64()))
+		})
+	})
+
+	Context("when selecting the outputs of a P2SH address", func() {
+		It("should pay for the redeem script", func() {
+			pubKeys := make([]*btcutil.AddressPubKey, 3)
+			for i := range pubKeys {
+				pubKey, err := btcutil.NewAddressPubKey((*btcec.PublicKey)(&id.NewPrivKey().PublicKey).SerializeCompressed(), params)
+				Expect(err).ToNot(HaveOccurred())
+				pubKeys[i] = pubKey
+			}
+			redeemScript, err := txscript.MultiSigScript(pubKeys, 2)
+			Expect(err).ToNot(HaveOccurred())
+			scriptAddr, err := btcutil.NewAddressScriptHash(redeemScript, params)
+			Expect(err).ToNot(HaveOccurred())
+			scriptPubKey, err := txscript.PayToAddrScript(scriptAddr)
+			Expect(err).ToNot(HaveOccurred())
+
+			outputs := []utxo.Output{output(1, 100000), output(2, 100000)}
+			for i := range outputs {
+				outputs[i].PubKeyScript = pack.NewBytes(scriptPubKey)
+			}
+			tx, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(150000)}, change, satsPerByte, opts.WithSigScript(redeemScript))
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(2))
+			for _, input := range inputs {
+				Expect(input.SigScript).To(Equal(pack.NewBytes(redeemScript)))
+			}
+			txOutputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txOutputs).To(HaveLen(2))
+
+			// Each sig script pushes OP_0, two signatures, and the redeem
+			// script, and needs a 3 byte length prefix.
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			sigScriptSize := 1 + 2*74 + 2 + len(redeemScript)
+			vsize := len(serial) + len(inputs)*(3+sigScriptSize-1)
+			Expect(sum(inputs[0].Value, inputs[1].Value) - sum(txOutputs[0].Value, txOutputs[1].Value)).To(Equal(uint64(vsize) * satsPerByte.Int().Uint64()))
+		})
+	})
+
+	Context("when selecting the same outputs in a different order", func() {
+		It("should build the same transaction", func() {
+			outputs := []utxo.Output{output(1, 40000), output(2, 40000), output(3, 40000), output(4, 70000)}
+			reversed := []utxo.Output{outputs[3], outputs[2], outputs[1], outputs[0]}
+
+			tx1, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(100000)}, change, satsPerByte, opts)
+			Expect(err).ToNot(HaveOccurred())
+			tx2, err := bitcoin.SelectCoins(txBuilder, reversed, []utxo.Recipient{recipient(100000)}, change, satsPerByte, opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			hash1, err := tx1.Hash()
+			Expect(err).ToNot(HaveOccurred())
+			hash2, err := tx2.Hash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash1).To(Equal(hash2))
+		})
+	})
+
+	Context("when there are insufficient funds", func() {
+		It("should return an error", func() {
+			outputs := []utxo.Output{output(1, 10000), output(2, 20000)}
+			_, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(30000)}, change, satsPerByte, opts)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when a recipient would receive dust", func() {
+		It("should return an error", func() {
+			outputs := []utxo.Output{output(1, 100000)}
+			_, err := bitcoin.SelectCoins(txBuilder, outputs, []utxo.Recipient{recipient(545)}, change, satsPerByte, opts)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
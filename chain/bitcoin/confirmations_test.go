@@ -0,0 +1,126 @@
+package bitcoin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newRPCServer returns a server that responds to requests using the result, or
+// error, returned by the handler for the method of the request.
+func newRPCServer(handler func(method string, params []interface{}) (string, string)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     int           `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result, rpcErr := handler(req.Method, req.Params)
+		res := struct {
+			ID     int              `json:"id"`
+			Result *json.RawMessage `json:"result"`
+			Error  *json.RawMessage `json:"error"`
+		}{ID: req.ID}
+		if rpcErr != "" {
+			raw := json.RawMessage(rpcErr)
+			res.Error = &raw
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			raw := json.RawMessage(result)
+			res.Result = &raw
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+var _ = Describe("Confirmations", func() {
+	txHash := chainhash.Hash{1}
+	blockHash := chainhash.Hash{2}
+
+	confirmations := func(handler func(method string, params []interface{}) (string, string)) (int64, error) {
+		server := newRPCServer(handler)
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+		return client.Confirmations(ctx, pack.NewBytes(txHash[:]))
+	}
+
+	Context("when the transaction is in a block", func() {
+		It("should return the confirmations of the transaction", func() {
+			confs, err := confirmations(func(method string, params []interface{}) (string, string) {
+				Expect(method).To(Equal("getrawtransaction"))
+				Expect(params).To(Equal([]interface{}{txHash.String(), float64(1)}))
+				return `{"blockhash":"` + blockHash.String() + `","confirmations":6}`, ""
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(6)))
+		})
+
+		It("should load the confirmations from the block header if they are not returned", func() {
+			confs, err := confirmations(func(method string, params []interface{}) (string, string) {
+				switch method {
+				case "getrawtransaction":
+					return `{"blockhash":"` + blockHash.String() + `"}`, ""
+				case "getblockheader":
+					Expect(params).To(Equal([]interface{}{blockHash.String(), true}))
+					return `{"hash":"` + blockHash.String() + `","confirmations":3}`, ""
+				}
+				return "", `{"code":-32601,"message":"Method not found"}`
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(3)))
+		})
+	})
+
+	Context("when the transaction is in the mempool", func() {
+		It("should return zero confirmations", func() {
+			confs, err := confirmations(func(method string, params []interface{}) (string, string) {
+				return `{"txid":"` + txHash.String() + `"}`, ""
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(0)))
+		})
+	})
+
+	Context("when the transaction is not known by the node", func() {
+		It("should return ErrTxNotFound without retrying", func() {
+			requests := 0
+			_, err := confirmations(func(method string, params []interface{}) (string, string) {
+				requests++
+				return "", `{"code":-5,"message":"No such mempool or blockchain transaction"}`
+			})
+			Expect(err).To(Equal(bitcoin.ErrTxNotFound))
+			Expect(requests).To(Equal(1))
+		})
+	})
+
+	Context("when the block of the transaction is given", func() {
+		It("should include the block hash in the request", func() {
+			server := newRPCServer(func(method string, params []interface{}) (string, string) {
+				Expect(params).To(Equal([]interface{}{txHash.String(), float64(1), blockHash.String()}))
+				return `{"blockhash":"` + blockHash.String() + `","confirmations":2}`, ""
+			})
+			defer server.Close()
+
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+			confs, err := client.ConfirmationsInBlock(context.Background(), pack.NewBytes(txHash[:]), pack.NewBytes(blockHash[:]))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(2)))
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/gas"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+// A CPFPBuilder builds child-pays-for-parent transactions. A child transaction
+// spends an output of a stuck parent transaction, and pays a fee that is large
+// enough for the parent and the child (considered together as a package) to
+// reach the estimated fee rate. This is the only way to speed up transactions
+// on chains that do not support replace-by-fee. It can be used with the
+// transaction builder of any Bitcoin-family chain.
+type CPFPBuilder struct {
+	client        Client
+	txBuilder     utxo.TxBuilder
+	gasEstimator  gas.Estimator
+	dustThreshold pack.U256
+	sigScript     pack.Bytes
+	inputScript   InputScriptFunc
+}
+
+// NewCPFPBuilder returns a CPFPBuilder that loads parent transactions using the
+// given client, estimates SATs-per-byte using the given gas estimator, and
+// builds child transactions using the given transaction builder. Child
+// transactions are not built if their output would be less than the dust
+// threshold.
+func NewCPFPBuilder(client Client, txBuilder utxo.TxBuilder, gasEstimator gas.Estimator, dustThreshold pack.U256) CPFPBuilder {
+	return CPFPBuilder{
+		client:        client,
+		txBuilder:     txBuilder,
+		gasEstimator:  gasEstimator,
+		dustThreshold: dustThreshold,
+		sigScript:     nil,
+		inputScript:   InputScriptFromInput,
+	}
+}
+
+// WithSigScript returns a copy of the CPFPBuilder that builds child
+// transactions with the given sig script. It must be set when spending an
+// output of a P2SH or P2WSH address, and is the redeem script (or witness
+// script) of the address.
+func (builder CPFPBuilder) WithSigScript(sigScript pack.Bytes) CPFPBuilder {
+	builder.sigScript = sigScript
+	return builder
+}
+
+// WithInputScript returns a copy of the CPFPBuilder that uses the given
+// function to get the InputScript of child transactions, which is used to
+// estimate their size after they have been signed. By default, InputScripts
+// are inferred from the pubkey scripts and sig scripts of the inputs.
+func (builder CPFPBuilder) WithInputScript(inputScript InputScriptFunc) CPFPBuilder {
+	builder.inputScript = inputScript
+	return builder
+}
+
+// BuildTx returns an unsigned child transaction that spends the given output of
+// a parent transaction (usually its change output) and sends the remaining
+// value to the given address. The hash of the outpoint identifies the parent
+// transaction. The fee paid by the child is:
+//
+//  satsPerByte * (parentVSize + childVSize) - parentFee
+//
+// but is never less than the minimum relay fee of the child.
+func (builder CPFPBuilder) BuildTx(ctx context.Context, outpoint utxo.Outpoint, to address.Address) (utxo.Tx, error) {
+	parentFee, parentVSize, err := builder.parentFee(ctx, outpoint.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("bad parent: %v", err)
+	}
+	output, _, err := builder.client.Output(ctx, outpoint)
+	if err != nil {
+		return nil, fmt.Errorf("bad output: %v", err)
+	}
+	satsPerByte, err := builder.gasEstimator.EstimateGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("estimating gas price: %v", err)
+	}
+
+	// Estimate the size of the child, which does not depend on the value of
+	// its output.
+	inputs := []utxo.Input{{Output: output, SigScript: builder.sigScript}}
+	recipients := []utxo.Recipient{{To: to, Value: output.Value}}
+	tx, err := builder.txBuilder.BuildTx(inputs, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("bad child: %v", err)
+	}
+	inputScript := builder.inputScript
+	if inputScript == nil {
+		inputScript = InputScriptFromInput
+	}
+	scripts, err := inputScripts(inputs, inputScript)
+	if err != nil {
+		return nil, fmt.Errorf("bad child: %v", err)
+	}
+	_, childVSize, err := EstimateSize(tx, scripts)
+	if err != nil {
+		return nil, fmt.Errorf("bad child: %v", err)
+	}
+
+	// Compute the fee that must be paid by the child for the package to reach
+	// the target fee rate.
+	fee := new(big.Int).Mul(satsPerByte.Int(), big.NewInt(parentVSize+int64(childVSize)))
+	fee.Sub(fee, parentFee)
+	minFee := new(big.Int).Mul(MinRelaySatsPerByte.Int(), big.NewInt(int64(childVSize)))
+	if fee.Cmp(minFee) < 0 {
+		fee = minFee
+	}
+	value := new(big.Int).Sub(output.Value.Int(), fee)
+	if value.Cmp(builder.dustThreshold.Int()) < 0 {
+		return nil, fmt.Errorf("bad child: expected value >= %v, got value %v", builder.dustThreshold, value)
+	}
+	recipients[0].Value = pack.NewU256FromInt(value)
+	return builder.txBuilder.BuildTx(inputs, recipients)
+}
+
+// parentFee returns the fee paid by the parent transaction, and its virtual
+// size.
+func (builder CPFPBuilder) parentFee(ctx context.Context, txHash pack.Bytes) (*big.Int, int64, error) {
+	parent, err := builder.client.RawTransaction(ctx, txHash)
+	if err != nil {
+		return nil, 0, err
+	}
+	vsize := int64(parent.Vsize)
+	if vsize == 0 {
+		// Nodes for chains that do not support segwit do not return the
+		// virtual size.
+		vsize = int64(parent.Size)
+	}
+	if vsize <= 0 {
+		return nil, 0, fmt.Errorf("bad size: %v", vsize)
+	}
+
+	fee := new(big.Int)
+	for _, vin := range parent.Vin {
+		if vin.IsCoinBase() {
+			return nil, 0, fmt.Errorf("bad input: coinbase")
+		}
+		hash, err := chainhash.NewHashFromStr(vin.Txid)
+		if err != nil {
+			return nil, 0, fmt.Errorf("bad input: %v", err)
+		}
+		output, _, err := builder.client.Output(ctx, utxo.Outpoint{Hash: pack.NewBytes(hash[:]), Index: pack.NewU32(vin.Vout)})
+		if err != nil {
+			return nil, 0, fmt.Errorf("bad input: %v", err)
+		}
+		fee.Add(fee, output.Value.Int())
+	}
+	for _, vout := range parent.Vout {
+		amount, err := btcutil.NewAmount(vout.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("bad amount: %v", err)
+		}
+		fee.Sub(fee, big.NewInt(int64(amount)))
+	}
+	if fee.Sign() < 0 {
+		return nil, 0, fmt.Errorf("bad fee: %v", fee)
+	}
+	return fee, vsize, nil
+}
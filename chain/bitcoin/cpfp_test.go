@@ -0,0 +1,153 @@
+package bitcoin_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockChainClient is a client that serves outputs and raw transactions from
+// memory.
+type mockChainClient struct {
+	bitcoin.Client
+	outputs map[string]utxo.Output
+	txs     map[string]btcjson.TxRawResult
+}
+
+func (client *mockChainClient) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.Output, pack.U64, error) {
+	output, ok := client.outputs[outpointKey(outpoint)]
+	if !ok {
+		return utxo.Output{}, pack.NewU64(0), fmt.Errorf("not found")
+	}
+	return output, pack.NewU64(0), nil
+}
+
+func (client *mockChainClient) RawTransaction(ctx context.Context, txHash pack.Bytes) (btcjson.TxRawResult, error) {
+	tx, ok := client.txs[hex.EncodeToString(txHash)]
+	if !ok {
+		return btcjson.TxRawResult{}, fmt.Errorf("not found")
+	}
+	return tx, nil
+}
+
+// outpointKey returns the key of the outpoint in the outputs of the client.
+func outpointKey(outpoint utxo.Outpoint) string {
+	return fmt.Sprintf("%v:%v", hex.EncodeToString(outpoint.Hash), outpoint.Index.Uint32())
+}
+
+var _ = Describe("Child-pays-for-parent", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+	dust := pack.NewU256FromU64(pack.NewU64(546))
+
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		panic(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		panic(err)
+	}
+	to := address.Address(addr.EncodeAddress())
+
+	// The grandparent output is spent by the parent, which pays a fee of 1000
+	// SATs and has a virtual size of 200 bytes.
+	grandparentHash := chainhash.Hash{1}
+	parentHash := chainhash.Hash{2}
+	grandparentOutpoint := utxo.Outpoint{Hash: pack.NewBytes(grandparentHash[:]), Index: pack.NewU32(0)}
+	changeOutpoint := utxo.Outpoint{Hash: pack.NewBytes(parentHash[:]), Index: pack.NewU32(1)}
+
+	newClient := func() *mockChainClient {
+		return &mockChainClient{
+			outputs: map[string]utxo.Output{
+				outpointKey(grandparentOutpoint): {
+					Outpoint:     grandparentOutpoint,
+					Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+					PubKeyScript: pack.NewBytes(script),
+				},
+				outpointKey(changeOutpoint): {
+					Outpoint:     changeOutpoint,
+					Value:        pack.NewU256FromU64(pack.NewU64(49000)),
+					PubKeyScript: pack.NewBytes(script),
+				},
+			},
+			txs: map[string]btcjson.TxRawResult{
+				hex.EncodeToString(parentHash[:]): {
+					Txid:  parentHash.String(),
+					Size:  200,
+					Vsize: 200,
+					Vin:   []btcjson.Vin{{Txid: grandparentHash.String(), Vout: 0}},
+					Vout:  []btcjson.Vout{{Value: 0.0005, N: 0}, {Value: 0.00049, N: 1}},
+				},
+			},
+		}
+	}
+
+	Context("when the parent pays a low fee", func() {
+		It("should build a child that pays for the package", func() {
+			client := newClient()
+			builder := bitcoin.NewCPFPBuilder(client, txBuilder, bitcoin.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(20))), dust)
+			tx, err := builder.BuildTx(context.Background(), changeOutpoint, to)
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(1))
+			Expect(inputs[0].Outpoint).To(Equal(changeOutpoint))
+
+			_, childVSize, err := bitcoin.EstimateSize(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs).To(HaveLen(1))
+			childFee := 49000 - outputs[0].Value.Int().Uint64()
+			Expect(childFee).To(Equal(uint64(20*(200+childVSize) - 1000)))
+		})
+	})
+
+	Context("when the parent already pays enough", func() {
+		It("should pay the minimum relay fee", func() {
+			client := newClient()
+			builder := bitcoin.NewCPFPBuilder(client, txBuilder, bitcoin.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(1))), dust)
+			tx, err := builder.BuildTx(context.Background(), changeOutpoint, to)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, childVSize, err := bitcoin.EstimateSize(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(49000 - outputs[0].Value.Int().Uint64()).To(Equal(uint64(childVSize)))
+		})
+	})
+
+	Context("when the output cannot pay for the package", func() {
+		It("should return an error", func() {
+			client := newClient()
+			builder := bitcoin.NewCPFPBuilder(client, txBuilder, bitcoin.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(200))), dust)
+			_, err := builder.BuildTx(context.Background(), changeOutpoint, to)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the parent cannot be found", func() {
+		It("should return an error", func() {
+			client := newClient()
+			builder := bitcoin.NewCPFPBuilder(client, txBuilder, bitcoin.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(20))), dust)
+			_, err := builder.BuildTx(context.Background(), grandparentOutpoint, to)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
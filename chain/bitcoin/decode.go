@@ -0,0 +1,129 @@
+package bitcoin
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+// DecodeTx returns the transaction encoded by the given bytes, in the format
+// produced by Serialize. Serialized transactions do not include the values, or
+// pubkey scripts, of the outputs that they spend, so these must be given as
+// inputs (in the same order as the transaction) for Sighashes to work. If no
+// inputs are given, they are recovered from the outpoints of the transaction
+// with a value of zero, and Sighashes will return an error. The transaction is
+// considered to be signed if any of its inputs have a signature script or a
+// witness.
+func DecodeTx(params *chaincfg.Params, data pack.Bytes, inputs []utxo.Input) (*Tx, error) {
+	msgTx := new(wire.MsgTx)
+	r := bytes.NewReader(data)
+	if err := msgTx.Deserialize(r); err != nil {
+		return nil, fmt.Errorf("bad tx: %v", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("bad tx: %v trailing bytes", r.Len())
+	}
+
+	inputs, err := InputsFromMsgTx(msgTx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := RecipientsFromMsgTx(msgTx, func(addr btcutil.Address) (address.Address, error) {
+		return address.Address(addr.EncodeAddress()), nil
+	}, params)
+	if err != nil {
+		return nil, err
+	}
+	for i, txOut := range msgTx.TxOut {
+		if IsPayToTaproot(txOut.PkScript) {
+			addr, err := NewAddressTaproot(txOut.PkScript[2:], params)
+			if err != nil {
+				return nil, fmt.Errorf("bad output %v: %v", i, err)
+			}
+			recipients[i].To = address.Address(addr.EncodeAddress())
+		}
+	}
+	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, signed: IsSigned(msgTx)}, nil
+}
+
+// InputsFromMsgTx returns the inputs spent by a decoded transaction. If inputs
+// are given, their outpoints must match the outpoints of the transaction. If no
+// inputs are given, they are recovered from the outpoints of the transaction
+// with a value of zero and no scripts. It can be used by any Bitcoin-family
+// chain that decodes transactions.
+func InputsFromMsgTx(msgTx *wire.MsgTx, inputs []utxo.Input) ([]utxo.Input, error) {
+	if inputs == nil {
+		inputs = make([]utxo.Input, len(msgTx.TxIn))
+		for i, txIn := range msgTx.TxIn {
+			inputs[i] = utxo.Input{
+				Output: utxo.Output{
+					Outpoint: utxo.Outpoint{
+						Hash:  pack.NewBytes(txIn.PreviousOutPoint.Hash[:]),
+						Index: pack.NewU32(txIn.PreviousOutPoint.Index),
+					},
+					Value: pack.NewU256FromU64(pack.NewU64(0)),
+				},
+			}
+		}
+		return inputs, nil
+	}
+
+	if len(inputs) != len(msgTx.TxIn) {
+		return nil, fmt.Errorf("expected %v inputs, got %v inputs", len(msgTx.TxIn), len(inputs))
+	}
+	for i, txIn := range msgTx.TxIn {
+		if !bytes.Equal(inputs[i].Outpoint.Hash, txIn.PreviousOutPoint.Hash[:]) || inputs[i].Outpoint.Index.Uint32() != txIn.PreviousOutPoint.Index {
+			return nil, fmt.Errorf("bad input %v: expected outpoint %v, got outpoint %x:%v", i, txIn.PreviousOutPoint, inputs[i].Outpoint.Hash, inputs[i].Outpoint.Index)
+		}
+	}
+	return inputs, nil
+}
+
+// RecipientsFromMsgTx returns the recipients paid by the outputs of a decoded
+// transaction, using the given function to encode the address of each output.
+// Outputs with pubkey scripts that do not pay exactly one address are returned
+// with an empty address, and null-data outputs are also returned with their
+// embedded data. It can be used by any Bitcoin-family chain that decodes
+// transactions.
+func RecipientsFromMsgTx(msgTx *wire.MsgTx, encode func(btcutil.Address) (address.Address, error), params *chaincfg.Params) ([]utxo.Recipient, error) {
+	recipients := make([]utxo.Recipient, len(msgTx.TxOut))
+	for i, txOut := range msgTx.TxOut {
+		if txOut.Value < 0 {
+			return nil, fmt.Errorf("bad output %v: value is less than zero", i)
+		}
+		recipients[i].Value = pack.NewU256FromU64(pack.NewU64(uint64(txOut.Value)))
+		if data, ok := NullData(txOut.PkScript); ok {
+			recipients[i].Data = data
+			continue
+		}
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, params)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+		to, err := encode(addrs[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad output %v: %v", i, err)
+		}
+		recipients[i].To = to
+	}
+	return recipients, nil
+}
+
+// IsSigned returns true if any of the inputs of the transaction have a
+// signature script or a witness.
+func IsSigned(msgTx *wire.MsgTx) bool {
+	for _, txIn := range msgTx.TxIn {
+		if len(txIn.SignatureScript) > 0 || len(txIn.Witness) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package bitcoin_test
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	wpkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	wpkhScript, err := txscript.PayToAddrScript(wpkhAddr)
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+			PubKeyScript: pack.NewBytes(wpkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(wpkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(100000))},
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(49000))},
+	}
+
+	Context("when decoding a signed transaction", func() {
+		It("should return the same transaction", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				hash := id.Hash(sighashes[i])
+				signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatures[i] = pack.NewBytes65(signature)
+			}
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := bitcoin.DecodeTx(params, serial, inputs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Serialize()).To(Equal(serial))
+			Expect(decoded.Inputs()).To(Equal(inputs))
+			Expect(decoded.Sighashes()).To(Equal(sighashes))
+			txHash, err := tx.Hash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Hash()).To(Equal(txHash))
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Outputs()).To(Equal(outputs))
+
+			// Decoded transactions are signed, so they cannot be signed again.
+			Expect(decoded.Sign(signatures, pubKey)).ToNot(Succeed())
+		})
+	})
+
+	Context("when decoding without inputs", func() {
+		It("should recover the outpoints", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := bitcoin.DecodeTx(params, serial, nil)
+			Expect(err).ToNot(HaveOccurred())
+			decodedInputs, err := decoded.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decodedInputs).To(HaveLen(len(inputs)))
+			for i := range inputs {
+				Expect(decodedInputs[i].Outpoint).To(Equal(inputs[i].Outpoint))
+			}
+			_, err = decoded.Sighashes()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when decoding with the wrong inputs", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = bitcoin.DecodeTx(params, serial, inputs[:1])
+			Expect(err).To(HaveOccurred())
+			_, err = bitcoin.DecodeTx(params, serial, []utxo.Input{inputs[1], inputs[0]})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when decoding malformed bytes", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = bitcoin.DecodeTx(params, serial[:len(serial)-1], inputs)
+			Expect(err).To(HaveOccurred())
+			_, err = bitcoin.DecodeTx(params, append(serial, 0), inputs)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
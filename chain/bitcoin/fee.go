@@ -0,0 +1,213 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+const (
+	// DefaultFeeGuardMaxFeeRatio used by the fee guard. It is the maximum
+	// fraction of the input value that can be paid as a fee.
+	DefaultFeeGuardMaxFeeRatio = 0.25
+)
+
+var (
+	// DefaultFeeGuardMaxFee used by the fee guard. This is the same as the
+	// default maximum fee of Bitcoin Core wallets (0.1 BTC).
+	DefaultFeeGuardMaxFee = pack.NewU256FromU64(pack.NewU64(10000000))
+	// DefaultFeeGuardMaxSatsPerByte used by the fee guard. This is the same as
+	// the default maximum fee rate of Bitcoin Core nodes (0.1 BTC/kvB).
+	DefaultFeeGuardMaxSatsPerByte = pack.NewU256FromU64(pack.NewU64(10000))
+)
+
+// FeeGuardOptions are used to parameterise the limits enforced by the fee
+// guard.
+type FeeGuardOptions struct {
+	MaxFee         pack.U256
+	MaxSatsPerByte pack.U256
+	MaxFeeRatio    float64
+	InputScript    InputScriptFunc
+}
+
+// DefaultFeeGuardOptions returns FeeGuardOptions with the default settings.
+func DefaultFeeGuardOptions() FeeGuardOptions {
+	return FeeGuardOptions{
+		MaxFee:         DefaultFeeGuardMaxFee,
+		MaxSatsPerByte: DefaultFeeGuardMaxSatsPerByte,
+		MaxFeeRatio:    DefaultFeeGuardMaxFeeRatio,
+		InputScript:    InputScriptFromInput,
+	}
+}
+
+// WithMaxFee sets the maximum absolute fee.
+func (opts FeeGuardOptions) WithMaxFee(maxFee pack.U256) FeeGuardOptions {
+	opts.MaxFee = maxFee
+	return opts
+}
+
+// WithMaxSatsPerByte sets the maximum fee rate.
+func (opts FeeGuardOptions) WithMaxSatsPerByte(maxSatsPerByte pack.U256) FeeGuardOptions {
+	opts.MaxSatsPerByte = maxSatsPerByte
+	return opts
+}
+
+// WithMaxFeeRatio sets the maximum fraction of the input value that can be
+// paid as a fee.
+func (opts FeeGuardOptions) WithMaxFeeRatio(maxFeeRatio float64) FeeGuardOptions {
+	opts.MaxFeeRatio = maxFeeRatio
+	return opts
+}
+
+// WithInputScript sets the function that returns the InputScript of inputs,
+// which is used to estimate the size of transactions after they have been
+// signed. By default, InputScripts are inferred from the pubkey scripts and
+// sig scripts of the inputs.
+func (opts FeeGuardOptions) WithInputScript(inputScript InputScriptFunc) FeeGuardOptions {
+	opts.InputScript = inputScript
+	return opts
+}
+
+// A FeeError is returned when the fee implied by a transaction (the difference
+// between the sum value of its inputs and the sum value of its outputs) is
+// outside of the limits enforced by the fee guard.
+type FeeError struct {
+	Inputs      pack.U256
+	Outputs     pack.U256
+	Fee         pack.U256
+	SatsPerByte pack.U256
+	Reason      string
+}
+
+// Error implements the error interface.
+func (err *FeeError) Error() string {
+	return fmt.Sprintf("bad fee: %v (inputs = %v, outputs = %v, sats per byte = %v)", err.Reason, err.Inputs, err.Outputs, err.SatsPerByte)
+}
+
+// CheckFee returns a FeeError if the fee implied by the transaction exceeds the
+// given limits. The virtual size of the transaction (after it has been signed)
+// is used to compute the fee rate. It can be used with transactions from any
+// Bitcoin-family chain.
+func CheckFee(tx utxo.Tx, vsize int, opts FeeGuardOptions) error {
+	inputs, err := tx.Inputs()
+	if err != nil {
+		return fmt.Errorf("bad inputs: %v", err)
+	}
+	outputs, err := tx.Outputs()
+	if err != nil {
+		return fmt.Errorf("bad outputs: %v", err)
+	}
+	if vsize <= 0 {
+		return fmt.Errorf("bad vsize: expected > 0, got %v", vsize)
+	}
+
+	in := new(big.Int)
+	for _, input := range inputs {
+		in.Add(in, input.Value.Int())
+	}
+	out := new(big.Int)
+	for _, output := range outputs {
+		out.Add(out, output.Value.Int())
+	}
+	if in.Cmp(out) < 0 {
+		return &FeeError{
+			Inputs:      pack.NewU256FromInt(in),
+			Outputs:     pack.NewU256FromInt(out),
+			Fee:         pack.NewU256FromU64(pack.NewU64(0)),
+			SatsPerByte: pack.NewU256FromU64(pack.NewU64(0)),
+			Reason:      "outputs exceed inputs",
+		}
+	}
+	fee := new(big.Int).Sub(in, out)
+	satsPerByte := new(big.Int).Div(fee, big.NewInt(int64(vsize)))
+	feeErr := func(reason string) error {
+		return &FeeError{
+			Inputs:      pack.NewU256FromInt(in),
+			Outputs:     pack.NewU256FromInt(out),
+			Fee:         pack.NewU256FromInt(fee),
+			SatsPerByte: pack.NewU256FromInt(satsPerByte),
+			Reason:      reason,
+		}
+	}
+
+	if fee.Cmp(opts.MaxFee.Int()) > 0 {
+		return feeErr(fmt.Sprintf("fee %v exceeds max fee %v", fee, opts.MaxFee))
+	}
+	if satsPerByte.Cmp(opts.MaxSatsPerByte.Int()) > 0 {
+		return feeErr(fmt.Sprintf("fee rate %v exceeds max sats per byte %v", satsPerByte, opts.MaxSatsPerByte))
+	}
+	maxFee := new(big.Float).Mul(new(big.Float).SetInt(in), big.NewFloat(opts.MaxFeeRatio))
+	if new(big.Float).SetInt(fee).Cmp(maxFee) > 0 {
+		return feeErr(fmt.Sprintf("fee %v exceeds max fee ratio %v of inputs", fee, opts.MaxFeeRatio))
+	}
+	return nil
+}
+
+// GuardedTxBuilder wraps a transaction builder, and refuses to build
+// transactions with fees that exceed the limits of the fee guard. The virtual
+// size of transactions is estimated from the InputScripts of their inputs.
+type GuardedTxBuilder struct {
+	txBuilder utxo.TxBuilder
+	opts      FeeGuardOptions
+}
+
+// NewGuardedTxBuilder returns a GuardedTxBuilder that wraps the given
+// transaction builder.
+func NewGuardedTxBuilder(txBuilder utxo.TxBuilder, opts FeeGuardOptions) GuardedTxBuilder {
+	return GuardedTxBuilder{txBuilder: txBuilder, opts: opts}
+}
+
+// BuildTx builds a transaction using the underlying transaction builder, and
+// returns a FeeError if the fee exceeds the limits of the fee guard.
+func (txBuilder GuardedTxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipient) (utxo.Tx, error) {
+	tx, err := txBuilder.txBuilder.BuildTx(inputs, recipients)
+	if err != nil {
+		return nil, err
+	}
+	inputScript := txBuilder.opts.InputScript
+	if inputScript == nil {
+		inputScript = InputScriptFromInput
+	}
+	scripts, err := inputScripts(inputs, inputScript)
+	if err != nil {
+		return nil, err
+	}
+	_, vsize, err := EstimateSize(tx, scripts)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckFee(tx, vsize, txBuilder.opts); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GuardedClient wraps a client, and refuses to submit transactions with fees
+// that exceed the limits of the fee guard. Transactions are expected to be
+// signed, so the serialized size (which is never less than the virtual size)
+// is used to compute the fee rate.
+type GuardedClient struct {
+	Client
+	opts FeeGuardOptions
+}
+
+// NewGuardedClient returns a GuardedClient that wraps the given client.
+func NewGuardedClient(client Client, opts FeeGuardOptions) GuardedClient {
+	return GuardedClient{Client: client, opts: opts}
+}
+
+// SubmitTx to the underlying client, unless the fee exceeds the limits of the
+// fee guard, in which case a FeeError is returned.
+func (client GuardedClient) SubmitTx(ctx context.Context, tx utxo.Tx) error {
+	serial, err := tx.Serialize()
+	if err != nil {
+		return fmt.Errorf("bad tx: %v", err)
+	}
+	if err := CheckFee(tx, len(serial), client.opts); err != nil {
+		return err
+	}
+	return client.Client.SubmitTx(ctx, tx)
+}
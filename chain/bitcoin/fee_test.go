@@ -0,0 +1,141 @@
+package bitcoin_test
+
+import (
+	"context"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockClient is a client that records submitted transactions.
+type mockClient struct {
+	bitcoin.Client
+	submitted []utxo.Tx
+}
+
+func (client *mockClient) SubmitTx(ctx context.Context, tx utxo.Tx) error {
+	client.submitted = append(client.submitted, tx)
+	return nil
+}
+
+var _ = Describe("Fee guard", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+	opts := bitcoin.DefaultFeeGuardOptions()
+
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		panic(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		panic(err)
+	}
+	inputs := []utxo.Input{{Output: utxo.Output{
+		Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+		Value:        pack.NewU256FromU64(pack.NewU64(1000000)),
+		PubKeyScript: pack.NewBytes(script),
+	}}}
+	recipients := func(value uint64) []utxo.Recipient {
+		return []utxo.Recipient{{To: address.Address(addr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(value))}}
+	}
+
+	Context("when the fee is within the limits", func() {
+		It("should build and submit the transaction", func() {
+			tx, err := bitcoin.NewGuardedTxBuilder(txBuilder, opts).BuildTx(inputs, recipients(998000))
+			Expect(err).ToNot(HaveOccurred())
+
+			client := &mockClient{}
+			Expect(bitcoin.NewGuardedClient(client, opts).SubmitTx(context.Background(), tx)).To(Succeed())
+			Expect(client.submitted).To(HaveLen(1))
+		})
+	})
+
+	Context("when the fee exceeds the limits", func() {
+		It("should return a fee error", func() {
+			for _, test := range []struct {
+				value uint64
+				opts  bitcoin.FeeGuardOptions
+			}{
+				// Absolute fee.
+				{900000, opts.WithMaxFee(pack.NewU256FromU64(pack.NewU64(50000)))},
+				// Fee rate.
+				{900000, opts.WithMaxSatsPerByte(pack.NewU256FromU64(pack.NewU64(100)))},
+				// Fee ratio.
+				{700000, opts},
+			} {
+				_, err := bitcoin.NewGuardedTxBuilder(txBuilder, test.opts).BuildTx(inputs, recipients(test.value))
+				Expect(err).To(BeAssignableToTypeOf(&bitcoin.FeeError{}))
+				Expect(err.(*bitcoin.FeeError).Fee).To(Equal(pack.NewU256FromU64(pack.NewU64(1000000 - test.value))))
+			}
+		})
+
+		It("should not submit the transaction", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients(1000))
+			Expect(err).ToNot(HaveOccurred())
+
+			client := &mockClient{}
+			err = bitcoin.NewGuardedClient(client, opts).SubmitTx(context.Background(), tx)
+			Expect(err).To(BeAssignableToTypeOf(&bitcoin.FeeError{}))
+			Expect(client.submitted).To(BeEmpty())
+		})
+	})
+
+	Context("when the inputs spend a multisig script", func() {
+		It("should estimate the size of the inputs from their sig scripts", func() {
+			pubKeys := make([]*btcutil.AddressPubKey, 3)
+			for i := range pubKeys {
+				pubKey, err := btcutil.NewAddressPubKey((*btcec.PublicKey)(&id.NewPrivKey().PublicKey).SerializeCompressed(), params)
+				Expect(err).ToNot(HaveOccurred())
+				pubKeys[i] = pubKey
+			}
+			multisigScript, err := txscript.MultiSigScript(pubKeys, 2)
+			Expect(err).ToNot(HaveOccurred())
+			shAddr, err := btcutil.NewAddressScriptHash(multisigScript, params)
+			Expect(err).ToNot(HaveOccurred())
+			shScript, err := txscript.PayToAddrScript(shAddr)
+			Expect(err).ToNot(HaveOccurred())
+			multisigInputs := []utxo.Input{{
+				Output: utxo.Output{
+					Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+					Value:        pack.NewU256FromU64(pack.NewU64(1000000)),
+					PubKeyScript: pack.NewBytes(shScript),
+				},
+				SigScript: multisigScript,
+			}}
+
+			// The signed transaction is more than 300 bytes, so a fee of 20000
+			// is less than 100 SATs-per-byte. If the input was assumed to be
+			// a nested P2WPKH input, then the fee rate would be too high.
+			guardOpts := opts.WithMaxSatsPerByte(pack.NewU256FromU64(pack.NewU64(100)))
+			_, err = bitcoin.NewGuardedTxBuilder(txBuilder, guardOpts).BuildTx(multisigInputs, recipients(980000))
+			Expect(err).ToNot(HaveOccurred())
+
+			guardOpts = guardOpts.WithInputScript(func(utxo.Input) (bitcoin.InputScript, error) {
+				return bitcoin.InputScript{Type: bitcoin.ScriptP2SHP2WPKH}, nil
+			})
+			_, err = bitcoin.NewGuardedTxBuilder(txBuilder, guardOpts).BuildTx(multisigInputs, recipients(980000))
+			Expect(err).To(BeAssignableToTypeOf(&bitcoin.FeeError{}))
+		})
+	})
+
+	Context("when the outputs exceed the inputs", func() {
+		It("should return a fee error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients(1000001))
+			Expect(err).ToNot(HaveOccurred())
+			err = bitcoin.CheckFee(tx, 200, opts)
+			Expect(err).To(BeAssignableToTypeOf(&bitcoin.FeeError{}))
+			Expect(err.(*bitcoin.FeeError).Outputs).To(Equal(pack.NewU256FromU64(pack.NewU64(1000001))))
+		})
+	})
+})
@@ -2,10 +2,103 @@ package bitcoin
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/btcsuite/btcutil"
 	"github.com/renproject/pack"
 )
 
+const (
+	// DefaultGasEstimatorConfTarget used by the GasEstimator. It is the number
+	// of blocks within which transactions are expected to confirm.
+	DefaultGasEstimatorConfTarget = int64(1)
+	// DefaultGasEstimatorMode used by the GasEstimator.
+	DefaultGasEstimatorMode = EstimateModeConservative
+)
+
+var (
+	// DefaultGasEstimatorMinSatsPerByte used by the GasEstimator. This is the
+	// minimum relay fee of most nodes.
+	DefaultGasEstimatorMinSatsPerByte = pack.NewU256FromU64(pack.NewU64(1))
+	// DefaultGasEstimatorMaxSatsPerByte used by the GasEstimator. By default,
+	// there is no ceiling.
+	DefaultGasEstimatorMaxSatsPerByte = pack.MaxU256
+)
+
+// EstimateMode is used by the "estimatesmartfee" method to decide how
+// conservative it should be when estimating fees.
+type EstimateMode string
+
+// Enumeration of supported estimate modes.
+const (
+	// EstimateModeUnset omits the mode when calling "estimatesmartfee". This is
+	// needed for nodes that do not support estimate modes (for example, nodes
+	// based on Bitcoin Core v0.14 and earlier).
+	EstimateModeUnset = EstimateMode("")
+	// EstimateModeEconomical estimates fees using a shorter history, and is
+	// more responsive to short-term drops in fees.
+	EstimateModeEconomical = EstimateMode("ECONOMICAL")
+	// EstimateModeConservative estimates fees using a longer history, and is
+	// less likely to under-estimate fees.
+	EstimateModeConservative = EstimateMode("CONSERVATIVE")
+)
+
+// EstimateSmartFeeResult is the result of the "estimatesmartfee" method. The
+// fee rate is in BTC-per-kilobyte (or, for segwit nodes, BTC-per-kilo-vbyte),
+// and is nil when the node does not have enough data to estimate fees.
+type EstimateSmartFeeResult struct {
+	FeeRate *float64 `json:"feerate,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Blocks  int64    `json:"blocks"`
+}
+
+// GasEstimatorOptions are used to parameterise the behaviour of GasEstimators
+// that are backed by a node. A zero MaxSatsPerByte means that there is no
+// ceiling.
+type GasEstimatorOptions struct {
+	ConfTarget     int64
+	Mode           EstimateMode
+	MinSatsPerByte pack.U256
+	MaxSatsPerByte pack.U256
+}
+
+// DefaultGasEstimatorOptions returns GasEstimatorOptions with the default
+// settings.
+func DefaultGasEstimatorOptions() GasEstimatorOptions {
+	return GasEstimatorOptions{
+		ConfTarget:     DefaultGasEstimatorConfTarget,
+		Mode:           DefaultGasEstimatorMode,
+		MinSatsPerByte: DefaultGasEstimatorMinSatsPerByte,
+		MaxSatsPerByte: DefaultGasEstimatorMaxSatsPerByte,
+	}
+}
+
+// WithConfTarget sets the number of blocks within which transactions are
+// expected to confirm.
+func (opts GasEstimatorOptions) WithConfTarget(confTarget int64) GasEstimatorOptions {
+	opts.ConfTarget = confTarget
+	return opts
+}
+
+// WithMode sets the estimate mode that will be used by the node.
+func (opts GasEstimatorOptions) WithMode(mode EstimateMode) GasEstimatorOptions {
+	opts.Mode = mode
+	return opts
+}
+
+// WithMinSatsPerByte sets the floor of estimated SATs-per-byte.
+func (opts GasEstimatorOptions) WithMinSatsPerByte(satsPerByte pack.U256) GasEstimatorOptions {
+	opts.MinSatsPerByte = satsPerByte
+	return opts
+}
+
+// WithMaxSatsPerByte sets the ceiling of estimated SATs-per-byte. A zero
+// ceiling means that there is no ceiling.
+func (opts GasEstimatorOptions) WithMaxSatsPerByte(satsPerByte pack.U256) GasEstimatorOptions {
+	opts.MaxSatsPerByte = satsPerByte
+	return opts
+}
+
 // A GasEstimator returns the SATs-per-byte that is needed in order to confirm
 // transactions with an estimated maximum delay of one block. In distributed
 // networks that collectively build, sign, and submit transactions, it is
@@ -13,6 +106,9 @@ import (
 // SATs-per-byte.
 type GasEstimator struct {
 	satsPerByte pack.U256
+
+	client Client
+	opts   GasEstimatorOptions
 }
 
 // NewGasEstimator returns a simple gas estimator that always returns the given
@@ -23,10 +119,53 @@ func NewGasEstimator(satsPerByte pack.U256) GasEstimator {
 	}
 }
 
+// NewSmartGasEstimator returns a gas estimator that uses the "estimatesmartfee"
+// method of the node to estimate the SATs-per-byte. When the node does not have
+// enough data to estimate fees (for example, because it has only recently
+// started), the given number of SATs-per-byte is used instead. In both cases,
+// the SATs-per-byte is bounded by the floor and ceiling in the options.
+func NewSmartGasEstimator(client Client, satsPerByte pack.U256, opts GasEstimatorOptions) GasEstimator {
+	return GasEstimator{
+		satsPerByte: satsPerByte,
+		client:      client,
+		opts:        opts,
+	}
+}
+
 // EstimateGasPrice returns the number of SATs-per-byte that is needed in order
 // to confirm transactions with an estimated maximum delay of one block. It is
 // the responsibility of the caller to know the number of bytes in their
 // transaction.
-func (gasEstimator GasEstimator) EstimateGasPrice(_ context.Context) (pack.U256, error) {
-	return gasEstimator.satsPerByte, nil
+func (gasEstimator GasEstimator) EstimateGasPrice(ctx context.Context) (pack.U256, error) {
+	if gasEstimator.client == nil {
+		return gasEstimator.satsPerByte, nil
+	}
+
+	resp, err := gasEstimator.client.EstimateSmartFee(ctx, gasEstimator.opts.ConfTarget, gasEstimator.opts.Mode)
+	if err != nil {
+		return pack.U256{}, err
+	}
+	satsPerByte := gasEstimator.satsPerByte
+	if resp.FeeRate != nil && *resp.FeeRate > 0 {
+		satsPerKB, err := btcutil.NewAmount(*resp.FeeRate)
+		if err != nil {
+			return pack.U256{}, fmt.Errorf("bad fee rate: %v", err)
+		}
+		// Round up, so that the estimated SATs-per-byte is never less than the
+		// fee rate estimated by the node.
+		satsPerByte = pack.NewU256FromU64(pack.NewU64((uint64(satsPerKB) + 999) / 1000))
+	}
+	return gasEstimator.clamp(satsPerByte), nil
+}
+
+// clamp the SATs-per-byte to the floor and ceiling in the options. A zero
+// floor, or a zero ceiling, is ignored.
+func (gasEstimator GasEstimator) clamp(satsPerByte pack.U256) pack.U256 {
+	if min := gasEstimator.opts.MinSatsPerByte; !min.Equal(pack.U256{}) && satsPerByte.Int().Cmp(min.Int()) < 0 {
+		return min
+	}
+	if max := gasEstimator.opts.MaxSatsPerByte; !max.Equal(pack.U256{}) && satsPerByte.Int().Cmp(max.Int()) > 0 {
+		return max
+	}
+	return satsPerByte
 }
@@ -1 +1,113 @@
 package bitcoin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newEstimateSmartFeeServer returns a server that responds to every
+// "estimatesmartfee" request with the given result, and records the params of
+// the most recent request.
+func newEstimateSmartFeeServer(result string, params *[]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     int           `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "estimatesmartfee" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*params = req.Params
+		res := struct {
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *string         `json:"error"`
+		}{
+			ID:     req.ID,
+			Result: json.RawMessage(result),
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+var _ = Describe("Gas", func() {
+	Context("when estimating gas using a static value", func() {
+		It("should return the static value", func() {
+			gasEstimator := bitcoin.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(10)))
+			satsPerByte, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(10))))
+		})
+	})
+
+	Context("when estimating gas using the node", func() {
+		fallback := pack.NewU256FromU64(pack.NewU64(10))
+
+		estimate := func(result string, opts bitcoin.GasEstimatorOptions) (pack.U256, []interface{}) {
+			params := []interface{}{}
+			server := newEstimateSmartFeeServer(result, &params)
+			defer server.Close()
+
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL))
+			gasEstimator := bitcoin.NewSmartGasEstimator(client, fallback, opts)
+			satsPerByte, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			return satsPerByte, params
+		}
+
+		It("should convert BTC-per-kilobyte into SATs-per-byte", func() {
+			satsPerByte, params := estimate(`{"feerate":0.00020000,"blocks":2}`, bitcoin.DefaultGasEstimatorOptions())
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(20))))
+			Expect(params).To(Equal([]interface{}{float64(1), "CONSERVATIVE"}))
+		})
+
+		It("should round up to the nearest SAT-per-byte", func() {
+			satsPerByte, _ := estimate(`{"feerate":0.00020001,"blocks":2}`, bitcoin.DefaultGasEstimatorOptions())
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(21))))
+		})
+
+		It("should use the confirmation target and mode from the options", func() {
+			opts := bitcoin.DefaultGasEstimatorOptions().
+				WithConfTarget(6).
+				WithMode(bitcoin.EstimateModeEconomical)
+			_, params := estimate(`{"feerate":0.00020000,"blocks":6}`, opts)
+			Expect(params).To(Equal([]interface{}{float64(6), "ECONOMICAL"}))
+
+			_, params = estimate(`{"feerate":0.00020000,"blocks":6}`, opts.WithMode(bitcoin.EstimateModeUnset))
+			Expect(params).To(Equal([]interface{}{float64(6)}))
+		})
+
+		It("should fall back to the static value when the node has insufficient data", func() {
+			satsPerByte, _ := estimate(`{"errors":["Insufficient data or no feerate found"],"blocks":0}`, bitcoin.DefaultGasEstimatorOptions())
+			Expect(satsPerByte).To(Equal(fallback))
+		})
+
+		It("should enforce the floor and ceiling", func() {
+			opts := bitcoin.DefaultGasEstimatorOptions().
+				WithMinSatsPerByte(pack.NewU256FromU64(pack.NewU64(5))).
+				WithMaxSatsPerByte(pack.NewU256FromU64(pack.NewU64(50)))
+
+			satsPerByte, _ := estimate(`{"feerate":0.00001000,"blocks":2}`, opts)
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(5))))
+
+			satsPerByte, _ = estimate(`{"feerate":0.01000000,"blocks":2}`, opts)
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(50))))
+		})
+
+		It("should not enforce a zero ceiling", func() {
+			satsPerByte, params := estimate(`{"feerate":0.00020000,"blocks":2}`, bitcoin.GasEstimatorOptions{})
+			Expect(satsPerByte).To(Equal(pack.NewU256FromU64(pack.NewU64(20))))
+			Expect(params).To(Equal([]interface{}{float64(0)}))
+		})
+	})
+})
@@ -0,0 +1,227 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+)
+
+// marshalledTx is the representation of a Tx that is used for binary and JSON
+// marshalling. The wire transaction is encoded without witnesses (this avoids
+// ambiguity when decoding transactions with no inputs), and the witnesses are
+// encoded separately.
+type marshalledTx struct {
+	Inputs       []utxo.Input             `json:"inputs"`
+	Recipients   []utxo.Recipient         `json:"recipients"`
+	MsgTx        pack.Bytes               `json:"msgTx"`
+	Witnesses    [][]pack.Bytes           `json:"witnesses"`
+	PartialSigs  [][]marshalledPartialSig `json:"partialSigs"`
+	SigHashTypes []utxo.SigHashType       `json:"sigHashTypes"`
+	Signed       bool                     `json:"signed"`
+}
+
+// marshalledPartialSig is the representation of a partial signature that is
+// used for binary and JSON marshalling.
+type marshalledPartialSig struct {
+	PubKey    pack.Bytes `json:"pubKey"`
+	Signature pack.Bytes `json:"signature"`
+}
+
+// SizeHint returns the number of bytes required to represent the transaction
+// in binary.
+func (tx *Tx) SizeHint() int {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return 0
+	}
+	return surge.SizeHint(marshalled)
+}
+
+// Marshal the transaction to binary. Inputs, recipients, partial signatures,
+// sighash types, and whether or not the transaction is signed, are all
+// preserved.
+func (tx *Tx) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(marshalled, buf, rem)
+}
+
+// Unmarshal the transaction from binary.
+func (tx *Tx) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	marshalled := marshalledTx{}
+	buf, rem, err := surge.Unmarshal(&marshalled, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if err := tx.unmarshalled(marshalled); err != nil {
+		return buf, rem, err
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface.
+func (tx *Tx) MarshalJSON() ([]byte, error) {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(marshalled)
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface.
+func (tx *Tx) UnmarshalJSON(data []byte) error {
+	marshalled := marshalledTx{}
+	if err := json.Unmarshal(data, &marshalled); err != nil {
+		return err
+	}
+	return tx.unmarshalled(marshalled)
+}
+
+func (tx *Tx) marshalled() (marshalledTx, error) {
+	msgTx, witnesses, err := EncodeMsgTx(tx.msgTx)
+	if err != nil {
+		return marshalledTx{}, err
+	}
+	partialSigs := make([][]marshalledPartialSig, len(tx.partialSigs))
+	for i := range tx.partialSigs {
+		partialSigs[i] = make([]marshalledPartialSig, len(tx.partialSigs[i]))
+		for j, partialSig := range tx.partialSigs[i] {
+			partialSigs[i][j] = marshalledPartialSig{
+				PubKey:    pack.NewBytes(partialSig.PubKey),
+				Signature: pack.NewBytes(partialSig.Signature),
+			}
+		}
+	}
+	return marshalledTx{
+		Inputs:       tx.inputs,
+		Recipients:   tx.recipients,
+		MsgTx:        msgTx,
+		Witnesses:    witnesses,
+		PartialSigs:  partialSigs,
+		SigHashTypes: tx.sigHashTypes,
+		Signed:       tx.signed,
+	}, nil
+}
+
+func (tx *Tx) unmarshalled(marshalled marshalledTx) error {
+	msgTx, err := DecodeMsgTx(marshalled.MsgTx, marshalled.Witnesses)
+	if err != nil {
+		return err
+	}
+	if len(marshalled.Inputs) != len(msgTx.TxIn) {
+		return fmt.Errorf("expected %v inputs, got %v inputs", len(msgTx.TxIn), len(marshalled.Inputs))
+	}
+	var partialSigs [][]*psbt.PartialSig
+	if len(marshalled.PartialSigs) > 0 {
+		if len(marshalled.PartialSigs) != len(msgTx.TxIn) {
+			return fmt.Errorf("expected %v partial signatures, got %v partial signatures", len(msgTx.TxIn), len(marshalled.PartialSigs))
+		}
+		partialSigs = make([][]*psbt.PartialSig, len(marshalled.PartialSigs))
+		for i := range marshalled.PartialSigs {
+			for _, partialSig := range marshalled.PartialSigs[i] {
+				partialSigs[i] = append(partialSigs[i], &psbt.PartialSig{
+					PubKey:    partialSig.PubKey,
+					Signature: partialSig.Signature,
+				})
+			}
+		}
+	}
+	if len(marshalled.SigHashTypes) > 0 && len(marshalled.SigHashTypes) != len(msgTx.TxIn) {
+		return fmt.Errorf("expected %v sighash types, got %v sighash types", len(msgTx.TxIn), len(marshalled.SigHashTypes))
+	}
+	for i, sigHashType := range marshalled.SigHashTypes {
+		if sigHashType != utxo.DefaultSigHashType {
+			if err := CheckSigHashType(txscript.SigHashType(sigHashType)); err != nil {
+				return fmt.Errorf("bad input %v: %v", i, err)
+			}
+		}
+	}
+	tx.inputs = NormaliseInputs(marshalled.Inputs)
+	tx.recipients = NormaliseRecipients(marshalled.Recipients)
+	tx.msgTx = msgTx
+	tx.partialSigs = partialSigs
+	tx.sigHashTypes = marshalled.SigHashTypes
+	tx.signed = marshalled.Signed
+	return nil
+}
+
+// EncodeMsgTx returns the wire transaction encoded without witnesses, and the
+// witness of each input. Encoding witnesses separately avoids the ambiguity
+// between the segwit marker and transactions with no inputs. It can be used by
+// any Bitcoin-family chain that marshals transactions.
+func EncodeMsgTx(msgTx *wire.MsgTx) (pack.Bytes, [][]pack.Bytes, error) {
+	buf := new(bytes.Buffer)
+	if err := msgTx.BtcEncode(buf, 0, wire.BaseEncoding); err != nil {
+		return pack.Bytes{}, nil, fmt.Errorf("encoding tx: %v", err)
+	}
+	witnesses := make([][]pack.Bytes, len(msgTx.TxIn))
+	for i, txIn := range msgTx.TxIn {
+		witnesses[i] = make([]pack.Bytes, len(txIn.Witness))
+		for j := range txIn.Witness {
+			witnesses[i][j] = pack.NewBytes(txIn.Witness[j])
+		}
+	}
+	return pack.NewBytes(buf.Bytes()), witnesses, nil
+}
+
+// DecodeMsgTx returns the wire transaction encoded by EncodeMsgTx.
+func DecodeMsgTx(data pack.Bytes, witnesses [][]pack.Bytes) (*wire.MsgTx, error) {
+	msgTx := new(wire.MsgTx)
+	r := bytes.NewReader(data)
+	if err := msgTx.BtcDecode(r, 0, wire.BaseEncoding); err != nil {
+		return nil, fmt.Errorf("decoding tx: %v", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("decoding tx: %v trailing bytes", r.Len())
+	}
+	if len(witnesses) > 0 {
+		if len(witnesses) != len(msgTx.TxIn) {
+			return nil, fmt.Errorf("expected %v witnesses, got %v witnesses", len(msgTx.TxIn), len(witnesses))
+		}
+		for i := range witnesses {
+			if len(witnesses[i]) == 0 {
+				continue
+			}
+			msgTx.TxIn[i].Witness = make(wire.TxWitness, len(witnesses[i]))
+			for j := range witnesses[i] {
+				msgTx.TxIn[i].Witness[j] = witnesses[i][j]
+			}
+		}
+	}
+	return msgTx, nil
+}
+
+// NormaliseInputs returns the inputs with empty sig scripts replaced by nil
+// sig scripts. Unmarshalling does not distinguish between the two, but
+// signing does.
+func NormaliseInputs(inputs []utxo.Input) []utxo.Input {
+	for i := range inputs {
+		if len(inputs[i].SigScript) == 0 {
+			inputs[i].SigScript = nil
+		}
+		if len(inputs[i].Data) == 0 {
+			inputs[i].Data = nil
+		}
+	}
+	return inputs
+}
+
+// NormaliseRecipients returns the recipients with empty data replaced by nil
+// data, so that recipients are the same before and after being marshalled.
+func NormaliseRecipients(recipients []utxo.Recipient) []utxo.Recipient {
+	for i := range recipients {
+		if len(recipients[i].Data) == 0 {
+			recipients[i].Data = nil
+		}
+	}
+	return recipients
+}
@@ -0,0 +1,141 @@
+package bitcoin_test
+
+import (
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Marshalling", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	wpkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	wpkhScript, err := txscript.PayToAddrScript(wpkhAddr)
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+			PubKeyScript: pack.NewBytes(wpkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(wpkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(100000))},
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(49000))},
+	}
+	sign := func(tx utxo.Tx) []pack.Bytes65 {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		signatures := make([]pack.Bytes65, len(sighashes))
+		for i := range sighashes {
+			hash := id.Hash(sighashes[i])
+			signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures[i] = pack.NewBytes65(signature)
+		}
+		return signatures
+	}
+
+	// roundTrip marshals and unmarshals the transaction to binary and JSON, and
+	// returns both results.
+	roundTrip := func(tx *bitcoin.Tx) []*bitcoin.Tx {
+		data, err := surge.ToBinary(tx)
+		Expect(err).ToNot(HaveOccurred())
+		fromBinary := new(bitcoin.Tx)
+		Expect(surge.FromBinary(fromBinary, data)).To(Succeed())
+
+		data, err = json.Marshal(tx)
+		Expect(err).ToNot(HaveOccurred())
+		fromJSON := new(bitcoin.Tx)
+		Expect(json.Unmarshal(data, fromJSON)).To(Succeed())
+
+		return []*bitcoin.Tx{fromBinary, fromJSON}
+	}
+
+	Context("when marshalling an unsigned transaction", func() {
+		It("should preserve partial signatures", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := sign(tx)
+			Expect(tx.(*bitcoin.Tx).AddPartialSig(0, signatures[0], pubKey)).To(Succeed())
+			psbt, err := tx.(*bitcoin.Tx).EncodePSBT()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, unmarshalled := range roundTrip(tx.(*bitcoin.Tx)) {
+				Expect(unmarshalled.Inputs()).To(Equal(inputs))
+				Expect(unmarshalled.SignalsReplaceByFee()).To(BeTrue())
+				Expect(unmarshalled.EncodePSBT()).To(Equal(psbt))
+
+				// The remaining signature can be added after unmarshalling.
+				Expect(unmarshalled.AddPartialSig(1, signatures[1], pubKey)).To(Succeed())
+				Expect(unmarshalled.Finalize()).To(Succeed())
+			}
+		})
+	})
+
+	Context("when marshalling a signed transaction", func() {
+		It("should preserve the signatures and witnesses", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := sign(tx)
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, unmarshalled := range roundTrip(tx.(*bitcoin.Tx)) {
+				Expect(unmarshalled.Serialize()).To(Equal(serial))
+				Expect(unmarshalled.Sign(signatures, pubKey)).ToNot(Succeed())
+			}
+		})
+	})
+
+	Context("when marshalling a transaction with no inputs", func() {
+		It("should unmarshal the same transaction", func() {
+			tx, err := txBuilder.BuildTx(nil, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, unmarshalled := range roundTrip(tx.(*bitcoin.Tx)) {
+				Expect(unmarshalled.Serialize()).To(Equal(serial))
+			}
+		})
+	})
+})
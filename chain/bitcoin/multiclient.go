@@ -0,0 +1,472 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+const (
+	// DefaultMultiClientQuorum used by the MultiClient. It is the number of
+	// nodes that must agree on the result of a safety-critical read.
+	DefaultMultiClientQuorum = 1
+	// DefaultMultiClientHealthCheckInterval used by the MultiClient.
+	DefaultMultiClientHealthCheckInterval = 30 * time.Second
+)
+
+// MultiClientOptions are used to parameterise the behaviour of the
+// MultiClient.
+type MultiClientOptions struct {
+	Quorum              int
+	HealthCheckInterval time.Duration
+}
+
+// DefaultMultiClientOptions returns MultiClientOptions with the default
+// settings.
+func DefaultMultiClientOptions() MultiClientOptions {
+	return MultiClientOptions{
+		Quorum:              DefaultMultiClientQuorum,
+		HealthCheckInterval: DefaultMultiClientHealthCheckInterval,
+	}
+}
+
+// WithQuorum sets the number of nodes that must agree on the result of a
+// safety-critical read. A quorum of one disables quorum reads.
+func (opts MultiClientOptions) WithQuorum(quorum int) MultiClientOptions {
+	opts.Quorum = quorum
+	return opts
+}
+
+// WithHealthCheckInterval sets the duration between health checks.
+func (opts MultiClientOptions) WithHealthCheckInterval(interval time.Duration) MultiClientOptions {
+	opts.HealthCheckInterval = interval
+	return opts
+}
+
+// A MultiClient is a Client that sends requests to several Bitcoin nodes,
+// usually run by different providers. Requests are sent to healthy nodes
+// first, and fail over to the next node when they fail. A node becomes
+// unhealthy when a request to it fails, and healthy again when it passes a
+// health check (see Run). Transactions are submitted to all nodes.
+//
+// When the quorum is greater than one, outputs and confirmations are read from
+// all nodes, and at least a quorum of nodes must agree on the output. The
+// number of confirmations returned is the largest number that is reported, or
+// exceeded, by a quorum of nodes.
+type MultiClient struct {
+	opts    MultiClientOptions
+	clients []Client
+
+	healthyMu *sync.RWMutex
+	healthy   []bool
+}
+
+// NewMultiClient returns a MultiClient that sends requests to the given
+// clients, each of which is usually connected to a different node. All
+// clients are assumed to be healthy until a request to them fails. An error is
+// returned if the quorum is not between one and the number of clients.
+func NewMultiClient(opts MultiClientOptions, clients ...Client) (*MultiClient, error) {
+	if opts.Quorum < 1 || opts.Quorum > len(clients) {
+		return nil, fmt.Errorf("bad quorum: expected 1 <= quorum <= %v, got quorum = %v", len(clients), opts.Quorum)
+	}
+	healthy := make([]bool, len(clients))
+	for i := range healthy {
+		healthy[i] = true
+	}
+	return &MultiClient{
+		opts:    opts,
+		clients: clients,
+
+		healthyMu: new(sync.RWMutex),
+		healthy:   healthy,
+	}, nil
+}
+
+// Run health checks every health check interval, until the context is done.
+func (client *MultiClient) Run(ctx context.Context) {
+	interval := client.opts.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultMultiClientHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		client.HealthCheck(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthCheck requests the block count from every node, and returns the number
+// of nodes that are healthy. Nodes that respond are healthy, and all other
+// nodes are unhealthy.
+func (client *MultiClient) HealthCheck(ctx context.Context) int {
+	client.all(func(i int, c Client) error {
+		_, err := c.BlockCount(ctx)
+		client.setHealthy(i, err == nil)
+		return err
+	})
+	return len(client.Healthy())
+}
+
+// Healthy returns the indices of the clients that are healthy.
+func (client *MultiClient) Healthy() []int {
+	client.healthyMu.RLock()
+	defer client.healthyMu.RUnlock()
+
+	healthy := []int{}
+	for i := range client.healthy {
+		if client.healthy[i] {
+			healthy = append(healthy, i)
+		}
+	}
+	return healthy
+}
+
+// Output associated with an outpoint, and its number of confirmations. When
+// the quorum is greater than one, a quorum of nodes must agree on the output,
+// and ErrTxNotFound is returned if a quorum of nodes cannot find it.
+func (client *MultiClient) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.Output, pack.U64, error) {
+	if client.opts.Quorum <= 1 {
+		output, confs := utxo.Output{}, pack.NewU64(0)
+		err := client.failover(ctx, func(c Client) (err error) {
+			output, confs, err = c.Output(ctx, outpoint)
+			return err
+		})
+		return output, confs, err
+	}
+
+	outputs := make([]utxo.Output, len(client.clients))
+	confs := make([]pack.U64, len(client.clients))
+	errs := client.all(func(i int, c Client) (err error) {
+		outputs[i], confs[i], err = c.Output(ctx, outpoint)
+		client.report(i, err)
+		return err
+	})
+	return client.quorumOutput(outputs, confs, errs)
+}
+
+// SubmitTx to all nodes. Submission succeeds if at least one node accepts the
+// transaction.
+func (client *MultiClient) SubmitTx(ctx context.Context, tx utxo.Tx) error {
+	errs := client.all(func(i int, c Client) error {
+		err := c.SubmitTx(ctx, tx)
+		client.report(i, err)
+		return err
+	})
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("bad clients: expected at least one client")
+	}
+	return errs[0]
+}
+
+// UnspentOutputs spendable by the given address.
+func (client *MultiClient) UnspentOutputs(ctx context.Context, minConf, maxConf int64, address address.Address) ([]utxo.Output, error) {
+	outputs := []utxo.Output{}
+	err := client.failover(ctx, func(c Client) (err error) {
+		outputs, err = c.UnspentOutputs(ctx, minConf, maxConf, address)
+		return err
+	})
+	return outputs, err
+}
+
+// Confirmations of a transaction in the Bitcoin network. When the quorum is
+// greater than one, a quorum of nodes must know about the transaction.
+func (client *MultiClient) Confirmations(ctx context.Context, txHash pack.Bytes) (int64, error) {
+	return client.confirmations(ctx, func(c Client) (int64, error) {
+		return c.Confirmations(ctx, txHash)
+	})
+}
+
+// ConfirmationsInBlock of a transaction in the given block. When the quorum is
+// greater than one, a quorum of nodes must know about the transaction.
+func (client *MultiClient) ConfirmationsInBlock(ctx context.Context, txHash, blockHash pack.Bytes) (int64, error) {
+	return client.confirmations(ctx, func(c Client) (int64, error) {
+		return c.ConfirmationsInBlock(ctx, txHash, blockHash)
+	})
+}
+
+// EstimateSmartFee returns the fee rate that is needed in order for a
+// transaction to confirm within the given number of blocks.
+func (client *MultiClient) EstimateSmartFee(ctx context.Context, confTarget int64, mode EstimateMode) (EstimateSmartFeeResult, error) {
+	resp := EstimateSmartFeeResult{}
+	err := client.failover(ctx, func(c Client) (err error) {
+		resp, err = c.EstimateSmartFee(ctx, confTarget, mode)
+		return err
+	})
+	return resp, err
+}
+
+// RawTransaction returns the verbose representation of a transaction in the
+// Bitcoin network.
+func (client *MultiClient) RawTransaction(ctx context.Context, txHash pack.Bytes) (btcjson.TxRawResult, error) {
+	resp := btcjson.TxRawResult{}
+	err := client.failover(ctx, func(c Client) (err error) {
+		resp, err = c.RawTransaction(ctx, txHash)
+		return err
+	})
+	return resp, err
+}
+
+// BlockCount returns the height of the most recent block in the longest chain
+// known by the first healthy node.
+func (client *MultiClient) BlockCount(ctx context.Context) (int64, error) {
+	resp := int64(0)
+	err := client.failover(ctx, func(c Client) (err error) {
+		resp, err = c.BlockCount(ctx)
+		return err
+	})
+	return resp, err
+}
+
+// BatchOutputs returns the output associated with each outpoint, and its
+// number of confirmations, using one batch of requests for each node. When the
+// quorum is greater than one, a quorum of nodes must agree on each output.
+func (client *MultiClient) BatchOutputs(ctx context.Context, outpoints []utxo.Outpoint) ([]OutputResult, error) {
+	if client.opts.Quorum <= 1 {
+		results := []OutputResult{}
+		err := client.failover(ctx, func(c Client) (err error) {
+			results, err = c.BatchOutputs(ctx, outpoints)
+			return err
+		})
+		return results, err
+	}
+
+	batches := make([][]OutputResult, len(client.clients))
+	batchErrs := client.all(func(i int, c Client) (err error) {
+		batches[i], err = c.BatchOutputs(ctx, outpoints)
+		client.report(i, err)
+		if err == nil && len(batches[i]) != len(outpoints) {
+			err = fmt.Errorf("bad batch: expected %v results, got %v results", len(outpoints), len(batches[i]))
+		}
+		return err
+	})
+	results := make([]OutputResult, len(outpoints))
+	for k := range outpoints {
+		outputs := make([]utxo.Output, len(client.clients))
+		confs := make([]pack.U64, len(client.clients))
+		errs := make([]error, len(client.clients))
+		for i := range client.clients {
+			if batchErrs[i] != nil {
+				errs[i] = batchErrs[i]
+				continue
+			}
+			outputs[i], confs[i], errs[i] = batches[i][k].Output, batches[i][k].Confirmations, batches[i][k].Err
+		}
+		results[k].Output, results[k].Confirmations, results[k].Err = client.quorumOutput(outputs, confs, errs)
+	}
+	return results, nil
+}
+
+// BatchConfirmations returns the confirmations of each transaction, using one
+// batch of requests for each node. When the quorum is greater than one, a
+// quorum of nodes must know about each transaction.
+func (client *MultiClient) BatchConfirmations(ctx context.Context, txHashes []pack.Bytes) ([]ConfirmationsResult, error) {
+	if client.opts.Quorum <= 1 {
+		results := []ConfirmationsResult{}
+		err := client.failover(ctx, func(c Client) (err error) {
+			results, err = c.BatchConfirmations(ctx, txHashes)
+			return err
+		})
+		return results, err
+	}
+
+	batches := make([][]ConfirmationsResult, len(client.clients))
+	batchErrs := client.all(func(i int, c Client) (err error) {
+		batches[i], err = c.BatchConfirmations(ctx, txHashes)
+		client.report(i, err)
+		if err == nil && len(batches[i]) != len(txHashes) {
+			err = fmt.Errorf("bad batch: expected %v results, got %v results", len(txHashes), len(batches[i]))
+		}
+		return err
+	})
+	results := make([]ConfirmationsResult, len(txHashes))
+	for k := range txHashes {
+		confs := make([]int64, len(client.clients))
+		errs := make([]error, len(client.clients))
+		for i := range client.clients {
+			if batchErrs[i] != nil {
+				errs[i] = batchErrs[i]
+				continue
+			}
+			confs[i], errs[i] = batches[i][k].Confirmations, batches[i][k].Err
+		}
+		results[k].Confirmations, results[k].Err = client.quorumConfirmations(confs, errs)
+	}
+	return results, nil
+}
+
+func (client *MultiClient) confirmations(ctx context.Context, f func(Client) (int64, error)) (int64, error) {
+	if client.opts.Quorum <= 1 {
+		confs := int64(0)
+		err := client.failover(ctx, func(c Client) (err error) {
+			confs, err = f(c)
+			return err
+		})
+		return confs, err
+	}
+
+	confs := make([]int64, len(client.clients))
+	errs := client.all(func(i int, c Client) (err error) {
+		confs[i], err = f(c)
+		client.report(i, err)
+		return err
+	})
+	return client.quorumConfirmations(confs, errs)
+}
+
+// quorumOutput returns the output that a quorum of nodes agree on, given the
+// output, confirmations, and error returned by each node. ErrTxNotFound is
+// returned if a quorum of nodes cannot find the output.
+func (client *MultiClient) quorumOutput(outputs []utxo.Output, confs []pack.U64, errs []error) (utxo.Output, pack.U64, error) {
+	for i := range outputs {
+		if errs[i] != nil {
+			continue
+		}
+		agreed := []int64{}
+		for j := range outputs {
+			if errs[j] == nil && sameOutput(outputs[i], outputs[j]) {
+				agreed = append(agreed, int64(confs[j].Uint64()))
+			}
+		}
+		if len(agreed) >= client.opts.Quorum {
+			return outputs[i], pack.NewU64(uint64(client.quorumMin(agreed))), nil
+		}
+	}
+	notFound := 0
+	for i := range errs {
+		if errors.Is(errs[i], ErrTxNotFound) {
+			notFound++
+		}
+	}
+	if notFound >= client.opts.Quorum {
+		return utxo.Output{}, pack.NewU64(0), ErrTxNotFound
+	}
+	return utxo.Output{}, pack.NewU64(0), client.quorumError(errs)
+}
+
+// quorumConfirmations returns the confirmations that a quorum of nodes agree
+// on, given the confirmations and error returned by each node.
+func (client *MultiClient) quorumConfirmations(confs []int64, errs []error) (int64, error) {
+	found, notFound := []int64{}, 0
+	for i := range confs {
+		if errs[i] == nil {
+			found = append(found, confs[i])
+		} else if errors.Is(errs[i], ErrTxNotFound) {
+			notFound++
+		}
+	}
+	if len(found) >= client.opts.Quorum {
+		return client.quorumMin(found), nil
+	}
+	if notFound >= client.opts.Quorum {
+		return 0, ErrTxNotFound
+	}
+	return 0, client.quorumError(errs)
+}
+
+// failover calls the function with each client, healthy clients first, until
+// it succeeds. The error of the last call is returned if it never succeeds.
+func (client *MultiClient) failover(ctx context.Context, f func(Client) error) error {
+	if len(client.clients) == 0 {
+		return fmt.Errorf("bad clients: expected at least one client")
+	}
+	var err error
+	for _, i := range client.order() {
+		err = f(client.clients[i])
+		client.report(i, err)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// all calls the function with every client concurrently, and returns the
+// error of each call.
+func (client *MultiClient) all(f func(int, Client) error) []error {
+	errs := make([]error, len(client.clients))
+	wg := new(sync.WaitGroup)
+	for i := range client.clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = f(i, client.clients[i])
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// order returns the indices of the clients, with healthy clients first.
+func (client *MultiClient) order() []int {
+	client.healthyMu.RLock()
+	defer client.healthyMu.RUnlock()
+
+	order := make([]int, 0, len(client.clients))
+	for i := range client.healthy {
+		if client.healthy[i] {
+			order = append(order, i)
+		}
+	}
+	for i := range client.healthy {
+		if !client.healthy[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// report the result of a request to the client at the given index. Errors
+// returned by the node itself, instead of by the connection to the node, do
+// not make the node unhealthy.
+func (client *MultiClient) report(i int, err error) {
+	var rpcErr *RPCError
+	if err != nil && (errors.Is(err, ErrTxNotFound) || errors.As(err, &rpcErr)) {
+		return
+	}
+	client.setHealthy(i, err == nil)
+}
+
+func (client *MultiClient) setHealthy(i int, healthy bool) {
+	client.healthyMu.Lock()
+	defer client.healthyMu.Unlock()
+
+	client.healthy[i] = healthy
+}
+
+// quorumMin returns the largest number of confirmations that is reported, or
+// exceeded, by a quorum of nodes.
+func (client *MultiClient) quorumMin(confs []int64) int64 {
+	sort.Slice(confs, func(i, j int) bool { return confs[i] > confs[j] })
+	return confs[client.opts.Quorum-1]
+}
+
+func (client *MultiClient) quorumError(errs []error) error {
+	return fmt.Errorf("bad quorum: expected %v of %v nodes to agree, got errors %v", client.opts.Quorum, len(client.clients), errs)
+}
+
+func sameOutput(a, b utxo.Output) bool {
+	return bytes.Equal(a.Outpoint.Hash, b.Outpoint.Hash) &&
+		a.Outpoint.Index == b.Outpoint.Index &&
+		a.Value.Equal(b.Value) &&
+		bytes.Equal(a.PubKeyScript, b.PubKeyScript)
+}
@@ -0,0 +1,194 @@
+package bitcoin_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// nodeClient is a client that returns fixed results, and counts the requests
+// that it receives.
+type nodeClient struct {
+	bitcoin.Client
+
+	output   utxo.Output
+	confs    int64
+	err      error
+	requests int
+}
+
+func (client *nodeClient) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.Output, pack.U64, error) {
+	client.requests++
+	return client.output, pack.NewU64(uint64(client.confs)), client.err
+}
+
+func (client *nodeClient) BatchOutputs(ctx context.Context, outpoints []utxo.Outpoint) ([]bitcoin.OutputResult, error) {
+	client.requests++
+	results := make([]bitcoin.OutputResult, len(outpoints))
+	for i := range results {
+		results[i] = bitcoin.OutputResult{Output: client.output, Confirmations: pack.NewU64(uint64(client.confs)), Err: client.err}
+	}
+	return results, nil
+}
+
+func (client *nodeClient) Confirmations(ctx context.Context, txHash pack.Bytes) (int64, error) {
+	client.requests++
+	return client.confs, client.err
+}
+
+func (client *nodeClient) SubmitTx(ctx context.Context, tx utxo.Tx) error {
+	client.requests++
+	return client.err
+}
+
+func (client *nodeClient) BlockCount(ctx context.Context) (int64, error) {
+	return 100, client.err
+}
+
+var _ = Describe("MultiClient", func() {
+	errConnection := fmt.Errorf("sending http request: connection refused")
+	newMultiClient := func(opts bitcoin.MultiClientOptions, clients ...bitcoin.Client) *bitcoin.MultiClient {
+		client, err := bitcoin.NewMultiClient(opts, clients...)
+		Expect(err).ToNot(HaveOccurred())
+		return client
+	}
+	output := func(value uint64) utxo.Output {
+		return utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(value)),
+			PubKeyScript: pack.NewBytes([]byte{0x51}),
+		}
+	}
+
+	Context("when the quorum is invalid", func() {
+		It("should return an error", func() {
+			for _, quorum := range []int{-1, 0, 3} {
+				_, err := bitcoin.NewMultiClient(bitcoin.DefaultMultiClientOptions().WithQuorum(quorum), &nodeClient{}, &nodeClient{})
+				Expect(err).To(HaveOccurred())
+			}
+			_, err := bitcoin.NewMultiClient(bitcoin.DefaultMultiClientOptions())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when a node fails", func() {
+		It("should fail over to the next node until the node is healthy again", func() {
+			first := &nodeClient{err: errConnection}
+			second := &nodeClient{output: output(1000), confs: 3}
+			client := newMultiClient(bitcoin.DefaultMultiClientOptions(), first, second)
+
+			out, confs, err := client.Output(context.Background(), utxo.Outpoint{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal(output(1000)))
+			Expect(confs).To(Equal(pack.NewU64(3)))
+			Expect(client.Healthy()).To(Equal([]int{1}))
+
+			// The unhealthy node is tried last.
+			_, _, err = client.Output(context.Background(), utxo.Outpoint{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first.requests).To(Equal(1))
+			Expect(second.requests).To(Equal(2))
+
+			first.err = nil
+			Expect(client.HealthCheck(context.Background())).To(Equal(2))
+			Expect(client.Healthy()).To(Equal([]int{0, 1}))
+		})
+
+		It("should not make the node unhealthy if the transaction is not found", func() {
+			first := &nodeClient{err: bitcoin.ErrTxNotFound}
+			second := &nodeClient{confs: 2}
+			client := newMultiClient(bitcoin.DefaultMultiClientOptions(), first, second)
+
+			confs, err := client.Confirmations(context.Background(), pack.Bytes{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(2)))
+			Expect(client.Healthy()).To(Equal([]int{0, 1}))
+		})
+
+		It("should submit transactions to all nodes", func() {
+			first := &nodeClient{err: errConnection}
+			second := &nodeClient{}
+			client := newMultiClient(bitcoin.DefaultMultiClientOptions(), first, second)
+
+			Expect(client.SubmitTx(context.Background(), nil)).To(Succeed())
+			Expect(first.requests).To(Equal(1))
+			Expect(second.requests).To(Equal(1))
+
+			second.err = errConnection
+			Expect(client.SubmitTx(context.Background(), nil)).ToNot(Succeed())
+		})
+	})
+
+	Context("when the quorum is greater than one", func() {
+		opts := bitcoin.DefaultMultiClientOptions().WithQuorum(2)
+
+		It("should return the output that a quorum of nodes agree on", func() {
+			client := newMultiClient(opts,
+				&nodeClient{output: output(1000), confs: 5},
+				&nodeClient{output: output(2000), confs: 9},
+				&nodeClient{output: output(1000), confs: 3},
+			)
+			out, confs, err := client.Output(context.Background(), utxo.Outpoint{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal(output(1000)))
+			Expect(confs).To(Equal(pack.NewU64(3)))
+		})
+
+		It("should return an error if a quorum of nodes do not agree", func() {
+			client := newMultiClient(opts,
+				&nodeClient{output: output(1000), confs: 5},
+				&nodeClient{output: output(2000), confs: 9},
+				&nodeClient{err: errConnection},
+			)
+			_, _, err := client.Output(context.Background(), utxo.Outpoint{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return ErrTxNotFound if a quorum of nodes cannot find the output", func() {
+			client := newMultiClient(opts,
+				&nodeClient{output: output(1000), confs: 5},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+			)
+			_, _, err := client.Output(context.Background(), utxo.Outpoint{})
+			Expect(err).To(Equal(bitcoin.ErrTxNotFound))
+		})
+
+		It("should return ErrTxNotFound for batched outputs that a quorum of nodes cannot find", func() {
+			client := newMultiClient(opts,
+				&nodeClient{output: output(1000), confs: 5},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+			)
+			results, err := client.BatchOutputs(context.Background(), []utxo.Outpoint{{}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Err).To(Equal(bitcoin.ErrTxNotFound))
+		})
+
+		It("should return the confirmations reported by a quorum of nodes", func() {
+			client := newMultiClient(opts,
+				&nodeClient{confs: 6},
+				&nodeClient{err: errConnection},
+				&nodeClient{confs: 4},
+			)
+			confs, err := client.Confirmations(context.Background(), pack.Bytes{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(int64(4)))
+
+			client = newMultiClient(opts,
+				&nodeClient{confs: 6},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+				&nodeClient{err: bitcoin.ErrTxNotFound},
+			)
+			_, err = client.Confirmations(context.Background(), pack.Bytes{})
+			Expect(err).To(Equal(bitcoin.ErrTxNotFound))
+		})
+	})
+})
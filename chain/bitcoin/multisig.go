@@ -0,0 +1,225 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/pack"
+)
+
+// MaxMultisigPubKeys is the maximum number of pubkeys in a multisig script.
+// Larger scripts exceed the maximum size of a P2SH redeem script.
+const MaxMultisigPubKeys = 15
+
+// MultisigScript returns an m-of-n multisig script for the given compressed
+// pubkeys. The pubkeys are sorted lexicographically (see BIP67), so the same
+// script, and therefore the same address, is produced regardless of the order
+// in which the pubkeys are given. The script can be used as the redeem script
+// of a P2SH output, or as the witness script of a P2WSH output, and must be
+// used as the sig script of inputs that spend these outputs.
+func MultisigScript(m int, pubKeys []pack.Bytes) (pack.Bytes, error) {
+	n := len(pubKeys)
+	if n < 1 || n > MaxMultisigPubKeys {
+		return pack.Bytes{}, fmt.Errorf("bad pubkeys: expected 1 <= n <= %v, got n = %v", MaxMultisigPubKeys, n)
+	}
+	if m < 1 || m > n {
+		return pack.Bytes{}, fmt.Errorf("bad threshold: expected 1 <= m <= %v, got m = %v", n, m)
+	}
+
+	sorted := make([]pack.Bytes, n)
+	copy(sorted, pubKeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	for i, pubKey := range sorted {
+		if len(pubKey) != btcec.PubKeyBytesLenCompressed {
+			return pack.Bytes{}, fmt.Errorf("bad pubkey %x: expected compressed pubkey", pubKey)
+		}
+		if _, err := btcec.ParsePubKey(pubKey, btcec.S256()); err != nil {
+			return pack.Bytes{}, fmt.Errorf("bad pubkey %x: %v", pubKey, err)
+		}
+		if i > 0 && bytes.Equal(sorted[i-1], pubKey) {
+			return pack.Bytes{}, fmt.Errorf("bad pubkey %x: duplicate", pubKey)
+		}
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(m))
+	for _, pubKey := range sorted {
+		builder.AddData(pubKey)
+	}
+	builder.AddInt64(int64(n))
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	script, err := builder.Script()
+	if err != nil {
+		return pack.Bytes{}, err
+	}
+	return pack.NewBytes(script), nil
+}
+
+// MultisigAddress returns the P2SH address of the given multisig script.
+func MultisigAddress(script pack.Bytes, params *chaincfg.Params) (address.Address, error) {
+	addr, err := btcutil.NewAddressScriptHash(script, params)
+	if err != nil {
+		return address.Address(""), err
+	}
+	return address.Address(addr.EncodeAddress()), nil
+}
+
+// WitnessMultisigAddress returns the P2WSH address of the given multisig
+// script.
+func WitnessMultisigAddress(script pack.Bytes, params *chaincfg.Params) (address.Address, error) {
+	scriptHash := sha256.Sum256(script)
+	addr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+	if err != nil {
+		return address.Address(""), err
+	}
+	return address.Address(addr.EncodeAddress()), nil
+}
+
+// SignMultisig signs the transaction using signatures from multiple keys for
+// each input. The signatures and pubkeys of each input are given in pairs, and
+// do not need to be in any particular order. Inputs with a multisig sig script
+// need at least as many signatures as the threshold of the script, and all
+// other inputs need exactly one signature. Multisig inputs that spend P2WSH
+// outputs are signed using a witness, and all other multisig inputs are signed
+// using an OP_0 <sig...> <redeemScript> signature script.
+func (tx *Tx) SignMultisig(signatures [][]pack.Bytes65, pubKeys [][]pack.Bytes) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if len(signatures) != len(tx.msgTx.TxIn) || len(pubKeys) != len(tx.msgTx.TxIn) {
+		return fmt.Errorf("expected %v signatures and pubkeys, got %v signatures and %v pubkeys", len(tx.msgTx.TxIn), len(signatures), len(pubKeys))
+	}
+	sigs := make([][][]byte, len(signatures))
+	for i := range signatures {
+		if len(signatures[i]) != len(pubKeys[i]) {
+			return fmt.Errorf("bad input %v: expected %v signatures, got %v signatures", i, len(pubKeys[i]), len(signatures[i]))
+		}
+		sigs[i] = make([][]byte, len(signatures[i]))
+		for j, rsv := range signatures[i] {
+			sigs[i][j] = EncodeSignature(rsv, tx.sigHashType(i))
+		}
+	}
+	for i := range sigs {
+		keys := make([][]byte, len(pubKeys[i]))
+		for j := range pubKeys[i] {
+			keys[j] = pubKeys[i][j]
+		}
+		if err := tx.setSignatures(i, sigs[i], keys); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	tx.signed = true
+	return nil
+}
+
+// setSignatures sets the signature script, or witness, of the input at the
+// given index using signatures from one or more keys. The signatures must be
+// DER encoded, and must be followed by the sighash type.
+func (tx *Tx) setSignatures(i int, sigs [][]byte, pubKeys [][]byte) error {
+	sigScript := tx.inputs[i].SigScript
+	if !IsMultisigScript(sigScript) {
+		if len(sigs) != 1 {
+			return fmt.Errorf("expected 1 signature, got %v signatures", len(sigs))
+		}
+		return tx.setSignature(i, sigs[0], pubKeys[0])
+	}
+
+	ordered, err := OrderMultisigSignatures(sigScript, sigs, pubKeys)
+	if err != nil {
+		return err
+	}
+	if txscript.IsPayToWitnessScriptHash(tx.inputs[i].PubKeyScript) {
+		witness := wire.TxWitness{nil}
+		witness = append(witness, ordered...)
+		tx.msgTx.TxIn[i].Witness = append(witness, sigScript)
+		return nil
+	}
+	tx.msgTx.TxIn[i].SignatureScript, err = MultisigSignatureScript(sigScript, ordered)
+	return err
+}
+
+// IsMultisigScript returns true if the script is a multisig script.
+func IsMultisigScript(script []byte) bool {
+	return len(script) > 0 && txscript.GetScriptClass(script) == txscript.MultiSigTy
+}
+
+// OrderMultisigSignatures returns the signatures in the order in which their
+// pubkeys appear in the multisig script, which is the order required by
+// OP_CHECKMULTISIG. Only as many signatures as the threshold of the script are
+// returned. An error is returned if there are not enough signatures, or if a
+// pubkey does not appear in the script. It can be used by any Bitcoin-family
+// chain.
+func OrderMultisigSignatures(script []byte, sigs [][]byte, pubKeys [][]byte) ([][]byte, error) {
+	if len(sigs) != len(pubKeys) {
+		return nil, fmt.Errorf("expected %v signatures, got %v signatures", len(pubKeys), len(sigs))
+	}
+	_, m, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return nil, fmt.Errorf("bad multisig script: %v", err)
+	}
+	scriptPubKeys, err := txscript.PushedData(script)
+	if err != nil {
+		return nil, fmt.Errorf("bad multisig script: %v", err)
+	}
+
+	ordered := make([][]byte, len(scriptPubKeys))
+	for j, pubKey := range pubKeys {
+		found := false
+		for k := range scriptPubKeys {
+			if bytes.Equal(scriptPubKeys[k], pubKey) {
+				ordered[k] = sigs[j]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("bad pubkey %x: not in multisig script", pubKey)
+		}
+	}
+
+	result := make([][]byte, 0, m)
+	for _, sig := range ordered {
+		if sig != nil && len(result) < m {
+			result = append(result, sig)
+		}
+	}
+	if len(result) < m {
+		return nil, fmt.Errorf("expected %v signatures, got %v signatures", m, len(result))
+	}
+	return result, nil
+}
+
+// MultisigSignatureScript returns the OP_0 <sig...> <redeemScript> signature
+// script that spends a P2SH multisig output. The signatures must already be
+// ordered (see OrderMultisigSignatures). The leading OP_0 is needed because of
+// an off-by-one error in OP_CHECKMULTISIG.
+func MultisigSignatureScript(script []byte, orderedSigs [][]byte) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_0)
+	for _, sig := range orderedSigs {
+		builder.AddData(sig)
+	}
+	builder.AddData(script)
+	return builder.Script()
+}
+
+// EncodeSignature returns the DER encoding of the [R || S || V] signature,
+// followed by the sighash type.
+func EncodeSignature(rsv pack.Bytes65, sigHashType txscript.SigHashType) []byte {
+	signature := btcec.Signature{
+		R: new(big.Int).SetBytes(rsv[:32]),
+		S: new(big.Int).SetBytes(rsv[32:64]),
+	}
+	return append(signature.Serialize(), byte(sigHashType))
+}
@@ -0,0 +1,192 @@
+package bitcoin_test
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multisig", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	newKey := func() (*btcec.PrivateKey, pack.Bytes) {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			panic(err)
+		}
+		return privKey, pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	}
+	sign := func(tx utxo.Tx, privKey *btcec.PrivateKey) []pack.Bytes65 {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		signatures := make([]pack.Bytes65, len(sighashes))
+		for i := range sighashes {
+			hash := id.Hash(sighashes[i])
+			signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures[i] = pack.NewBytes65(signature)
+		}
+		return signatures
+	}
+	verify := func(tx utxo.Tx, inputs []utxo.Input) {
+		serialized, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+		msgTx := new(wire.MsgTx)
+		Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+		hashCache := txscript.NewTxSigHashes(msgTx)
+		for i, input := range inputs {
+			engine, err := txscript.NewEngine(input.PubKeyScript, msgTx, i, txscript.StandardVerifyFlags, nil, hashCache, int64(input.Value.Int().Uint64()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(engine.Execute()).To(Succeed())
+		}
+	}
+
+	privKey1, pubKey1 := newKey()
+	privKey2, pubKey2 := newKey()
+	_, pubKey3 := newKey()
+
+	script, err := bitcoin.MultisigScript(2, []pack.Bytes{pubKey1, pubKey2, pubKey3})
+	if err != nil {
+		panic(err)
+	}
+	shAddr, err := bitcoin.MultisigAddress(script, params)
+	if err != nil {
+		panic(err)
+	}
+	wshAddr, err := bitcoin.WitnessMultisigAddress(script, params)
+	if err != nil {
+		panic(err)
+	}
+	pubKeyScript := func(addr address.Address) pack.Bytes {
+		decoded, err := btcutil.DecodeAddress(string(addr), params)
+		Expect(err).ToNot(HaveOccurred())
+		script, err := txscript.PayToAddrScript(decoded)
+		Expect(err).ToNot(HaveOccurred())
+		return pack.NewBytes(script)
+	}
+	newInputs := func() []utxo.Input {
+		return []utxo.Input{
+			{
+				Output: utxo.Output{
+					Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+					Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+					PubKeyScript: pubKeyScript(shAddr),
+				},
+				SigScript: script,
+			},
+			{
+				Output: utxo.Output{
+					Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+					Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+					PubKeyScript: pubKeyScript(wshAddr),
+				},
+				SigScript: script,
+			},
+		}
+	}
+	recipients := []utxo.Recipient{
+		{To: wshAddr, Value: pack.NewU256FromU64(pack.NewU64(149000))},
+	}
+
+	Context("when building a multisig script", func() {
+		It("should not depend on the order of the pubkeys", func() {
+			other, err := bitcoin.MultisigScript(2, []pack.Bytes{pubKey3, pubKey1, pubKey2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(other).To(Equal(script))
+
+			class, addrs, m, err := txscript.ExtractPkScriptAddrs(script, params)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(class).To(Equal(txscript.MultiSigTy))
+			Expect(addrs).To(HaveLen(3))
+			Expect(m).To(Equal(2))
+		})
+
+		It("should return an error for a bad threshold", func() {
+			_, err := bitcoin.MultisigScript(0, []pack.Bytes{pubKey1, pubKey2})
+			Expect(err).To(HaveOccurred())
+			_, err = bitcoin.MultisigScript(3, []pack.Bytes{pubKey1, pubKey2})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error for bad pubkeys", func() {
+			_, err := bitcoin.MultisigScript(1, []pack.Bytes{pubKey1, pubKey1})
+			Expect(err).To(HaveOccurred())
+			_, err = bitcoin.MultisigScript(1, []pack.Bytes{pack.NewBytes(make([]byte, 33))})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when signing with enough keys", func() {
+		It("should produce a valid transaction", func() {
+			inputs := newInputs()
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures1 := sign(tx, privKey1)
+			signatures2 := sign(tx, privKey2)
+
+			// Signatures are given in a different order to their pubkeys in the
+			// script.
+			signatures := [][]pack.Bytes65{
+				{signatures2[0], signatures1[0]},
+				{signatures2[1], signatures1[1]},
+			}
+			pubKeys := [][]pack.Bytes{{pubKey2, pubKey1}, {pubKey2, pubKey1}}
+			Expect(tx.(*bitcoin.Tx).SignMultisig(signatures, pubKeys)).To(Succeed())
+			verify(tx, inputs)
+		})
+
+		It("should produce a valid transaction when finalizing partial signatures", func() {
+			inputs := newInputs()
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures1 := sign(tx, privKey1)
+			signatures2 := sign(tx, privKey2)
+			for i := range inputs {
+				Expect(tx.(*bitcoin.Tx).AddPartialSig(i, signatures1[i], pubKey1)).To(Succeed())
+				Expect(tx.(*bitcoin.Tx).AddPartialSig(i, signatures2[i], pubKey2)).To(Succeed())
+			}
+			Expect(tx.(*bitcoin.Tx).Finalize()).To(Succeed())
+			verify(tx, inputs)
+		})
+	})
+
+	Context("when signing with too few keys", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(newInputs(), recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures1 := sign(tx, privKey1)
+			signatures := [][]pack.Bytes65{{signatures1[0]}, {signatures1[1]}}
+			pubKeys := [][]pack.Bytes{{pubKey1}, {pubKey1}}
+			Expect(tx.(*bitcoin.Tx).SignMultisig(signatures, pubKeys)).ToNot(Succeed())
+		})
+	})
+
+	Context("when signing with a key that is not in the script", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(newInputs(), recipients)
+			Expect(err).ToNot(HaveOccurred())
+			privKey4, pubKey4 := newKey()
+			signatures1 := sign(tx, privKey1)
+			signatures4 := sign(tx, privKey4)
+			signatures := [][]pack.Bytes65{
+				{signatures1[0], signatures4[0]},
+				{signatures1[1], signatures4[1]},
+			}
+			pubKeys := [][]pack.Bytes{{pubKey1, pubKey4}, {pubKey1, pubKey4}}
+			Expect(tx.(*bitcoin.Tx).SignMultisig(signatures, pubKeys)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/pack"
+)
+
+// MaxNullDataSize is the maximum number of bytes of data that can be embedded
+// in a null-data output by standard Bitcoin transactions.
+const MaxNullDataSize = txscript.MaxDataCarrierSize
+
+// NullDataScript returns the OP_RETURN <data> pubkey script of a null-data
+// output that embeds the given data. An error is returned if there are more
+// than the given maximum number of bytes of data. It can be used by any
+// Bitcoin-family chain.
+func NullDataScript(data []byte, maxSize int) ([]byte, error) {
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("bad data: expected size <= %v, got size %v", maxSize, len(data))
+	}
+	builder := txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_RETURN)
+	builder.AddData(data)
+	return builder.Script()
+}
+
+// NullData returns the data embedded in the pubkey script of a null-data
+// output, and true, or false if the pubkey script is not a null-data script.
+// Data that is pushed by more than one operation is concatenated, and the data
+// is nil if nothing is pushed.
+func NullData(script []byte) (pack.Bytes, bool) {
+	if len(script) == 0 || script[0] != txscript.OP_RETURN || !txscript.IsPushOnlyScript(script[1:]) {
+		return nil, false
+	}
+	pushes, err := txscript.PushedData(script[1:])
+	if err != nil {
+		return nil, false
+	}
+	var data pack.Bytes
+	for _, push := range pushes {
+		data = append(data, push...)
+	}
+	return data, true
+}
@@ -0,0 +1,110 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Null data", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+	}
+	to := address.Address(pkhAddr.EncodeAddress())
+	data := pack.NewBytes(bytes.Repeat([]byte{0xAB}, 32))
+	zero := pack.NewU256FromU64(pack.NewU64(0))
+
+	Context("when a recipient has data", func() {
+		It("should produce a null-data output", func() {
+			recipients := []utxo.Recipient{
+				{To: to, Value: pack.NewU256FromU64(pack.NewU64(99000))},
+				{Value: zero, Data: data},
+			}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs).To(HaveLen(2))
+			Expect(outputs[0].Data).To(BeNil())
+			Expect(outputs[1].Data).To(Equal(data))
+			Expect(outputs[1].PubKeyScript[0]).To(Equal(byte(txscript.OP_RETURN)))
+			Expect(txscript.GetScriptClass(outputs[1].PubKeyScript)).To(Equal(txscript.NullDataTy))
+
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			decoded, err := bitcoin.DecodeTx(params, serialized, inputs)
+			Expect(err).ToNot(HaveOccurred())
+			decodedOutputs, err := decoded.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decodedOutputs).To(Equal(outputs))
+
+			binary, err := surge.ToBinary(tx)
+			Expect(err).ToNot(HaveOccurred())
+			fromBinary := new(bitcoin.Tx)
+			Expect(surge.FromBinary(fromBinary, binary)).To(Succeed())
+			Expect(fromBinary.Outputs()).To(Equal(outputs))
+
+			jsonData, err := json.Marshal(tx)
+			Expect(err).ToNot(HaveOccurred())
+			fromJSON := new(bitcoin.Tx)
+			Expect(json.Unmarshal(jsonData, fromJSON)).To(Succeed())
+			Expect(fromJSON.Outputs()).To(Equal(outputs))
+		})
+
+		It("should return an error if the data is too large", func() {
+			recipients := []utxo.Recipient{{Value: zero, Data: make([]byte, bitcoin.MaxNullDataSize+1)}}
+			_, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).To(HaveOccurred())
+
+			recipients = []utxo.Recipient{{Value: zero, Data: make([]byte, bitcoin.MaxNullDataSize)}}
+			_, err = txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should return an error if the recipient also has an address", func() {
+			recipients := []utxo.Recipient{{To: to, Value: zero, Data: data}}
+			_, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when decoding null-data scripts", func() {
+		It("should return the embedded data", func() {
+			script, err := bitcoin.NullDataScript(data, bitcoin.MaxNullDataSize)
+			Expect(err).ToNot(HaveOccurred())
+			decoded, ok := bitcoin.NullData(script)
+			Expect(ok).To(BeTrue())
+			Expect(decoded).To(Equal(data))
+
+			_, ok = bitcoin.NullData(pkhScript)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
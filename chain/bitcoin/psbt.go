@@ -0,0 +1,328 @@
+package bitcoin
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+// EncodePSBT returns the transaction as a partially signed Bitcoin transaction
+// (see BIP174), so that it can be passed to external signers. Every input
+// includes the output that it spends as its witness UTXO (the full previous
+// transaction is not known to the transaction builder), its redeem script or
+// witness script, and its sighash type. Signatures that have been added using
+// AddPartialSig are included. If the transaction has already been signed, the
+// inputs are encoded as finalized.
+func (tx *Tx) EncodePSBT() (pack.Bytes, error) {
+	packet, err := psbt.NewFromUnsignedTx(unsignedMsgTx(tx.msgTx))
+	if err != nil {
+		return pack.Bytes{}, fmt.Errorf("bad tx: %v", err)
+	}
+
+	for i, input := range tx.inputs {
+		value := input.Value.Int().Int64()
+		if value < 0 {
+			return pack.Bytes{}, fmt.Errorf("expected value >= 0, got value %v", value)
+		}
+		pInput := &packet.Inputs[i]
+		pInput.WitnessUtxo = wire.NewTxOut(value, input.PubKeyScript)
+		if tx.signed {
+			pInput.FinalScriptSig = tx.msgTx.TxIn[i].SignatureScript
+			if len(tx.msgTx.TxIn[i].Witness) > 0 {
+				witness, err := encodeWitness(tx.msgTx.TxIn[i].Witness)
+				if err != nil {
+					return pack.Bytes{}, fmt.Errorf("bad witness: %v", err)
+				}
+				pInput.FinalScriptWitness = witness
+			}
+			continue
+		}
+		pInput.SighashType = tx.sigHashType(i)
+		if input.SigScript != nil {
+			if txscript.IsPayToWitnessScriptHash(input.PubKeyScript) {
+				pInput.WitnessScript = input.SigScript
+			} else {
+				pInput.RedeemScript = input.SigScript
+			}
+		}
+		if i < len(tx.partialSigs) {
+			pInput.PartialSigs = tx.partialSigs[i]
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := packet.Serialize(buf); err != nil {
+		return pack.Bytes{}, err
+	}
+	return pack.NewBytes(buf.Bytes()), nil
+}
+
+// DecodePSBT returns the transaction encoded by a partially signed Bitcoin
+// transaction (see BIP174). The PSBT does not need to have been produced by
+// EncodePSBT, but every input must include either its witness UTXO or its
+// non-witness UTXO. The sighash type of each input is kept, and can be any
+// sighash type that is accepted by CheckSigHashType (inputs without a sighash
+// type use the default sighash type). Partial signatures are kept, so that they
+// can be merged with signatures from other PSBTs. If every input has been
+// finalized, the returned transaction is signed.
+func (txBuilder TxBuilder) DecodePSBT(data pack.Bytes) (*Tx, error) {
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(data), false)
+	if err != nil {
+		return nil, fmt.Errorf("bad psbt: %v", err)
+	}
+
+	inputs := make([]utxo.Input, len(packet.UnsignedTx.TxIn))
+	partialSigs := make([][]*psbt.PartialSig, len(packet.UnsignedTx.TxIn))
+	sigHashTypes := make([]utxo.SigHashType, len(packet.UnsignedTx.TxIn))
+	finalized := 0
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		pInput := packet.Inputs[i]
+
+		var prevOut *wire.TxOut
+		switch {
+		case pInput.WitnessUtxo != nil:
+			prevOut = pInput.WitnessUtxo
+		case pInput.NonWitnessUtxo != nil:
+			if pInput.NonWitnessUtxo.TxHash() != txIn.PreviousOutPoint.Hash {
+				return nil, fmt.Errorf("bad input %v: non-witness utxo does not match outpoint", i)
+			}
+			if int(txIn.PreviousOutPoint.Index) >= len(pInput.NonWitnessUtxo.TxOut) {
+				return nil, fmt.Errorf("bad input %v: non-witness utxo does not match outpoint", i)
+			}
+			prevOut = pInput.NonWitnessUtxo.TxOut[txIn.PreviousOutPoint.Index]
+		default:
+			return nil, fmt.Errorf("bad input %v: missing utxo", i)
+		}
+		if prevOut.Value < 0 {
+			return nil, fmt.Errorf("bad input %v: value is less than zero", i)
+		}
+		if pInput.SighashType != 0 {
+			if err := CheckSigHashType(pInput.SighashType); err != nil {
+				return nil, fmt.Errorf("bad input %v: %v", i, err)
+			}
+			sigHashTypes[i] = utxo.SigHashType(pInput.SighashType)
+		}
+
+		inputs[i] = utxo.Input{
+			Output: utxo.Output{
+				Outpoint: utxo.Outpoint{
+					Hash:  pack.NewBytes(txIn.PreviousOutPoint.Hash[:]),
+					Index: pack.NewU32(txIn.PreviousOutPoint.Index),
+				},
+				Value:        pack.NewU256FromU64(pack.NewU64(uint64(prevOut.Value))),
+				PubKeyScript: pack.NewBytes(prevOut.PkScript),
+			},
+		}
+		switch {
+		case pInput.WitnessScript != nil:
+			inputs[i].SigScript = pack.NewBytes(pInput.WitnessScript)
+		case pInput.RedeemScript != nil:
+			inputs[i].SigScript = pack.NewBytes(pInput.RedeemScript)
+		}
+		partialSigs[i] = pInput.PartialSigs
+		if pInput.FinalScriptSig != nil || pInput.FinalScriptWitness != nil {
+			finalized++
+		}
+	}
+
+	recipients, err := RecipientsFromMsgTx(packet.UnsignedTx, func(addr btcutil.Address) (address.Address, error) {
+		return address.Address(addr.EncodeAddress()), nil
+	}, txBuilder.params)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{inputs: inputs, recipients: recipients, msgTx: packet.UnsignedTx, partialSigs: partialSigs, sigHashTypes: sigHashTypes}
+	switch finalized {
+	case 0:
+		sighashes, err := tx.Sighashes()
+		if err != nil {
+			return nil, fmt.Errorf("bad sighashes: %v", err)
+		}
+		for i := range partialSigs {
+			for _, partialSig := range partialSigs[i] {
+				if err := verifyPartialSig(partialSig, sighashes[i], tx.sigHashType(i)); err != nil {
+					return nil, fmt.Errorf("bad input %v: %v", i, err)
+				}
+			}
+		}
+	case len(inputs):
+		msgTx, err := psbt.Extract(packet)
+		if err != nil {
+			return nil, fmt.Errorf("bad psbt: %v", err)
+		}
+		tx.msgTx = msgTx
+		tx.partialSigs = nil
+		tx.signed = true
+	default:
+		return nil, fmt.Errorf("bad psbt: expected all or no inputs to be finalized, got %v/%v", finalized, len(inputs))
+	}
+	return tx, nil
+}
+
+// AddPartialSig adds a signature, and the pubkey that produced it, to the
+// input at the given index. The signature is over the sighash returned by
+// Sighashes for that input. Partial signatures are included when encoding the
+// transaction as a PSBT, and are used when finalizing the transaction.
+func (tx *Tx) AddPartialSig(i int, signature pack.Bytes65, pubKey pack.Bytes) error {
+	if i < 0 || i >= len(tx.msgTx.TxIn) {
+		return fmt.Errorf("bad input: expected index < %v, got index %v", len(tx.msgTx.TxIn), i)
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	sig := btcec.Signature{
+		R: r,
+		S: s,
+	}
+	sighashes, err := tx.Sighashes()
+	if err != nil {
+		return fmt.Errorf("bad sighashes: %v", err)
+	}
+	return tx.addPartialSig(i, &psbt.PartialSig{
+		PubKey:    pubKey,
+		Signature: append(sig.Serialize(), byte(tx.sigHashType(i))),
+	}, sighashes)
+}
+
+// Merge adds the partial signatures of another transaction to this
+// transaction. Both transactions must be built from the same inputs and
+// recipients, usually by decoding PSBTs that have been signed by different
+// signers. An error is returned if the signers disagree about the signature of
+// a pubkey.
+func (tx *Tx) Merge(other *Tx) error {
+	if unsignedMsgTx(tx.msgTx).TxHash() != unsignedMsgTx(other.msgTx).TxHash() {
+		return fmt.Errorf("bad tx: expected %v, got %v", unsignedMsgTx(tx.msgTx).TxHash(), unsignedMsgTx(other.msgTx).TxHash())
+	}
+	sighashes, err := tx.Sighashes()
+	if err != nil {
+		return fmt.Errorf("bad sighashes: %v", err)
+	}
+	for i := range other.partialSigs {
+		for _, partialSig := range other.partialSigs[i] {
+			if err := tx.addPartialSig(i, partialSig, sighashes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Finalize uses the partial signatures of each input to sign the transaction.
+// The signature scripts and witnesses are the same as those produced by Sign,
+// or SignMultisig for inputs with a multisig sig script, so every other input
+// must have exactly one partial signature.
+func (tx *Tx) Finalize() error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if len(tx.partialSigs) != len(tx.msgTx.TxIn) {
+		return fmt.Errorf("expected %v signatures, got %v signatures", len(tx.msgTx.TxIn), len(tx.partialSigs))
+	}
+	for i := range tx.partialSigs {
+		if !IsMultisigScript(tx.inputs[i].SigScript) && len(tx.partialSigs[i]) != 1 {
+			return fmt.Errorf("bad input %v: expected 1 signature, got %v signatures", i, len(tx.partialSigs[i]))
+		}
+	}
+	for i := range tx.partialSigs {
+		sigs := make([][]byte, len(tx.partialSigs[i]))
+		pubKeys := make([][]byte, len(tx.partialSigs[i]))
+		for j, partialSig := range tx.partialSigs[i] {
+			sigs[j] = partialSig.Signature
+			pubKeys[j] = partialSig.PubKey
+		}
+		if err := tx.setSignatures(i, sigs, pubKeys); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	tx.partialSigs = nil
+	tx.signed = true
+	return nil
+}
+
+// addPartialSig verifies and adds a partial signature to the input at the given
+// index, using the sighashes of the transaction. Adding the same signature
+// twice has no effect.
+func (tx *Tx) addPartialSig(i int, partialSig *psbt.PartialSig, sighashes []pack.Bytes32) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if i < 0 || i >= len(tx.msgTx.TxIn) {
+		return fmt.Errorf("bad input: expected index < %v, got index %v", len(tx.msgTx.TxIn), i)
+	}
+	if err := verifyPartialSig(partialSig, sighashes[i], tx.sigHashType(i)); err != nil {
+		return fmt.Errorf("bad input %v: %v", i, err)
+	}
+	if len(tx.partialSigs) != len(tx.msgTx.TxIn) {
+		partialSigs := make([][]*psbt.PartialSig, len(tx.msgTx.TxIn))
+		copy(partialSigs, tx.partialSigs)
+		tx.partialSigs = partialSigs
+	}
+	for _, existing := range tx.partialSigs[i] {
+		if bytes.Equal(existing.PubKey, partialSig.PubKey) {
+			if !bytes.Equal(existing.Signature, partialSig.Signature) {
+				return fmt.Errorf("bad input %v: conflicting signatures for pubkey %x", i, partialSig.PubKey)
+			}
+			return nil
+		}
+	}
+	tx.partialSigs[i] = append(tx.partialSigs[i], partialSig)
+	return nil
+}
+
+// verifyPartialSig returns an error if the partial signature is not a valid
+// signature of the given sighash, using the given sighash type.
+func verifyPartialSig(partialSig *psbt.PartialSig, sighash pack.Bytes32, sigHashType txscript.SigHashType) error {
+	if len(partialSig.Signature) == 0 {
+		return fmt.Errorf("bad signature: empty")
+	}
+	if got := txscript.SigHashType(partialSig.Signature[len(partialSig.Signature)-1]); got != sigHashType {
+		return fmt.Errorf("bad signature: expected sighash type %v, got sighash type %v", sigHashType, got)
+	}
+	sig, err := btcec.ParseDERSignature(partialSig.Signature[:len(partialSig.Signature)-1], btcec.S256())
+	if err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+	pubKey, err := btcec.ParsePubKey(partialSig.PubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("bad pubkey: %v", err)
+	}
+	if !sig.Verify(sighash[:], pubKey) {
+		return fmt.Errorf("bad signature: verification failed for pubkey %x", partialSig.PubKey)
+	}
+	return nil
+}
+
+// unsignedMsgTx returns a copy of the transaction with all signature scripts
+// and witnesses removed.
+func unsignedMsgTx(msgTx *wire.MsgTx) *wire.MsgTx {
+	unsigned := msgTx.Copy()
+	for _, txIn := range unsigned.TxIn {
+		txIn.SignatureScript = nil
+		txIn.Witness = nil
+	}
+	return unsigned
+}
+
+// encodeWitness serializes a witness stack in the format used by the final
+// script witness field of a PSBT.
+func encodeWitness(witness wire.TxWitness) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := wire.WriteVarInt(buf, 0, uint64(len(witness))); err != nil {
+		return nil, err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package bitcoin_test
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PSBT", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	newKey := func() (*btcec.PrivateKey, pack.Bytes) {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			panic(err)
+		}
+		return privKey, pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	}
+	sign := func(tx utxo.Tx, privKey *btcec.PrivateKey) []pack.Bytes65 {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		signatures := make([]pack.Bytes65, len(sighashes))
+		for i := range sighashes {
+			hash := id.Hash(sighashes[i])
+			signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures[i] = pack.NewBytes65(signature)
+		}
+		return signatures
+	}
+
+	privKey, pubKey := newKey()
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	wpkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	wpkhScript, err := txscript.PayToAddrScript(wpkhAddr)
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+			PubKeyScript: pack.NewBytes(wpkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(wpkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(100000))},
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(49000))},
+	}
+
+	Context("when encoding and decoding an unsigned transaction", func() {
+		It("should return the same transaction", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := tx.(*bitcoin.Tx).EncodePSBT()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := txBuilder.DecodePSBT(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Inputs()).To(Equal(inputs))
+			Expect(decoded.SignalsReplaceByFee()).To(BeTrue())
+
+			txHash, err := tx.Hash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Hash()).To(Equal(txHash))
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Sighashes()).To(Equal(sighashes))
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Outputs()).To(Equal(outputs))
+		})
+	})
+
+	Context("when finalizing partial signatures", func() {
+		It("should produce the same transaction as signing", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := sign(tx, privKey)
+
+			// Each input is signed by a different signer, using its own copy
+			// of the PSBT.
+			data, err := tx.(*bitcoin.Tx).EncodePSBT()
+			Expect(err).ToNot(HaveOccurred())
+			partials := make([]*bitcoin.Tx, len(signatures))
+			for i := range signatures {
+				partial, err := txBuilder.DecodePSBT(data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(partial.AddPartialSig(i, signatures[i], pubKey)).To(Succeed())
+				partialData, err := partial.EncodePSBT()
+				Expect(err).ToNot(HaveOccurred())
+				partials[i], err = txBuilder.DecodePSBT(partialData)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			merged := partials[0]
+			for _, partial := range partials[1:] {
+				Expect(merged.Merge(partial)).To(Succeed())
+			}
+			Expect(merged.Finalize()).To(Succeed())
+
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			expected, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(merged.Serialize()).To(Equal(expected))
+
+			// Finalized transactions survive a round trip.
+			data, err = merged.EncodePSBT()
+			Expect(err).ToNot(HaveOccurred())
+			decoded, err := txBuilder.DecodePSBT(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Serialize()).To(Equal(expected))
+		})
+
+		It("should return an error if a signature is missing", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := sign(tx, privKey)
+			Expect(tx.(*bitcoin.Tx).AddPartialSig(0, signatures[0], pubKey)).To(Succeed())
+			Expect(tx.(*bitcoin.Tx).Finalize()).ToNot(Succeed())
+		})
+	})
+
+	Context("when adding invalid partial signatures", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := sign(tx, privKey)
+
+			// Signature of the wrong input.
+			Expect(tx.(*bitcoin.Tx).AddPartialSig(0, signatures[1], pubKey)).ToNot(Succeed())
+			// Signature of the wrong pubkey.
+			_, otherPubKey := newKey()
+			Expect(tx.(*bitcoin.Tx).AddPartialSig(0, signatures[0], otherPubKey)).ToNot(Succeed())
+			// Index out of range.
+			Expect(tx.(*bitcoin.Tx).AddPartialSig(2, signatures[0], pubKey)).ToNot(Succeed())
+		})
+	})
+
+	Context("when merging different transactions", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			other, err := txBuilder.BuildTx(inputs[:1], recipients[:1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(*bitcoin.Tx).Merge(other.(*bitcoin.Tx))).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package bitcoin
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
+)
+
+var (
+	// MinRelaySatsPerByte is the default minimum relay fee of Bitcoin nodes. A
+	// replacement transaction must pay for its own size at this rate, in
+	// addition to the fee of the transaction that it replaces.
+	MinRelaySatsPerByte = pack.NewU256FromU64(pack.NewU64(1))
+)
+
+// SignalsReplaceByFee returns true if the transaction signals that it can be
+// replaced by a transaction with a higher fee. This is true when at least one
+// of its inputs has a sequence number less than MaxTxInSequenceNum - 1.
+func (tx *Tx) SignalsReplaceByFee() bool {
+	for _, txIn := range tx.msgTx.TxIn {
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// BumpFeeOptions are used to parameterise the replacement transactions that
+// are built when bumping fees.
+type BumpFeeOptions struct {
+	DustThreshold pack.U256
+	InputScript   InputScriptFunc
+}
+
+// DefaultBumpFeeOptions returns BumpFeeOptions with the default settings.
+func DefaultBumpFeeOptions() BumpFeeOptions {
+	return BumpFeeOptions{
+		DustThreshold: DefaultCoinSelectorDustThreshold,
+		InputScript:   InputScriptFromInput,
+	}
+}
+
+// WithDustThreshold sets the minimum value of the change output of the
+// replacement transaction.
+func (opts BumpFeeOptions) WithDustThreshold(dustThreshold pack.U256) BumpFeeOptions {
+	opts.DustThreshold = dustThreshold
+	return opts
+}
+
+// WithInputScript sets the function that returns the InputScript of inputs,
+// which is used to estimate the size of the replacement transaction after it
+// has been signed. By default, InputScripts are inferred from the pubkey
+// scripts and sig scripts of the inputs.
+func (opts BumpFeeOptions) WithInputScript(inputScript InputScriptFunc) BumpFeeOptions {
+	opts.InputScript = inputScript
+	return opts
+}
+
+// BumpFee returns an unsigned transaction that replaces the given transaction
+// (see BIP125). The replacement spends the same inputs (with the same sequence
+// numbers and lock time), and pays the same recipients, but reduces the output
+// paid to the change address so that the replacement pays the given
+// SATs-per-byte. No new inputs are added, so the replacement never spends new
+// unconfirmed outputs. The given transaction can
+// be signed or unsigned. An error is returned if the given transaction does not
+// signal replaceability, if the replacement would not pay a higher fee and a
+// higher fee rate (accounting for the minimum relay fee), or if the change
+// would be less than the dust threshold. The InputScripts of the inputs are
+// returned by the InputScriptFunc of the options.
+func (txBuilder TxBuilder) BumpFee(tx utxo.Tx, change address.Address, satsPerByte pack.U256, opts BumpFeeOptions) (utxo.Tx, error) {
+	inputs, err := tx.Inputs()
+	if err != nil {
+		return nil, fmt.Errorf("bad inputs: %v", err)
+	}
+	inputScript := opts.InputScript
+	if inputScript == nil {
+		inputScript = InputScriptFromInput
+	}
+	scripts, err := inputScripts(inputs, inputScript)
+	if err != nil {
+		return nil, err
+	}
+	return txBuilder.BumpFeeWithInputScripts(tx, change, satsPerByte, scripts, opts)
+}
+
+// BumpFeeWithInputScripts is the same as BumpFee, except that the InputScript
+// of each input is given explicitly, in the same order as the inputs of the
+// transaction, and the InputScriptFunc of the options is not used.
+func (txBuilder TxBuilder) BumpFeeWithInputScripts(tx utxo.Tx, change address.Address, satsPerByte pack.U256, scripts []InputScript, opts BumpFeeOptions) (utxo.Tx, error) {
+	prev, ok := tx.(*Tx)
+	if !ok {
+		return nil, fmt.Errorf("bad tx: expected %T, got %T", &Tx{}, tx)
+	}
+	if !prev.SignalsReplaceByFee() {
+		return nil, fmt.Errorf("bad tx: replace-by-fee is not signalled")
+	}
+
+	changeIndex := -1
+	for i, recipient := range prev.recipients {
+		if recipient.To == change {
+			changeIndex = i
+		}
+	}
+	if changeIndex < 0 {
+		return nil, fmt.Errorf("bad change: %v is not a recipient", change)
+	}
+
+	// Compute the fee of the previous transaction.
+	in := new(big.Int)
+	for _, input := range prev.inputs {
+		in.Add(in, input.Value.Int())
+	}
+	out := new(big.Int)
+	for _, recipient := range prev.recipients {
+		out.Add(out, recipient.Value.Int())
+	}
+	if in.Cmp(out) < 0 {
+		return nil, fmt.Errorf("bad tx: outputs exceed inputs")
+	}
+	prevFee := new(big.Int).Sub(in, out)
+
+	// The replacement has the same inputs and outputs as the previous
+	// transaction, so it has the same size. The size is estimated from an
+	// unsigned copy, because the previous transaction might already be signed.
+	inputs := make([]utxo.Input, len(prev.inputs))
+	copy(inputs, prev.inputs)
+	recipients := make([]utxo.Recipient, len(prev.recipients))
+	copy(recipients, prev.recipients)
+	sequences := make([]uint32, len(prev.msgTx.TxIn))
+	for i, txIn := range prev.msgTx.TxIn {
+		sequences[i] = txIn.Sequence
+	}
+	replacementBuilder := txBuilder.WithLockTime(prev.msgTx.LockTime).WithSequences(sequences)
+	unsigned, err := replacementBuilder.BuildTx(inputs, recipients)
+	if err != nil {
+		return nil, err
+	}
+	_, vsize, err := EstimateSize(unsigned, scripts)
+	if err != nil {
+		return nil, err
+	}
+	fee := new(big.Int).Mul(big.NewInt(int64(vsize)), satsPerByte.Int())
+	minFee := new(big.Int).Mul(big.NewInt(int64(vsize)), MinRelaySatsPerByte.Int())
+	minFee.Add(minFee, prevFee)
+	if fee.Cmp(minFee) < 0 {
+		return nil, fmt.Errorf("bad fee: expected fee >= %v, got fee %v", minFee, fee)
+	}
+
+	// Reduce the change to pay for the higher fee.
+	changeValue := new(big.Int).Sub(prev.recipients[changeIndex].Value.Int(), new(big.Int).Sub(fee, prevFee))
+	if changeValue.Cmp(opts.DustThreshold.Int()) < 0 {
+		return nil, fmt.Errorf("bad change: expected change >= %v, got change %v", opts.DustThreshold, changeValue)
+	}
+	recipients[changeIndex].Value = pack.NewU256FromInt(changeValue)
+	return replacementBuilder.BuildTx(inputs, recipients)
+}
@@ -0,0 +1,201 @@
+package bitcoin_test
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Replace-by-fee", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+	opts := bitcoin.DefaultBumpFeeOptions()
+
+	fromAddr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		panic(err)
+	}
+	fromScript, err := txscript.PayToAddrScript(fromAddr)
+	if err != nil {
+		panic(err)
+	}
+	toAddr, err := btcutil.NewAddressWitnessPubKeyHash(append(make([]byte, 19), 1), params)
+	if err != nil {
+		panic(err)
+	}
+	to := address.Address(toAddr.EncodeAddress())
+	change := address.Address(fromAddr.EncodeAddress())
+
+	inputs := []utxo.Input{{Output: utxo.Output{
+		Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+		Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+		PubKeyScript: pack.NewBytes(fromScript),
+	}}}
+	recipients := []utxo.Recipient{
+		{To: to, Value: pack.NewU256FromU64(pack.NewU64(50000))},
+		{To: change, Value: pack.NewU256FromU64(pack.NewU64(49000))},
+	}
+
+	sequences := func(tx utxo.Tx) []uint32 {
+		serial, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+		msgTx := new(wire.MsgTx)
+		Expect(msgTx.Deserialize(bytes.NewReader(serial))).To(Succeed())
+		seqs := make([]uint32, len(msgTx.TxIn))
+		for i := range msgTx.TxIn {
+			seqs[i] = msgTx.TxIn[i].Sequence
+		}
+		return seqs
+	}
+
+	Context("when building transactions", func() {
+		It("should only signal replace-by-fee when enabled", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(*bitcoin.Tx).SignalsReplaceByFee()).To(BeFalse())
+			Expect(sequences(tx)).To(Equal([]uint32{wire.MaxTxInSequenceNum}))
+
+			tx, err = txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(*bitcoin.Tx).SignalsReplaceByFee()).To(BeTrue())
+			Expect(sequences(tx)).To(Equal([]uint32{bitcoin.RBFSequenceNum}))
+		})
+	})
+
+	Context("when bumping the fee", func() {
+		It("should reduce the change output", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			replacement, err := txBuilder.BumpFee(tx, change, pack.NewU256FromU64(pack.NewU64(20)), opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replacement.(*bitcoin.Tx).SignalsReplaceByFee()).To(BeTrue())
+
+			replacementInputs, err := replacement.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replacementInputs).To(Equal(inputs))
+
+			outputs, err := replacement.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs).To(HaveLen(2))
+			Expect(outputs[0].Value).To(Equal(recipients[0].Value))
+			Expect(outputs[1].PubKeyScript).To(Equal(pack.NewBytes(fromScript)))
+
+			_, vsize, err := bitcoin.EstimateSize(replacement, []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+			fee := 100000 - 50000 - outputs[1].Value.Int().Uint64()
+			Expect(fee).To(Equal(uint64(20 * vsize)))
+		})
+
+		It("should use the given input scripts", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH, Uncompressed: true}}
+			replacement, err := txBuilder.BumpFeeWithInputScripts(tx, change, pack.NewU256FromU64(pack.NewU64(20)), scripts, opts)
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := replacement.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			_, vsize, err := bitcoin.EstimateSize(replacement, scripts)
+			Expect(err).ToNot(HaveOccurred())
+			fee := 100000 - 50000 - outputs[1].Value.Int().Uint64()
+			Expect(fee).To(Equal(uint64(20 * vsize)))
+
+			_, err = txBuilder.BumpFeeWithInputScripts(tx, change, pack.NewU256FromU64(pack.NewU64(20)), nil, opts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should bump the fee of signed transactions", func() {
+			privKey := id.NewPrivKey()
+			pubKey := (*btcec.PublicKey)(&privKey.PublicKey).SerializeCompressed()
+			pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+			Expect(err).ToNot(HaveOccurred())
+			pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+			Expect(err).ToNot(HaveOccurred())
+			pkhChange := address.Address(pkhAddr.EncodeAddress())
+			pkhInputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(pkhScript),
+			}}}
+			pkhRecipients := []utxo.Recipient{
+				{To: to, Value: pack.NewU256FromU64(pack.NewU64(50000))},
+				{To: pkhChange, Value: pack.NewU256FromU64(pack.NewU64(49000))},
+			}
+
+			unsigned, err := txBuilder.WithReplaceByFee(true).BuildTx(pkhInputs, pkhRecipients)
+			Expect(err).ToNot(HaveOccurred())
+			expected, err := txBuilder.BumpFee(unsigned, pkhChange, pack.NewU256FromU64(pack.NewU64(20)), opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Signing the transaction must not change the size of the
+			// replacement, otherwise the signatures would be paid for twice.
+			sighashes, err := unsigned.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				hash := id.Hash(sighashes[i])
+				signature, err := privKey.Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatures[i] = pack.NewBytes65(signature)
+			}
+			Expect(unsigned.Sign(signatures, pack.NewBytes(pubKey))).To(Succeed())
+			replacement, err := txBuilder.BumpFee(unsigned, pkhChange, pack.NewU256FromU64(pack.NewU64(20)), opts)
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := replacement.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			expectedOutputs, err := expected.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs).To(Equal(expectedOutputs))
+		})
+
+		It("should return an error if replace-by-fee is not signalled", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = txBuilder.BumpFee(tx, change, pack.NewU256FromU64(pack.NewU64(20)), opts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if the fee does not increase enough", func() {
+			// The original transaction pays 1000 SATs, which is more than 4
+			// SATs-per-byte.
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = txBuilder.BumpFee(tx, change, pack.NewU256FromU64(pack.NewU64(4)), opts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if the change would be dust", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = txBuilder.BumpFee(tx, change, pack.NewU256FromU64(pack.NewU64(250)), opts)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should use the dust threshold of the options", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = txBuilder.BumpFee(tx, change, pack.NewU256FromU64(pack.NewU64(20)), opts.WithDustThreshold(pack.NewU256FromU64(pack.NewU64(48000))))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if the change address is not a recipient", func() {
+			tx, err := txBuilder.WithReplaceByFee(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = txBuilder.BumpFee(tx, address.Address("bcrt1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq"), pack.NewU256FromU64(pack.NewU64(20)), opts)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
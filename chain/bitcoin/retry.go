@@ -0,0 +1,172 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRetryMaxAttempts used by the BackoffPolicy. It is the maximum
+	// number of attempts that are made to send a request, including the first
+	// attempt.
+	DefaultRetryMaxAttempts = 10
+	// DefaultRetryInitialBackoff used by the BackoffPolicy. It is the duration
+	// to wait before the first retry.
+	DefaultRetryInitialBackoff = time.Second
+	// DefaultRetryMaxBackoff used by the BackoffPolicy. It is the maximum
+	// duration to wait between retries, before jitter is applied.
+	DefaultRetryMaxBackoff = 30 * time.Second
+	// DefaultRetryMultiplier used by the BackoffPolicy. It is the factor by
+	// which the backoff grows after each retry.
+	DefaultRetryMultiplier = 2.0
+	// DefaultRetryJitter used by the BackoffPolicy. It is the maximum fraction
+	// of the backoff that is randomly added to, or subtracted from, it.
+	DefaultRetryJitter = 0.2
+)
+
+// A RetryPolicy decides whether or not a failed attempt to send a request to
+// the node is retried, and how long to wait before retrying.
+type RetryPolicy interface {
+	// Backoff returns the duration to wait before retrying a request that has
+	// failed the given number of attempts with the given error, and false if
+	// the request must not be retried.
+	Backoff(attempts int, err error) (time.Duration, bool)
+}
+
+// BackoffPolicy is a RetryPolicy that retries transient errors (see
+// IsTransientError) with exponential backoff and jitter, until the maximum
+// number of attempts has been made. A maximum number of attempts of zero
+// allows requests to be retried until their context is done.
+type BackoffPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+}
+
+// DefaultBackoffPolicy returns a BackoffPolicy with the default settings.
+func DefaultBackoffPolicy() BackoffPolicy {
+	return BackoffPolicy{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+		Multiplier:     DefaultRetryMultiplier,
+		Jitter:         DefaultRetryJitter,
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts that are made to send a
+// request, including the first attempt.
+func (policy BackoffPolicy) WithMaxAttempts(maxAttempts int) BackoffPolicy {
+	policy.MaxAttempts = maxAttempts
+	return policy
+}
+
+// WithInitialBackoff sets the duration to wait before the first retry.
+func (policy BackoffPolicy) WithInitialBackoff(initialBackoff time.Duration) BackoffPolicy {
+	policy.InitialBackoff = initialBackoff
+	return policy
+}
+
+// WithMaxBackoff sets the maximum duration to wait between retries.
+func (policy BackoffPolicy) WithMaxBackoff(maxBackoff time.Duration) BackoffPolicy {
+	policy.MaxBackoff = maxBackoff
+	return policy
+}
+
+// WithJitter sets the maximum fraction of the backoff that is randomly added
+// to, or subtracted from, it.
+func (policy BackoffPolicy) WithJitter(jitter float64) BackoffPolicy {
+	policy.Jitter = jitter
+	return policy
+}
+
+// Backoff implements the RetryPolicy interface.
+func (policy BackoffPolicy) Backoff(attempts int, err error) (time.Duration, bool) {
+	if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+		return 0, false
+	}
+	if !IsTransientError(err) {
+		return 0, false
+	}
+	backoff := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(attempts-1))
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		backoff += backoff * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff), true
+}
+
+// An HTTPError is returned when the node responds with an HTTP error status,
+// and without a JSON-RPC error.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface.
+func (err *HTTPError) Error() string {
+	return fmt.Sprintf("bad http status %v: %v", err.StatusCode, err.Body)
+}
+
+// IsTransientError returns true if the error is likely to be resolved by
+// retrying the request: the connection to the node was refused, reset, or
+// timed out, the node responded with an HTTP 5xx status, or the node is still
+// warming up.
+func IsTransientError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == ErrRPCInWarmup
+	}
+	return false
+}
+
+// retry calls the function until it succeeds, the retry policy rejects its
+// error, or the context is done. The error of the last call is returned,
+// unless the context is done, in which case the context error is returned.
+func retry(ctx context.Context, policy RetryPolicy, logger *zap.Logger, f func() error) error {
+	for attempts := 1; ; attempts++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		backoff, ok := policy.Backoff(attempts, err)
+		if !ok {
+			return err
+		}
+		logger.Warn("retrying", zap.Int("attempts", attempts), zap.Duration("backoff", backoff), zap.Error(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
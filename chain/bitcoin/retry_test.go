@@ -0,0 +1,168 @@
+package bitcoin_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/renproject/multichain/chain/bitcoin"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry", func() {
+	policy := bitcoin.DefaultBackoffPolicy().
+		WithInitialBackoff(time.Millisecond).
+		WithMaxBackoff(4 * time.Millisecond).
+		WithJitter(0)
+	transientErr := &bitcoin.HTTPError{StatusCode: http.StatusServiceUnavailable}
+
+	newClient := func(server *httptest.Server, policy bitcoin.RetryPolicy) bitcoin.Client {
+		return bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL).WithRetryPolicy(policy))
+	}
+
+	Context("when computing the backoff", func() {
+		It("should grow exponentially up to the maximum backoff", func() {
+			for attempts, expected := range []time.Duration{1, 2, 4, 4} {
+				backoff, ok := policy.Backoff(attempts+1, transientErr)
+				Expect(ok).To(BeTrue())
+				Expect(backoff).To(Equal(expected * time.Millisecond))
+			}
+		})
+
+		It("should apply jitter within bounds", func() {
+			policy := policy.WithInitialBackoff(time.Second).WithMaxBackoff(time.Second).WithJitter(0.5)
+			for i := 0; i < 100; i++ {
+				backoff, ok := policy.Backoff(1, transientErr)
+				Expect(ok).To(BeTrue())
+				Expect(backoff).To(BeNumerically(">=", 500*time.Millisecond))
+				Expect(backoff).To(BeNumerically("<=", 1500*time.Millisecond))
+			}
+		})
+
+		It("should stop after the maximum number of attempts", func() {
+			_, ok := policy.WithMaxAttempts(3).Backoff(2, transientErr)
+			Expect(ok).To(BeTrue())
+			_, ok = policy.WithMaxAttempts(3).Backoff(3, transientErr)
+			Expect(ok).To(BeFalse())
+			_, ok = policy.WithMaxAttempts(0).Backoff(1000, transientErr)
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("when classifying errors", func() {
+		It("should only treat transient errors as retryable", func() {
+			Expect(bitcoin.IsTransientError(&bitcoin.HTTPError{StatusCode: http.StatusBadGateway})).To(BeTrue())
+			Expect(bitcoin.IsTransientError(&bitcoin.HTTPError{StatusCode: http.StatusUnauthorized})).To(BeFalse())
+			Expect(bitcoin.IsTransientError(&bitcoin.RPCError{Code: bitcoin.ErrRPCInWarmup})).To(BeTrue())
+			Expect(bitcoin.IsTransientError(&bitcoin.RPCError{Code: btcjson.ErrRPCInvalidParameter})).To(BeFalse())
+		})
+
+		It("should treat refused connections as retryable", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			addr := listener.Addr().String()
+			Expect(listener.Close()).To(Succeed())
+
+			// The request is retried until the context is done.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			client := bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost("http://" + addr).WithRetryPolicy(policy.WithMaxAttempts(0)))
+			_, err = client.EstimateSmartFee(ctx, 1, bitcoin.EstimateModeUnset)
+			Expect(err).To(MatchError(ContainSubstring(context.DeadlineExceeded.Error())))
+		})
+	})
+
+	Context("when the node returns transient errors", func() {
+		It("should retry until the request succeeds", func() {
+			requests := 0
+			server := newRPCServer(func(method string, params []interface{}) (string, string) {
+				requests++
+				if requests < 3 {
+					return "", `{"code":-28,"message":"Loading block index..."}`
+				}
+				return `{"feerate":0.0001,"blocks":2}`, ""
+			})
+			defer server.Close()
+
+			resp, err := newClient(server, policy).EstimateSmartFee(context.Background(), 2, bitcoin.EstimateModeUnset)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Blocks).To(Equal(int64(2)))
+			Expect(requests).To(Equal(3))
+		})
+
+		It("should retry HTTP 5xx responses without a JSON-RPC error", func() {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			_, err := newClient(server, policy.WithMaxAttempts(4)).EstimateSmartFee(context.Background(), 2, bitcoin.EstimateModeUnset)
+			Expect(err).To(HaveOccurred())
+			Expect(requests).To(Equal(4))
+		})
+	})
+
+	Context("when the deprecated retry timeout is set", func() {
+		It("should retry at a fixed interval until the context is done", func() {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			opts := bitcoin.DefaultClientOptions().WithHost(server.URL)
+			opts.TimeoutRetry = 10 * time.Millisecond
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err := bitcoin.NewClient(opts).EstimateSmartFee(ctx, 2, bitcoin.EstimateModeUnset)
+			Expect(err).To(HaveOccurred())
+			// The default policy gives up after 10 attempts, and would wait
+			// for more than a second before the fifth attempt.
+			Expect(requests).To(BeNumerically(">", 10))
+		})
+	})
+
+	Context("when the node returns other errors", func() {
+		It("should not retry", func() {
+			requests := 0
+			server := newRPCServer(func(method string, params []interface{}) (string, string) {
+				requests++
+				return "", `{"code":-8,"message":"Invalid conf_target"}`
+			})
+			defer server.Close()
+
+			_, err := newClient(server, policy).EstimateSmartFee(context.Background(), 2, bitcoin.EstimateModeUnset)
+			Expect(err).To(HaveOccurred())
+			Expect(requests).To(Equal(1))
+		})
+	})
+
+	Context("when the context is done during a request", func() {
+		It("should cancel the request", func() {
+			done := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer server.Close()
+			defer close(done)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := newClient(server, policy.WithMaxAttempts(0)).EstimateSmartFee(ctx, 2, bitcoin.EstimateModeUnset)
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+const (
+	// ErrRPCInWarmup is the JSON-RPC error code returned by nodes that are
+	// still starting up (for example, loading the block index).
+	ErrRPCInWarmup = btcjson.RPCErrorCode(-28)
+	// ErrRPCVerifyAlreadyInChain is the JSON-RPC error code returned by nodes
+	// when submitting a transaction that is already in the blockchain.
+	ErrRPCVerifyAlreadyInChain = btcjson.RPCErrorCode(-27)
+)
+
+var (
+	// ErrTxAlreadyInChain is matched by RPCErrors returned when submitting a
+	// transaction that is already in the blockchain.
+	ErrTxAlreadyInChain = errors.New("transaction already in block chain")
+	// ErrTxAlreadyInMempool is matched by RPCErrors returned when submitting a
+	// transaction that is already in the mempool.
+	ErrTxAlreadyInMempool = errors.New("transaction already in mempool")
+	// ErrMissingInputs is matched by RPCErrors returned when submitting a
+	// transaction that spends outputs that are unknown, or already spent.
+	ErrMissingInputs = errors.New("missing inputs")
+	// ErrInsufficientFee is matched by RPCErrors returned when submitting a
+	// transaction that does not pay enough fees to be relayed, or to replace
+	// the transactions that it conflicts with.
+	ErrInsufficientFee = errors.New("insufficient fee")
+	// ErrMempoolConflict is matched by RPCErrors returned when submitting a
+	// transaction that spends the same outputs as a transaction in the
+	// mempool, and cannot replace it.
+	ErrMempoolConflict = errors.New("mempool conflict")
+)
+
+// rpcErrorMessages are the substrings of the messages of RPCErrors that are
+// matched by each sentinel error. Different nodes, and different versions of
+// the same node, use different messages for the same error.
+var rpcErrorMessages = map[error][]string{
+	ErrTxAlreadyInChain:   {"already in block chain", "already in utxo set", "txn-already-confirmed"},
+	ErrTxAlreadyInMempool: {"txn-already-in-mempool", "txn-already-known"},
+	ErrMissingInputs:      {"missing inputs", "missingorspent"},
+	ErrInsufficientFee:    {"insufficient fee", "insufficient priority", "min relay fee not met", "mempool min fee not met"},
+	ErrMempoolConflict:    {"txn-mempool-conflict"},
+}
+
+// An RPCError is returned when the node responds to a JSON-RPC request with an
+// error. It can be matched against ErrTxAlreadyInChain, ErrTxAlreadyInMempool,
+// ErrMissingInputs, ErrInsufficientFee, and ErrMempoolConflict using
+// errors.Is.
+type RPCError struct {
+	Code    btcjson.RPCErrorCode `json:"code"`
+	Message string               `json:"message"`
+}
+
+// Error implements the error interface.
+func (err *RPCError) Error() string {
+	return fmt.Sprintf("%v: %v", err.Code, err.Message)
+}
+
+// Is returns true if the target is the sentinel error that corresponds to the
+// code and message of the RPCError.
+func (err *RPCError) Is(target error) bool {
+	if target == ErrTxAlreadyInChain && err.Code == ErrRPCVerifyAlreadyInChain {
+		return true
+	}
+	message := strings.ToLower(err.Message)
+	for _, substr := range rpcErrorMessages[target] {
+		if strings.Contains(message, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package bitcoin_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RPC errors", func() {
+	Context("when matching RPC errors", func() {
+		It("should match the sentinel errors of known codes and messages", func() {
+			matches := []struct {
+				err    *bitcoin.RPCError
+				target error
+			}{
+				{&bitcoin.RPCError{Code: -27, Message: "Transaction already in block chain"}, bitcoin.ErrTxAlreadyInChain},
+				{&bitcoin.RPCError{Code: -27, Message: "Transaction outputs already in utxo set"}, bitcoin.ErrTxAlreadyInChain},
+				{&bitcoin.RPCError{Code: -26, Message: "txn-already-in-mempool"}, bitcoin.ErrTxAlreadyInMempool},
+				{&bitcoin.RPCError{Code: -25, Message: "Missing inputs"}, bitcoin.ErrMissingInputs},
+				{&bitcoin.RPCError{Code: -25, Message: "bad-txns-inputs-missingorspent"}, bitcoin.ErrMissingInputs},
+				{&bitcoin.RPCError{Code: -26, Message: "min relay fee not met, 100 < 141"}, bitcoin.ErrInsufficientFee},
+				{&bitcoin.RPCError{Code: -26, Message: "insufficient fee, rejecting replacement"}, bitcoin.ErrInsufficientFee},
+				{&bitcoin.RPCError{Code: -26, Message: "258: txn-mempool-conflict"}, bitcoin.ErrMempoolConflict},
+			}
+			for _, match := range matches {
+				Expect(errors.Is(match.err, match.target)).To(BeTrue(), match.err.Error())
+			}
+
+			err := &bitcoin.RPCError{Code: -26, Message: "scriptpubkey"}
+			for _, target := range []error{bitcoin.ErrTxAlreadyInChain, bitcoin.ErrTxAlreadyInMempool, bitcoin.ErrMissingInputs, bitcoin.ErrInsufficientFee, bitcoin.ErrMempoolConflict} {
+				Expect(errors.Is(err, target)).To(BeFalse())
+			}
+		})
+	})
+
+	Context("when submitting transactions", func() {
+		params := &chaincfg.RegressionNetParams
+		pkhAddr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+		if err != nil {
+			panic(err)
+		}
+		pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+		if err != nil {
+			panic(err)
+		}
+		tx, err := bitcoin.NewTxBuilder(params).BuildTx(
+			[]utxo.Input{{Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(pkhScript),
+			}}},
+			[]utxo.Recipient{{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(99000))}},
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		submitTx := func(rpcErr string) error {
+			server := newRPCServer(func(method string, params []interface{}) (string, string) {
+				Expect(method).To(Equal("sendrawtransaction"))
+				return `""`, rpcErr
+			})
+			defer server.Close()
+			return bitcoin.NewClient(bitcoin.DefaultClientOptions().WithHost(server.URL)).SubmitTx(context.Background(), tx)
+		}
+
+		It("should succeed if the transaction has already been submitted", func() {
+			Expect(submitTx(`{"code":-27,"message":"Transaction already in block chain"}`)).To(Succeed())
+			Expect(submitTx(`{"code":-26,"message":"txn-already-in-mempool"}`)).To(Succeed())
+		})
+
+		It("should return errors that can be matched", func() {
+			err := submitTx(`{"code":-26,"message":"txn-mempool-conflict"}`)
+			Expect(errors.Is(err, bitcoin.ErrMempoolConflict)).To(BeTrue())
+
+			var rpcErr *bitcoin.RPCError
+			Expect(errors.As(err, &rpcErr)).To(BeTrue())
+			Expect(rpcErr.Code).To(BeEquivalentTo(-26))
+
+			err = submitTx(`{"code":-25,"message":"Missing inputs"}`)
+			Expect(errors.Is(err, bitcoin.ErrMissingInputs)).To(BeTrue())
+			Expect(errors.Is(err, bitcoin.ErrMempoolConflict)).To(BeFalse())
+		})
+	})
+})
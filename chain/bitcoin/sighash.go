@@ -0,0 +1,67 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/multichain/api/utxo"
+)
+
+// CheckSigHashType returns an error if the sighash type is not SIGHASH_ALL,
+// SIGHASH_NONE, or SIGHASH_SINGLE, optionally combined with
+// SIGHASH_ANYONECANPAY. It can be used by any Bitcoin-family chain.
+func CheckSigHashType(sigHashType txscript.SigHashType) error {
+	switch sigHashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashAll, txscript.SigHashNone, txscript.SigHashSingle:
+		return nil
+	default:
+		return fmt.Errorf("bad sighash type %v", sigHashType)
+	}
+}
+
+// SetSigHashType sets the sighash type of the input at the given index, so
+// that each signer can commit to only the parts of the transaction that they
+// care about (for example, their own inputs and outputs). It must be called
+// before computing the sighashes, and signatures of the input must use the
+// same sighash type. By default, inputs use SIGHASH_ALL, except for P2TR
+// inputs, which use SIGHASH_DEFAULT.
+func (tx *Tx) SetSigHashType(i int, sigHashType utxo.SigHashType) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if i < 0 || i >= len(tx.inputs) {
+		return fmt.Errorf("bad input: expected index < %v, got index %v", len(tx.inputs), i)
+	}
+	if sigHashType != utxo.DefaultSigHashType {
+		if err := CheckSigHashType(txscript.SigHashType(sigHashType)); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	if len(tx.sigHashTypes) != len(tx.inputs) {
+		sigHashTypes := make([]utxo.SigHashType, len(tx.inputs))
+		copy(sigHashTypes, tx.sigHashTypes)
+		tx.sigHashTypes = sigHashTypes
+	}
+	tx.sigHashTypes[i] = sigHashType
+	return nil
+}
+
+// sigHashType returns the sighash type of the input at the given index.
+func (tx *Tx) sigHashType(i int) txscript.SigHashType {
+	if i < len(tx.sigHashTypes) {
+		return tx.resolveSigHashType(i, tx.sigHashTypes[i])
+	}
+	return tx.resolveSigHashType(i, utxo.DefaultSigHashType)
+}
+
+// resolveSigHashType replaces the default sighash type with the sighash type
+// that it selects for the input at the given index.
+func (tx *Tx) resolveSigHashType(i int, sigHashType utxo.SigHashType) txscript.SigHashType {
+	if sigHashType != utxo.DefaultSigHashType {
+		return txscript.SigHashType(sigHashType)
+	}
+	if tx.isTaproot(i) {
+		return SigHashDefault
+	}
+	return txscript.SigHashAll
+}
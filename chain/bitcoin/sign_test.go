@@ -0,0 +1,161 @@
+package bitcoin_test
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signing", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	newKey := func() (*btcec.PrivateKey, pack.Bytes) {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			panic(err)
+		}
+		return privKey, pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	}
+	signInput := func(tx utxo.Tx, i int, privKey *btcec.PrivateKey) pack.Bytes65 {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		hash := id.Hash(sighashes[i])
+		signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+		Expect(err).ToNot(HaveOccurred())
+		return pack.NewBytes65(signature)
+	}
+
+	privKey1, pubKey1 := newKey()
+	privKey2, pubKey2 := newKey()
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey1), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	wpkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey2), params)
+	if err != nil {
+		panic(err)
+	}
+	wpkhScript, err := txscript.PayToAddrScript(wpkhAddr)
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+			PubKeyScript: pack.NewBytes(wpkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(149000))},
+	}
+
+	Context("when signing inputs that are owned by different keys", func() {
+		It("should produce a valid transaction", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := []utxo.InputSignature{
+				{Signature: signInput(tx, 0, privKey1), PubKey: pubKey1},
+				{Signature: signInput(tx, 1, privKey2), PubKey: pubKey2, SigHashType: utxo.SigHashType(txscript.SigHashAll)},
+			}
+			Expect(tx.(utxo.InputSigner).SignInputs(signatures)).To(Succeed())
+
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			msgTx := new(wire.MsgTx)
+			Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+			hashCache := txscript.NewTxSigHashes(msgTx)
+			for i, input := range inputs {
+				engine, err := txscript.NewEngine(input.PubKeyScript, msgTx, i, txscript.StandardVerifyFlags, nil, hashCache, int64(input.Value.Int().Uint64()))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(engine.Execute()).To(Succeed())
+			}
+		})
+	})
+
+	Context("when signing with a sighash type that does not match the input", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := []utxo.InputSignature{
+				{Signature: signInput(tx, 0, privKey1), PubKey: pubKey1},
+				{Signature: signInput(tx, 1, privKey2), PubKey: pubKey2, SigHashType: utxo.SigHashType(txscript.SigHashNone)},
+			}
+			Expect(tx.(utxo.InputSigner).SignInputs(signatures)).ToNot(Succeed())
+		})
+	})
+
+	Context("when setting the sighash types of inputs", func() {
+		It("should produce a valid transaction", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(0, utxo.SigHashType(txscript.SigHashSingle|txscript.SigHashAnyOneCanPay))).To(Succeed())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(1, utxo.SigHashType(txscript.SigHashNone))).To(Succeed())
+			signatures := []utxo.InputSignature{
+				{Signature: signInput(tx, 0, privKey1), PubKey: pubKey1},
+				{Signature: signInput(tx, 1, privKey2), PubKey: pubKey2, SigHashType: utxo.SigHashType(txscript.SigHashNone)},
+			}
+			Expect(tx.(utxo.InputSigner).SignInputs(signatures)).To(Succeed())
+
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			msgTx := new(wire.MsgTx)
+			Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+			pushes, err := txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushes[0][len(pushes[0])-1]).To(Equal(byte(txscript.SigHashSingle | txscript.SigHashAnyOneCanPay)))
+			hashCache := txscript.NewTxSigHashes(msgTx)
+			for i, input := range inputs {
+				engine, err := txscript.NewEngine(input.PubKeyScript, msgTx, i, txscript.StandardVerifyFlags, nil, hashCache, int64(input.Value.Int().Uint64()))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(engine.Execute()).To(Succeed())
+			}
+		})
+
+		It("should preserve the sighash types when marshalling", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(1, utxo.SigHashType(txscript.SigHashSingle))).To(Succeed())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+
+			data, err := tx.(*bitcoin.Tx).MarshalJSON()
+			Expect(err).ToNot(HaveOccurred())
+			unmarshalled := new(bitcoin.Tx)
+			Expect(unmarshalled.UnmarshalJSON(data)).To(Succeed())
+			unmarshalledSighashes, err := unmarshalled.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unmarshalledSighashes).To(Equal(sighashes))
+		})
+
+		It("should return an error for unsupported sighash types and indices", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(0, utxo.SigHashType(0x04))).ToNot(Succeed())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(2, utxo.SigHashType(txscript.SigHashAll))).ToNot(Succeed())
+		})
+	})
+})
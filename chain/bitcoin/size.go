@@ -0,0 +1,375 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/multichain/api/utxo"
+)
+
+const (
+	// MaxSignatureSize is the maximum size of a DER encoded ECDSA signature,
+	// including the sighash type.
+	MaxSignatureSize = 73
+	// PubKeySize is the size of a compressed pubkey.
+	PubKeySize = 33
+	// UncompressedPubKeySize is the size of an uncompressed pubkey.
+	UncompressedPubKeySize = 65
+)
+
+// ScriptType is the type of pubkey script being spent by an input.
+type ScriptType uint8
+
+// Enumeration of supported script types.
+const (
+	ScriptP2PKH = ScriptType(iota)
+	ScriptP2SHMultisig
+	ScriptP2WPKH
+	ScriptP2WSH
+	ScriptP2SHP2WPKH
+	ScriptP2SHSingleSig
+	ScriptP2WSHSingleSig
+	ScriptP2TR
+)
+
+// String implements the Stringer interface.
+func (scriptType ScriptType) String() string {
+	switch scriptType {
+	case ScriptP2PKH:
+		return "P2PKH"
+	case ScriptP2SHMultisig:
+		return "P2SH-multisig"
+	case ScriptP2WPKH:
+		return "P2WPKH"
+	case ScriptP2WSH:
+		return "P2WSH"
+	case ScriptP2SHP2WPKH:
+		return "P2SH-P2WPKH"
+	case ScriptP2SHSingleSig:
+		return "P2SH-single-sig"
+	case ScriptP2WSHSingleSig:
+		return "P2WSH-single-sig"
+	case ScriptP2TR:
+		return "P2TR"
+	default:
+		return fmt.Sprintf("ScriptType(%d)", uint8(scriptType))
+	}
+}
+
+// An InputScript describes how an input will be spent once it has been
+// signed. For multisig scripts, M is the number of required signatures and N is
+// the number of pubkeys. P2WSH scripts are assumed to be M-of-N multisig
+// witness scripts. ScriptSize is the size of the redeem script (or witness
+// script) of P2SH and P2WSH inputs. It is required by single-sig scripts, which
+// are spent by a signature and a pubkey followed by the script. It is optional
+// for multisig scripts, and when it is zero the script is assumed to be a
+// multisig script of compressed pubkeys (or uncompressed pubkeys, when
+// Uncompressed is true). Uncompressed is true when the input is signed using
+// an uncompressed pubkey, which cannot be inferred from the pubkey script of
+// P2PKH inputs. SigHashType is the sighash type of P2TR inputs, which are
+// signed using 64 byte signatures when it is SIGHASH_DEFAULT, and 65 byte
+// signatures otherwise. When estimating the size of a Bitcoin transaction, it
+// defaults to the sighash type of the input in the transaction.
+type InputScript struct {
+	Type         ScriptType
+	M, N         int
+	ScriptSize   int
+	Uncompressed bool
+	SigHashType  utxo.SigHashType
+}
+
+// An InputScriptFunc returns the InputScript that describes how the given
+// input will be spent once it has been signed. It can be used to give explicit
+// scripts for inputs that cannot be inferred from their pubkey scripts and sig
+// scripts.
+type InputScriptFunc func(input utxo.Input) (InputScript, error)
+
+// IsWitness returns true if the input will be spent using a witness.
+func (script InputScript) IsWitness() bool {
+	switch script.Type {
+	case ScriptP2WPKH, ScriptP2WSH, ScriptP2SHP2WPKH, ScriptP2WSHSingleSig, ScriptP2TR:
+		return true
+	default:
+		return false
+	}
+}
+
+// InputScriptFromPubKeyScript returns the InputScript that is assumed when
+// spending the given pubkey script. P2SH scripts are assumed to be nested
+// P2WPKH scripts, and unknown scripts are assumed to be P2PKH scripts.
+func InputScriptFromPubKeyScript(pubKeyScript []byte) InputScript {
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(pubKeyScript):
+		return InputScript{Type: ScriptP2WPKH}
+	case IsPayToTaproot(pubKeyScript):
+		return InputScript{Type: ScriptP2TR}
+	case txscript.IsPayToScriptHash(pubKeyScript):
+		return InputScript{Type: ScriptP2SHP2WPKH}
+	default:
+		return InputScript{Type: ScriptP2PKH}
+	}
+}
+
+// InputScriptFromInput returns the InputScript that is assumed when spending
+// the given input. If the input has no sig script, then the InputScript is
+// inferred from its pubkey script (see InputScriptFromPubKeyScript).
+// Otherwise, the sig script is the redeem script of a P2SH output, or the
+// witness script of a P2WSH output, and is either a multisig script or a
+// script that is spent by a single signature and pubkey. It is an
+// InputScriptFunc.
+func InputScriptFromInput(input utxo.Input) (InputScript, error) {
+	script := []byte(input.SigScript)
+	if len(script) == 0 {
+		return InputScriptFromPubKeyScript(input.PubKeyScript), nil
+	}
+	isWitness := txscript.IsPayToWitnessScriptHash(input.PubKeyScript)
+	if txscript.GetScriptClass(script) != txscript.MultiSigTy {
+		if isWitness {
+			return InputScript{Type: ScriptP2WSHSingleSig, ScriptSize: len(script)}, nil
+		}
+		return InputScript{Type: ScriptP2SHSingleSig, ScriptSize: len(script)}, nil
+	}
+	n, m, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return InputScript{}, fmt.Errorf("bad multisig script: %v", err)
+	}
+	if isWitness {
+		return InputScript{Type: ScriptP2WSH, M: m, N: n, ScriptSize: len(script)}, nil
+	}
+	return InputScript{Type: ScriptP2SHMultisig, M: m, N: n, ScriptSize: len(script)}, nil
+}
+
+// InputScriptFromInputWithoutWitness returns the InputScript that is assumed
+// when spending the given input on a chain that does not support segwit. It is
+// the same as InputScriptFromInput, except that P2SH inputs must have a sig
+// script, and an error is returned if the input needs a witness. It is an
+// InputScriptFunc.
+func InputScriptFromInputWithoutWitness(input utxo.Input) (InputScript, error) {
+	if len(input.SigScript) == 0 && txscript.IsPayToScriptHash(input.PubKeyScript) {
+		return InputScript{}, fmt.Errorf("bad sig script: expected redeem script")
+	}
+	script, err := InputScriptFromInput(input)
+	if err != nil {
+		return InputScript{}, err
+	}
+	if script.IsWitness() {
+		return InputScript{}, fmt.Errorf("bad script: %v is not supported", script.Type)
+	}
+	return script, nil
+}
+
+// inputScripts returns the InputScript of each input, using the given
+// InputScriptFunc.
+func inputScripts(inputs []utxo.Input, inputScript InputScriptFunc) ([]InputScript, error) {
+	scripts := make([]InputScript, len(inputs))
+	for i := range inputs {
+		script, err := inputScript(inputs[i])
+		if err != nil {
+			return nil, fmt.Errorf("bad input %v: %v", i, err)
+		}
+		scripts[i] = script
+	}
+	return scripts, nil
+}
+
+// pubKeySize returns the size of the pubkey that is used to sign the input.
+func (script InputScript) pubKeySize() int {
+	if script.Uncompressed {
+		return UncompressedPubKeySize
+	}
+	return PubKeySize
+}
+
+// sizes returns the size of the sig script, and the size of the witness, for
+// an input after it has been signed. Signatures are assumed to be at most
+// MaxSignatureSize bytes.
+func (script InputScript) sizes() (int, int, error) {
+	switch script.Type {
+	case ScriptP2PKH:
+		return pushSize(MaxSignatureSize) + pushSize(script.pubKeySize()), 0, nil
+	case ScriptP2SHMultisig:
+		redeemScriptSize, err := script.multisigScriptSize()
+		if err != nil {
+			return 0, 0, err
+		}
+		// The leading OP_0 is needed because of an off-by-one error in
+		// OP_CHECKMULTISIG.
+		return 1 + script.M*pushSize(MaxSignatureSize) + pushSize(redeemScriptSize), 0, nil
+	case ScriptP2WPKH:
+		return 0, wire.VarIntSerializeSize(2) + varSize(MaxSignatureSize) + varSize(script.pubKeySize()), nil
+	case ScriptP2WSH:
+		witnessScriptSize, err := script.multisigScriptSize()
+		if err != nil {
+			return 0, 0, err
+		}
+		// The leading empty item is needed because of an off-by-one error in
+		// OP_CHECKMULTISIG.
+		return 0, wire.VarIntSerializeSize(uint64(script.M+2)) + varSize(0) + script.M*varSize(MaxSignatureSize) + varSize(witnessScriptSize), nil
+	case ScriptP2SHP2WPKH:
+		// The sig script pushes the 22 byte witness program.
+		return pushSize(22), wire.VarIntSerializeSize(2) + varSize(MaxSignatureSize) + varSize(script.pubKeySize()), nil
+	case ScriptP2SHSingleSig:
+		if script.ScriptSize <= 0 {
+			return 0, 0, fmt.Errorf("bad script size: expected > 0, got %v", script.ScriptSize)
+		}
+		return pushSize(MaxSignatureSize) + pushSize(script.pubKeySize()) + pushSize(script.ScriptSize), 0, nil
+	case ScriptP2WSHSingleSig:
+		if script.ScriptSize <= 0 {
+			return 0, 0, fmt.Errorf("bad script size: expected > 0, got %v", script.ScriptSize)
+		}
+		return 0, wire.VarIntSerializeSize(3) + varSize(MaxSignatureSize) + varSize(script.pubKeySize()) + varSize(script.ScriptSize), nil
+	case ScriptP2TR:
+		// Key path spends only need a Schnorr signature, which omits the
+		// sighash type when using SIGHASH_DEFAULT.
+		sigSize := SchnorrSignatureSize
+		if script.SigHashType != utxo.SigHashType(SigHashDefault) {
+			sigSize++
+		}
+		return 0, wire.VarIntSerializeSize(1) + varSize(sigSize), nil
+	default:
+		return 0, 0, fmt.Errorf("non-exhaustive pattern: script type %v", script.Type)
+	}
+}
+
+// multisigScriptSize returns the size of an M-of-N multisig script.
+func (script InputScript) multisigScriptSize() (int, error) {
+	if script.M < 1 || script.M > script.N || script.N > txscript.MaxPubKeysPerMultiSig {
+		return 0, fmt.Errorf("bad multisig: expected 1 <= m <= n <= %v, got m = %v and n = %v", txscript.MaxPubKeysPerMultiSig, script.M, script.N)
+	}
+	if script.ScriptSize > 0 {
+		return script.ScriptSize, nil
+	}
+	// OP_M <pubkeys> OP_N OP_CHECKMULTISIG, where M and N are pushed using a
+	// single byte small integer opcode whenever possible.
+	return smallIntSize(script.M) + script.N*pushSize(script.pubKeySize()) + smallIntSize(script.N) + 1, nil
+}
+
+// weight returns an upper bound on the weight of the input after it has been
+// signed, assuming that the transaction includes witness data whenever the
+// input does.
+func (script InputScript) weight() (int, error) {
+	sigScriptSize, witnessSize, err := script.sizes()
+	if err != nil {
+		return 0, err
+	}
+	baseSize := 32 + 4 + varSize(sigScriptSize) + 4
+	return 4*baseSize + witnessSize, nil
+}
+
+// EstimateSize returns an upper bound on the serialized size, and virtual
+// size, of the given unsigned transaction after it has been signed. The script
+// of each input must be given, in the same order as the inputs of the
+// transaction. The unsigned transaction can be from any Bitcoin-family chain,
+// because the chain-specific overheads (for example, the expiry height of
+// Zcash transactions) are already included in its serialization. An error is
+// returned if the transaction has already been signed, because its
+// serialization would already include the signatures.
+//
+// Witness data is counted at a quarter of its size towards the virtual size.
+// Chains that do not support segwit should not use witness script types.
+func EstimateSize(tx utxo.Tx, scripts []InputScript) (int, int, error) {
+	if tx, ok := tx.(signedTx); ok && tx.IsSigned() {
+		return 0, 0, fmt.Errorf("bad tx: expected unsigned tx")
+	}
+	inputs, err := tx.Inputs()
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad inputs: %v", err)
+	}
+	if len(inputs) != len(scripts) {
+		return 0, 0, fmt.Errorf("bad scripts: expected %v scripts, got %v scripts", len(inputs), len(scripts))
+	}
+	serial, err := tx.Serialize()
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad tx: %v", err)
+	}
+	if btcTx, ok := tx.(*Tx); ok {
+		// The size of P2TR signatures depends on their sighash type, so
+		// default to the sighash type of the input.
+		scripts = append([]InputScript{}, scripts...)
+		for i := range scripts {
+			if scripts[i].Type == ScriptP2TR && scripts[i].SigHashType == utxo.DefaultSigHashType {
+				scripts[i].SigHashType = utxo.SigHashType(btcTx.sigHashType(i))
+			}
+		}
+	}
+
+	// The unsigned transaction already includes empty sig scripts, so only
+	// the signed sig scripts and witnesses need to be added.
+	baseSize := len(serial)
+	witnessSize := 0
+	hasWitness := false
+	for i, script := range scripts {
+		sigScriptSize, inputWitnessSize, err := script.sizes()
+		if err != nil {
+			return 0, 0, fmt.Errorf("bad script %v: %v", i, err)
+		}
+		baseSize += varSize(sigScriptSize) - varSize(0)
+		witnessSize += inputWitnessSize
+		if script.IsWitness() {
+			hasWitness = true
+		}
+	}
+	if hasWitness {
+		// Segwit transactions include a marker and a flag, and an empty
+		// witness for each input that does not need a witness.
+		witnessSize += 2
+		for _, script := range scripts {
+			if !script.IsWitness() {
+				witnessSize += wire.VarIntSerializeSize(0)
+			}
+		}
+	}
+
+	weight := 4*baseSize + witnessSize
+	return baseSize + witnessSize, (weight + 3) / 4, nil
+}
+
+// signedTx is implemented by transactions that know whether they have been
+// signed. The transactions of all Bitcoin-family chains implement it.
+type signedTx interface {
+	IsSigned() bool
+}
+
+// varSize returns the size of a variable length byte slice, including its
+// length prefix.
+func varSize(n int) int {
+	return wire.VarIntSerializeSize(uint64(n)) + n
+}
+
+// pushSize returns the size of a script push of n bytes, including the push
+// opcode. Empty pushes use OP_0.
+func pushSize(n int) int {
+	switch {
+	case n <= txscript.OP_DATA_75:
+		return 1 + n
+	case n <= 0xff:
+		return 2 + n
+	case n <= 0xffff:
+		return 3 + n
+	default:
+		return 5 + n
+	}
+}
+
+// smallIntSize returns the size of a script push of a small integer.
+func smallIntSize(n int) int {
+	if n <= 16 {
+		return 1
+	}
+	return pushSize(1)
+}
+
+// EstimateSizeWithoutWitness returns an upper bound on the serialized size,
+// and virtual size, of the given unsigned transaction after it has been signed.
+// It is used by chains that do not support segwit, and returns an error if any
+// of the input scripts needs a witness. The serialized size and virtual size
+// are always equal.
+func EstimateSizeWithoutWitness(tx utxo.Tx, scripts []InputScript) (int, int, error) {
+	for i, script := range scripts {
+		if script.IsWitness() {
+			return 0, 0, fmt.Errorf("bad script %v: %v is not supported", i, script.Type)
+		}
+	}
+	return EstimateSize(tx, scripts)
+}
@@ -0,0 +1,269 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Size", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	privKey := id.NewPrivKey()
+	pubKey := (*btcec.PublicKey)(&privKey.PublicKey).SerializeCompressed()
+	uncompressedPubKey := (*btcec.PublicKey)(&privKey.PublicKey).SerializeUncompressed()
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	uncompressedPKHAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(uncompressedPubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	wpkhAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+
+	input := func(i byte, addr btcutil.Address) utxo.Input {
+		script, err := txscript.PayToAddrScript(addr)
+		Expect(err).ToNot(HaveOccurred())
+		return utxo.Input{Output: utxo.Output{
+			Outpoint: utxo.Outpoint{
+				Hash:  pack.NewBytes(append(make([]byte, 31), i)),
+				Index: pack.NewU32(0),
+			},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(script),
+		}}
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(50000))},
+		{To: address.Address(wpkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(40000))},
+	}
+
+	// signedSizes signs the transaction using the given pubkey, and returns
+	// its serialized size and virtual size.
+	signedSizes := func(tx utxo.Tx, pubKey []byte) (int, int) {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		signatures := make([]pack.Bytes65, len(sighashes))
+		for i := range sighashes {
+			hash := id.Hash(sighashes[i])
+			signature, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures[i] = pack.NewBytes65(signature)
+		}
+		Expect(tx.Sign(signatures, pack.NewBytes(pubKey))).To(Succeed())
+		serial, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+
+		msgTx := new(wire.MsgTx)
+		Expect(msgTx.Deserialize(bytes.NewReader(serial))).To(Succeed())
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(msgTx))
+		return len(serial), int((weight + 3) / 4)
+	}
+
+	Context("when estimating the size of signed transactions", func() {
+		It("should return an upper bound for P2PKH inputs", func() {
+			inputs := []utxo.Input{input(1, pkhAddr), input(2, pkhAddr)}
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}, {Type: bitcoin.ScriptP2PKH}}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			size, vsize, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(vsize))
+
+			actualSize, actualVSize := signedSizes(tx, pubKey)
+			Expect(size).To(BeNumerically(">=", actualSize))
+			Expect(size).To(BeNumerically("<=", actualSize+4))
+			Expect(vsize).To(BeNumerically(">=", actualVSize))
+		})
+
+		It("should return an upper bound for P2PKH inputs with uncompressed pubkeys", func() {
+			inputs := []utxo.Input{input(1, uncompressedPKHAddr), input(2, uncompressedPKHAddr)}
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH, Uncompressed: true}, {Type: bitcoin.ScriptP2PKH, Uncompressed: true}}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			size, vsize, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(vsize))
+
+			actualSize, _ := signedSizes(tx, uncompressedPubKey)
+			Expect(size).To(BeNumerically(">=", actualSize))
+			Expect(size).To(BeNumerically("<=", actualSize+4))
+		})
+
+		It("should return an upper bound for P2WPKH inputs", func() {
+			inputs := []utxo.Input{input(1, wpkhAddr), input(2, wpkhAddr)}
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2WPKH}, {Type: bitcoin.ScriptP2WPKH}}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			size, vsize, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vsize).To(BeNumerically("<", size))
+
+			actualSize, actualVSize := signedSizes(tx, pubKey)
+			Expect(size).To(BeNumerically(">=", actualSize))
+			Expect(size).To(BeNumerically("<=", actualSize+4))
+			Expect(vsize).To(BeNumerically(">=", actualVSize))
+			Expect(vsize).To(BeNumerically("<=", actualVSize+1))
+		})
+
+		It("should return an upper bound for mixed inputs", func() {
+			inputs := []utxo.Input{input(1, pkhAddr), input(2, wpkhAddr)}
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}, {Type: bitcoin.ScriptP2WPKH}}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			size, vsize, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+
+			actualSize, actualVSize := signedSizes(tx, pubKey)
+			Expect(size).To(BeNumerically(">=", actualSize))
+			Expect(size).To(BeNumerically("<=", actualSize+4))
+			Expect(vsize).To(BeNumerically(">=", actualVSize))
+		})
+
+		It("should return the size of multisig inputs", func() {
+			inputs := []utxo.Input{input(1, pkhAddr)}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			// A 2-of-3 redeem script is 105 bytes, so the sig script is 1 + 2 *
+			// 74 + 2 + 105 = 256 bytes, which needs a 3 byte length prefix.
+			size, vsize, err := bitcoin.EstimateSize(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2SHMultisig, M: 2, N: 3}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(len(serial) + 256 + 2))
+			Expect(vsize).To(Equal(size))
+
+			// The witness has 4 items: an empty item, 2 signatures, and the 105
+			// byte witness script.
+			size, vsize, err = bitcoin.EstimateSize(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2WSH, M: 2, N: 3}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(len(serial) + 2 + 1 + 1 + 2*74 + 106))
+			Expect(vsize).To(Equal(len(serial) + (2+1+1+2*74+106+3)/4))
+		})
+
+		It("should return an error for bad scripts", func() {
+			inputs := []utxo.Input{input(1, pkhAddr)}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = bitcoin.EstimateSize(tx, []bitcoin.InputScript{})
+			Expect(err).To(HaveOccurred())
+			_, _, err = bitcoin.EstimateSize(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2SHMultisig, M: 3, N: 2}})
+			Expect(err).To(HaveOccurred())
+			_, _, err = bitcoin.EstimateSizeWithoutWitness(tx, []bitcoin.InputScript{{Type: bitcoin.ScriptP2WPKH}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error for signed transactions", func() {
+			inputs := []utxo.Input{input(1, pkhAddr)}
+			scripts := []bitcoin.InputScript{{Type: bitcoin.ScriptP2PKH}}
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+
+			signedSizes(tx, pubKey)
+			_, _, err = bitcoin.EstimateSize(tx, scripts)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when inferring the script of an input", func() {
+		pubKeys := make([]*btcutil.AddressPubKey, 3)
+		for i := range pubKeys {
+			pubKey, err := btcutil.NewAddressPubKey((*btcec.PublicKey)(&id.NewPrivKey().PublicKey).SerializeCompressed(), params)
+			if err != nil {
+				panic(err)
+			}
+			pubKeys[i] = pubKey
+		}
+		multisigScript, err := txscript.MultiSigScript(pubKeys, 2)
+		if err != nil {
+			panic(err)
+		}
+		shAddr, err := btcutil.NewAddressScriptHash(multisigScript, params)
+		if err != nil {
+			panic(err)
+		}
+		witnessScriptHash := sha256.Sum256(multisigScript)
+		wshAddr, err := btcutil.NewAddressWitnessScriptHash(witnessScriptHash[:], params)
+		if err != nil {
+			panic(err)
+		}
+
+		It("should use the pubkey script of inputs without a sig script", func() {
+			script, err := bitcoin.InputScriptFromInput(input(1, wpkhAddr))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script).To(Equal(bitcoin.InputScript{Type: bitcoin.ScriptP2WPKH}))
+		})
+
+		It("should use the sig script of multisig inputs", func() {
+			shInput := input(1, shAddr)
+			shInput.SigScript = multisigScript
+			script, err := bitcoin.InputScriptFromInput(shInput)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script).To(Equal(bitcoin.InputScript{Type: bitcoin.ScriptP2SHMultisig, M: 2, N: 3, ScriptSize: len(multisigScript)}))
+
+			wshInput := input(2, wshAddr)
+			wshInput.SigScript = multisigScript
+			script, err = bitcoin.InputScriptFromInput(wshInput)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script).To(Equal(bitcoin.InputScript{Type: bitcoin.ScriptP2WSH, M: 2, N: 3, ScriptSize: len(multisigScript)}))
+		})
+
+		It("should use the sig script of single-sig inputs", func() {
+			pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+			Expect(err).ToNot(HaveOccurred())
+			redeemScript := append([]byte{txscript.OP_DROP}, pkhScript...)
+			shInput := input(1, shAddr)
+			shInput.SigScript = redeemScript
+			script, err := bitcoin.InputScriptFromInput(shInput)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script).To(Equal(bitcoin.InputScript{Type: bitcoin.ScriptP2SHSingleSig, ScriptSize: len(redeemScript)}))
+
+			// The sig script is <sig> <pubkey> <redeem script>.
+			tx, err := txBuilder.BuildTx([]utxo.Input{shInput}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			size, _, err := bitcoin.EstimateSize(tx, []bitcoin.InputScript{script})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(len(serial) + 74 + 34 + 1 + len(redeemScript)))
+		})
+
+		It("should not assume witness scripts on chains without segwit", func() {
+			_, err := bitcoin.InputScriptFromInputWithoutWitness(input(1, shAddr))
+			Expect(err).To(HaveOccurred())
+			_, err = bitcoin.InputScriptFromInputWithoutWitness(input(1, wpkhAddr))
+			Expect(err).To(HaveOccurred())
+
+			shInput := input(1, shAddr)
+			shInput.SigScript = multisigScript
+			script, err := bitcoin.InputScriptFromInputWithoutWitness(shInput)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script.Type).To(Equal(bitcoin.ScriptP2SHMultisig))
+		})
+	})
+})
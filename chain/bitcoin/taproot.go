@@ -0,0 +1,303 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/pack"
+)
+
+const (
+	// SigHashDefault is the taproot sighash type that commits to the whole
+	// transaction, in the same way as SIGHASH_ALL, but allows the sighash type
+	// to be omitted from the signature (see BIP341).
+	SigHashDefault = txscript.SigHashType(0x00)
+
+	// SchnorrSignatureSize is the size of a BIP340 Schnorr signature.
+	SchnorrSignatureSize = 64
+
+	// TaprootWitnessVersion is the witness version of taproot outputs.
+	TaprootWitnessVersion = 1
+)
+
+// AddressTaproot is a pay-to-taproot (P2TR) address. It encodes the x-only
+// output key of the taproot output using bech32m (see BIP350). It implements
+// the btcutil.Address interface, which does not support taproot addresses.
+type AddressTaproot struct {
+	hrp       string
+	outputKey [32]byte
+}
+
+// NewAddressTaproot returns a new P2TR address for the given 32 byte x-only
+// output key.
+func NewAddressTaproot(outputKey []byte, params *chaincfg.Params) (*AddressTaproot, error) {
+	if len(outputKey) != 32 {
+		return nil, fmt.Errorf("bad output key: expected 32 bytes, got %v bytes", len(outputKey))
+	}
+	if params.Bech32HRPSegwit == "" {
+		return nil, fmt.Errorf("bad params: %v does not support segwit", params.Name)
+	}
+	addr := &AddressTaproot{hrp: params.Bech32HRPSegwit}
+	copy(addr.outputKey[:], outputKey)
+	return addr, nil
+}
+
+// EncodeAddress returns the bech32m encoding of the address.
+func (addr *AddressTaproot) EncodeAddress() string {
+	encoded, err := EncodeSegwitAddress(addr.hrp, TaprootWitnessVersion, addr.outputKey[:])
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// String returns the bech32m encoding of the address.
+func (addr *AddressTaproot) String() string {
+	return addr.EncodeAddress()
+}
+
+// ScriptAddress returns the x-only output key of the address.
+func (addr *AddressTaproot) ScriptAddress() []byte {
+	return addr.outputKey[:]
+}
+
+// IsForNet returns true if the address is for the given network.
+func (addr *AddressTaproot) IsForNet(params *chaincfg.Params) bool {
+	return addr.hrp == params.Bech32HRPSegwit
+}
+
+// TaprootOutputKey returns the x-only output key of a taproot output that can
+// only be spent using the key path (see BIP86). The internal key can be a 33
+// byte compressed pubkey, or a 32 byte x-only pubkey. The signer must tweak its
+// private key in the same way before signing.
+func TaprootOutputKey(internalKey pack.Bytes) (pack.Bytes, error) {
+	switch len(internalKey) {
+	case btcec.PubKeyBytesLenCompressed:
+		if _, err := btcec.ParsePubKey(internalKey, btcec.S256()); err != nil {
+			return pack.Bytes{}, fmt.Errorf("bad internal key: %v", err)
+		}
+		internalKey = internalKey[1:]
+	case 32:
+	default:
+		return pack.Bytes{}, fmt.Errorf("bad internal key: expected 32 or 33 bytes, got %v bytes", len(internalKey))
+	}
+
+	curve := btcec.S256()
+	px, py, err := liftX(internalKey)
+	if err != nil {
+		return pack.Bytes{}, fmt.Errorf("bad internal key: %v", err)
+	}
+	tweak := taggedHash("TapTweak", internalKey)
+	if new(big.Int).SetBytes(tweak[:]).Cmp(curve.N) >= 0 {
+		return pack.Bytes{}, fmt.Errorf("bad internal key: tweak is out of range")
+	}
+	tweakX, tweakY := curve.ScalarBaseMult(tweak[:])
+	qx, qy := curve.Add(px, py, tweakX, tweakY)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return pack.Bytes{}, fmt.Errorf("bad internal key: output key is infinity")
+	}
+	outputKey := make([]byte, 32)
+	qxBytes := qx.Bytes()
+	copy(outputKey[32-len(qxBytes):], qxBytes)
+	return pack.NewBytes(outputKey), nil
+}
+
+// TaprootAddress returns the P2TR address of a taproot output that can only be
+// spent using the key path (see BIP86).
+func TaprootAddress(internalKey pack.Bytes, params *chaincfg.Params) (address.Address, error) {
+	outputKey, err := TaprootOutputKey(internalKey)
+	if err != nil {
+		return address.Address(""), err
+	}
+	addr, err := NewAddressTaproot(outputKey, params)
+	if err != nil {
+		return address.Address(""), err
+	}
+	return address.Address(addr.EncodeAddress()), nil
+}
+
+// DecodeAddress decodes the given address. It supports all of the addresses
+// supported by btcutil.DecodeAddress, as well as P2TR addresses.
+func DecodeAddress(addr string, params *chaincfg.Params) (btcutil.Address, error) {
+	if params.Bech32HRPSegwit != "" {
+		version, program, err := DecodeSegwitAddress(params.Bech32HRPSegwit, addr)
+		if err == nil && version == TaprootWitnessVersion && len(program) == 32 {
+			return NewAddressTaproot(program, params)
+		}
+	}
+	return btcutil.DecodeAddress(addr, params)
+}
+
+// PayToAddrScript returns the pubkey script that pays to the given address. It
+// supports all of the addresses supported by txscript.PayToAddrScript, as well
+// as P2TR addresses.
+func PayToAddrScript(addr btcutil.Address) ([]byte, error) {
+	if addr, ok := addr.(*AddressTaproot); ok {
+		return txscript.NewScriptBuilder().
+			AddOp(txscript.OP_1).
+			AddData(addr.ScriptAddress()).
+			Script()
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// IsPayToTaproot returns true if the script is a P2TR pubkey script.
+func IsPayToTaproot(script []byte) bool {
+	return len(script) == 34 && script[0] == txscript.OP_1 && script[1] == txscript.OP_DATA_32
+}
+
+// TaprootSigHash returns the BIP341 sighash for spending the input at the given
+// index using the key path. The outputs spent by all inputs of the transaction
+// must be given, in the same order as the inputs.
+func TaprootSigHash(msgTx *wire.MsgTx, prevOuts []*wire.TxOut, i int, hashType txscript.SigHashType) ([]byte, error) {
+	if len(prevOuts) != len(msgTx.TxIn) {
+		return nil, fmt.Errorf("expected %v previous outputs, got %v previous outputs", len(msgTx.TxIn), len(prevOuts))
+	}
+	if i < 0 || i >= len(msgTx.TxIn) {
+		return nil, fmt.Errorf("bad input: expected index < %v, got index %v", len(msgTx.TxIn), i)
+	}
+	switch hashType {
+	case SigHashDefault, txscript.SigHashAll, txscript.SigHashNone, txscript.SigHashSingle,
+		txscript.SigHashAll | txscript.SigHashAnyOneCanPay, txscript.SigHashNone | txscript.SigHashAnyOneCanPay, txscript.SigHashSingle | txscript.SigHashAnyOneCanPay:
+	default:
+		return nil, fmt.Errorf("bad sighash type %v", hashType)
+	}
+	anyoneCanPay := hashType&txscript.SigHashAnyOneCanPay != 0
+	outputType := hashType & 0x03
+
+	// The epoch is always zero.
+	msg := new(bytes.Buffer)
+	msg.WriteByte(0)
+
+	// Transaction data.
+	msg.WriteByte(byte(hashType))
+	binary.Write(msg, binary.LittleEndian, msgTx.Version)
+	binary.Write(msg, binary.LittleEndian, msgTx.LockTime)
+	if !anyoneCanPay {
+		prevOutpoints, amounts, pubKeyScripts, sequences := sha256.New(), sha256.New(), sha256.New(), sha256.New()
+		for j, txIn := range msgTx.TxIn {
+			prevOutpoints.Write(txIn.PreviousOutPoint.Hash[:])
+			binary.Write(prevOutpoints, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+			binary.Write(amounts, binary.LittleEndian, prevOuts[j].Value)
+			wire.WriteVarBytes(pubKeyScripts, 0, prevOuts[j].PkScript)
+			binary.Write(sequences, binary.LittleEndian, txIn.Sequence)
+		}
+		msg.Write(prevOutpoints.Sum(nil))
+		msg.Write(amounts.Sum(nil))
+		msg.Write(pubKeyScripts.Sum(nil))
+		msg.Write(sequences.Sum(nil))
+	}
+	if outputType != txscript.SigHashNone && outputType != txscript.SigHashSingle {
+		outputs := sha256.New()
+		for _, txOut := range msgTx.TxOut {
+			if err := wire.WriteTxOut(outputs, 0, msgTx.Version, txOut); err != nil {
+				return nil, err
+			}
+		}
+		msg.Write(outputs.Sum(nil))
+	}
+
+	// Data about this input. Key path spends have no annex, and no extension.
+	msg.WriteByte(0)
+	if anyoneCanPay {
+		txIn := msgTx.TxIn[i]
+		msg.Write(txIn.PreviousOutPoint.Hash[:])
+		binary.Write(msg, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+		binary.Write(msg, binary.LittleEndian, prevOuts[i].Value)
+		wire.WriteVarBytes(msg, 0, prevOuts[i].PkScript)
+		binary.Write(msg, binary.LittleEndian, txIn.Sequence)
+	} else {
+		binary.Write(msg, binary.LittleEndian, uint32(i))
+	}
+
+	// Data about this output.
+	if outputType == txscript.SigHashSingle {
+		if i >= len(msgTx.TxOut) {
+			return nil, fmt.Errorf("bad input %v: no corresponding output for SIGHASH_SINGLE", i)
+		}
+		output := new(bytes.Buffer)
+		if err := wire.WriteTxOut(output, 0, msgTx.Version, msgTx.TxOut[i]); err != nil {
+			return nil, err
+		}
+		outputHash := sha256.Sum256(output.Bytes())
+		msg.Write(outputHash[:])
+	}
+
+	sighash := taggedHash("TapSighash", msg.Bytes())
+	return sighash[:], nil
+}
+
+// VerifySchnorr returns true if the BIP340 Schnorr signature of the hash is
+// valid for the given x-only pubkey.
+func VerifySchnorr(pubKey []byte, hash []byte, signature []byte) bool {
+	if len(pubKey) != 32 || len(signature) != SchnorrSignatureSize {
+		return false
+	}
+	curve := btcec.S256()
+	px, py, err := liftX(pubKey)
+	if err != nil {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if r.Cmp(curve.P) >= 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+	challenge := taggedHash("BIP0340/challenge", signature[:32], pubKey, hash)
+	e := new(big.Int).SetBytes(challenge[:])
+	e.Mod(e, curve.N)
+
+	// R = s⋅G - e⋅P
+	sx, sy := curve.ScalarBaseMult(signature[32:])
+	ex, ey := curve.ScalarMult(px, py, new(big.Int).Sub(curve.N, e).Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+// liftX returns the point with the given x-coordinate and an even
+// y-coordinate (see BIP340).
+func liftX(x []byte) (*big.Int, *big.Int, error) {
+	curve := btcec.S256()
+	px := new(big.Int).SetBytes(x)
+	if px.Cmp(curve.P) >= 0 {
+		return nil, nil, fmt.Errorf("x-coordinate is out of range")
+	}
+	// y^2 = x^3 + 7
+	c := new(big.Int).Exp(px, big.NewInt(3), curve.P)
+	c.Add(c, big.NewInt(7))
+	c.Mod(c, curve.P)
+	py := new(big.Int).Exp(c, curve.QPlus1Div4(), curve.P)
+	if new(big.Int).Exp(py, big.NewInt(2), curve.P).Cmp(c) != 0 {
+		return nil, nil, fmt.Errorf("x-coordinate is not on the curve")
+	}
+	if py.Bit(0) == 1 {
+		py.Sub(curve.P, py)
+	}
+	return px, py, nil
+}
+
+// taggedHash returns the BIP340 tagged hash of the given data.
+func taggedHash(tag string, data ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	sum := [32]byte{}
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
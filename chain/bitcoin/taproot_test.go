@@ -0,0 +1,248 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Taproot", func() {
+	curve := btcec.S256()
+
+	mustDecodeHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			panic(err)
+		}
+		return b
+	}
+	taggedHash := func(tag string, data ...[]byte) []byte {
+		tagHash := sha256.Sum256([]byte(tag))
+		h := sha256.New()
+		h.Write(tagHash[:])
+		h.Write(tagHash[:])
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+	bytes32 := func(x *big.Int) []byte {
+		b := make([]byte, 32)
+		xBytes := x.Bytes()
+		copy(b[32-len(xBytes):], xBytes)
+		return b
+	}
+
+	// signSchnorr returns the BIP340 signature of the message.
+	signSchnorr := func(privKey *big.Int, msg []byte, aux []byte) []byte {
+		px, py := curve.ScalarBaseMult(bytes32(privKey))
+		d := new(big.Int).Set(privKey)
+		if py.Bit(0) == 1 {
+			d.Sub(curve.N, d)
+		}
+		t := taggedHash("BIP0340/aux", aux)
+		for i, b := range bytes32(d) {
+			t[i] ^= b
+		}
+		k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, bytes32(px), msg))
+		k.Mod(k, curve.N)
+		rx, ry := curve.ScalarBaseMult(bytes32(k))
+		if ry.Bit(0) == 1 {
+			k.Sub(curve.N, k)
+		}
+		e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", bytes32(rx), bytes32(px), msg))
+		e.Mod(e, curve.N)
+		s := new(big.Int).Mul(e, d)
+		s.Add(s, k)
+		s.Mod(s, curve.N)
+		return append(bytes32(rx), bytes32(s)...)
+	}
+
+	// tweakPrivKey returns the private key of the BIP86 output key.
+	tweakPrivKey := func(privKey *big.Int) *big.Int {
+		px, py := curve.ScalarBaseMult(bytes32(privKey))
+		d := new(big.Int).Set(privKey)
+		if py.Bit(0) == 1 {
+			d.Sub(curve.N, d)
+		}
+		t := new(big.Int).SetBytes(taggedHash("TapTweak", bytes32(px)))
+		d.Add(d, t)
+		return d.Mod(d, curve.N)
+	}
+
+	Context("when encoding and decoding segwit addresses", func() {
+		It("should support bech32 and bech32m addresses", func() {
+			for _, vector := range []struct {
+				addr    string
+				version byte
+				program string
+			}{
+				{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+				{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", 1, "751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+				{"BC1SW50QGDZ25J", 16, "751e"},
+				{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", 2, "751e76e8199196d454941c45d1b3a323"},
+				{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+			} {
+				version, program, err := bitcoin.DecodeSegwitAddress("bc", vector.addr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(version).To(Equal(vector.version))
+				Expect(hex.EncodeToString(program)).To(Equal(vector.program))
+
+				encoded, err := bitcoin.EncodeSegwitAddress("bc", version, program)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(encoded).To(Equal(strings.ToLower(vector.addr)))
+			}
+		})
+
+		It("should reject addresses with the wrong checksum", func() {
+			// A v1 address with a bech32 checksum.
+			_, _, err := bitcoin.DecodeSegwitAddress("bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd")
+			Expect(err).To(HaveOccurred())
+			// A v0 address with a bech32m checksum.
+			_, _, err = bitcoin.DecodeSegwitAddress("bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when getting the address of an internal key", func() {
+		It("should return the BIP86 address", func() {
+			internalKey := mustDecodeHex("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+			outputKey, err := bitcoin.TaprootOutputKey(internalKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hex.EncodeToString(outputKey)).To(Equal("a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"))
+
+			addr, err := bitcoin.TaprootAddress(internalKey, &chaincfg.MainNetParams)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr).To(Equal(address.Address("bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr")))
+
+			encodeDecoder := bitcoin.NewAddressEncodeDecoder(&chaincfg.MainNetParams)
+			rawAddr, err := encodeDecoder.DecodeAddress(addr)
+			Expect(err).ToNot(HaveOccurred())
+			encoded, err := encodeDecoder.EncodeAddress(address.RawAddress(rawAddr))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(Equal(addr))
+		})
+	})
+
+	Context("when verifying Schnorr signatures", func() {
+		It("should accept valid signatures and reject invalid signatures", func() {
+			pubKey := mustDecodeHex("F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9")
+			msg := make([]byte, 32)
+			signature := mustDecodeHex("E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0")
+			Expect(signSchnorr(big.NewInt(3), msg, make([]byte, 32))).To(Equal(signature))
+			Expect(bitcoin.VerifySchnorr(pubKey, msg, signature)).To(BeTrue())
+
+			signature[63] ^= 1
+			Expect(bitcoin.VerifySchnorr(pubKey, msg, signature)).To(BeFalse())
+		})
+	})
+
+	Context("when spending a taproot output", func() {
+		It("should sign the input using the key path", func() {
+			params := &chaincfg.RegressionNetParams
+			privKey, err := btcec.NewPrivateKey(curve)
+			Expect(err).ToNot(HaveOccurred())
+			internalKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+			outputKey, err := bitcoin.TaprootOutputKey(internalKey)
+			Expect(err).ToNot(HaveOccurred())
+			addr, err := bitcoin.TaprootAddress(internalKey, params)
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := bitcoin.DecodeAddress(string(addr), params)
+			Expect(err).ToNot(HaveOccurred())
+			pubKeyScript, err := bitcoin.PayToAddrScript(decoded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bitcoin.IsPayToTaproot(pubKeyScript)).To(BeTrue())
+
+			inputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(pubKeyScript),
+			}}}
+			recipients := []utxo.Recipient{{To: addr, Value: pack.NewU256FromU64(pack.NewU64(99000))}}
+			tx, err := bitcoin.NewTxBuilder(params).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs[0].PubKeyScript).To(Equal(pack.NewBytes(pubKeyScript)))
+
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signature := signSchnorr(tweakPrivKey(privKey.D), sighashes[0][:], make([]byte, 32))
+			signature65 := pack.Bytes65{}
+			copy(signature65[:], signature)
+			Expect(tx.Sign([]pack.Bytes65{signature65}, internalKey)).To(Succeed())
+
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			msgTx := new(wire.MsgTx)
+			Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+			witness := msgTx.TxIn[0].Witness
+			Expect(witness).To(HaveLen(1))
+			Expect(witness[0]).To(Equal(signature))
+			Expect(bitcoin.VerifySchnorr(outputKey, sighashes[0][:], witness[0])).To(BeTrue())
+		})
+
+		It("should estimate the size of the input", func() {
+			script := bitcoin.InputScriptFromPubKeyScript(append([]byte{txscript.OP_1, txscript.OP_DATA_32}, make([]byte, 32)...))
+			Expect(script.Type).To(Equal(bitcoin.ScriptP2TR))
+			Expect(script.IsWitness()).To(BeTrue())
+		})
+
+		It("should estimate the size of signatures with a sighash type", func() {
+			params := &chaincfg.RegressionNetParams
+			privKey, err := btcec.NewPrivateKey(curve)
+			Expect(err).ToNot(HaveOccurred())
+			internalKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+			addr, err := bitcoin.TaprootAddress(internalKey, params)
+			Expect(err).ToNot(HaveOccurred())
+			decoded, err := bitcoin.DecodeAddress(string(addr), params)
+			Expect(err).ToNot(HaveOccurred())
+			pubKeyScript, err := bitcoin.PayToAddrScript(decoded)
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(pubKeyScript),
+			}}}
+			recipients := []utxo.Recipient{{To: addr, Value: pack.NewU256FromU64(pack.NewU64(99000))}}
+			tx, err := bitcoin.NewTxBuilder(params).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			scripts := []bitcoin.InputScript{bitcoin.InputScriptFromPubKeyScript(pubKeyScript)}
+			defaultSize, _, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Signatures that do not use SIGHASH_DEFAULT include the sighash
+			// type.
+			Expect(tx.(utxo.InputSigner).SetSigHashType(0, utxo.SigHashType(txscript.SigHashAll))).To(Succeed())
+			size, _, err := bitcoin.EstimateSize(tx, scripts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(defaultSize + 1))
+
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signature := signSchnorr(tweakPrivKey(privKey.D), sighashes[0][:], make([]byte, 32))
+			signature65 := pack.Bytes65{}
+			copy(signature65[:], signature)
+			Expect(tx.Sign([]pack.Bytes65{signature65}, internalKey)).To(Succeed())
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(serialized)).To(Equal(size))
+		})
+	})
+})
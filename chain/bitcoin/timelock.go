@@ -0,0 +1,113 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxRelativeLockTimeSeconds is the maximum number of seconds for which an
+// input can be relatively locked (see BIP68).
+const MaxRelativeLockTimeSeconds = wire.SequenceLockTimeMask << wire.SequenceLockTimeGranularity
+
+// WithLockTime returns a copy of the transaction builder that builds
+// transactions with the given lock time (nLockTime). Lock times less than
+// 500000000 are block heights, and all other lock times are UNIX timestamps.
+// Unless sequence numbers are given (see WithSequences), inputs use the
+// sequence number MaxTxInSequenceNum - 1 so that the lock time is enforced.
+func (txBuilder TxBuilder) WithLockTime(lockTime uint32) TxBuilder {
+	txBuilder.lockTime = lockTime
+	return txBuilder
+}
+
+// WithSequences returns a copy of the transaction builder that builds
+// transactions with the given sequence number (nSequence) for each input. It
+// can be used to relatively lock inputs (see RelativeLockTimeBlocks and
+// RelativeLockTimeSeconds). The number of sequence numbers must be the same as
+// the number of inputs, and the sequence numbers take precedence over the
+// sequence numbers used to signal replace-by-fee.
+func (txBuilder TxBuilder) WithSequences(sequences []uint32) TxBuilder {
+	txBuilder.sequences = sequences
+	return txBuilder
+}
+
+// SetTimelocks sets the lock time of the transaction, and the sequence numbers
+// of its inputs. If there are no sequence numbers, and the lock time is not
+// zero, inputs that do not already have a sequence number use the sequence
+// number MaxTxInSequenceNum - 1 so that the lock time is enforced. It can be
+// used by any Bitcoin-family chain.
+func SetTimelocks(msgTx *wire.MsgTx, lockTime uint32, sequences []uint32) error {
+	if len(sequences) > 0 && len(sequences) != len(msgTx.TxIn) {
+		return fmt.Errorf("expected %v sequences, got %v sequences", len(msgTx.TxIn), len(sequences))
+	}
+	msgTx.LockTime = lockTime
+	for i, txIn := range msgTx.TxIn {
+		switch {
+		case len(sequences) > 0:
+			txIn.Sequence = sequences[i]
+		case lockTime != 0 && txIn.Sequence == wire.MaxTxInSequenceNum:
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	}
+	return nil
+}
+
+// RelativeLockTimeBlocks returns the sequence number that relatively locks an
+// input until the output that it spends has the given number of
+// confirmations (see BIP68).
+func RelativeLockTimeBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// RelativeLockTimeSeconds returns the sequence number that relatively locks an
+// input until the given number of seconds have passed since the output that it
+// spends was confirmed (see BIP68). The number of seconds is rounded up to a
+// multiple of 512.
+func RelativeLockTimeSeconds(seconds uint32) (uint32, error) {
+	if seconds > MaxRelativeLockTimeSeconds {
+		return 0, fmt.Errorf("expected seconds <= %v, got seconds = %v", MaxRelativeLockTimeSeconds, seconds)
+	}
+	granularity := uint32(1) << wire.SequenceLockTimeGranularity
+	return wire.SequenceLockTimeIsSeconds | (seconds+granularity-1)>>wire.SequenceLockTimeGranularity, nil
+}
+
+// CheckLockTimeVerifyScript returns a script that can only be spent by
+// transactions with a lock time of at least the given lock time (see BIP65),
+// and that satisfy the given script:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <script>
+//
+// For example, the given script can be the pubkey script of a P2PKH address,
+// in which case the returned script can be the sig script of an input that is
+// signed in the same way as a P2PKH input. Both lock times must either be
+// block heights, or UNIX timestamps.
+func CheckLockTimeVerifyScript(lockTime uint32, script []byte) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(lockTime))
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddOps(script)
+	return builder.Script()
+}
+
+// CheckSequenceVerifyScript returns a script that can only be spent by inputs
+// that are relatively locked for at least as long as the given sequence number
+// (see BIP112), and that satisfy the given script:
+//
+//	<sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <script>
+//
+// The sequence number is usually returned by RelativeLockTimeBlocks, or
+// RelativeLockTimeSeconds, and the input must use a sequence number with the
+// same type of relative lock (see WithSequences).
+func CheckSequenceVerifyScript(sequence uint32, script []byte) ([]byte, error) {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		return nil, fmt.Errorf("bad sequence: relative lock time is disabled")
+	}
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(sequence))
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddOps(script)
+	return builder.Script()
+}
@@ -0,0 +1,160 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timelocks", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoin.NewTxBuilder(params)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr)
+	if err != nil {
+		panic(err)
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(99000))},
+	}
+
+	newInput := func(pubKeyScript, sigScript []byte) utxo.Input {
+		return utxo.Input{
+			Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(pubKeyScript),
+			},
+			SigScript: pack.NewBytes(sigScript),
+		}
+	}
+
+	// signAndExecute signs the transaction and executes the script of its
+	// input.
+	signAndExecute := func(tx utxo.Tx, input utxo.Input) error {
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		hash := id.Hash(sighashes[0])
+		signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, pubKey)).To(Succeed())
+
+		serialized, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+		msgTx := new(wire.MsgTx)
+		Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+		engine, err := txscript.NewEngine(input.PubKeyScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx), int64(input.Value.Int().Uint64()))
+		Expect(err).ToNot(HaveOccurred())
+		return engine.Execute()
+	}
+
+	Context("when spending a CHECKLOCKTIMEVERIFY script", func() {
+		script, err := bitcoin.CheckLockTimeVerifyScript(1000, pkhScript)
+		if err != nil {
+			panic(err)
+		}
+		shAddr, err := btcutil.NewAddressScriptHash(script, params)
+		if err != nil {
+			panic(err)
+		}
+		shScript, err := txscript.PayToAddrScript(shAddr)
+		if err != nil {
+			panic(err)
+		}
+		input := newInput(shScript, script)
+
+		It("should succeed after the lock time", func() {
+			tx, err := txBuilder.WithLockTime(1000).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signAndExecute(tx, input)).To(Succeed())
+		})
+
+		It("should fail before the lock time", func() {
+			tx, err := txBuilder.WithLockTime(999).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signAndExecute(tx, input)).ToNot(Succeed())
+		})
+	})
+
+	Context("when spending a CHECKSEQUENCEVERIFY script", func() {
+		sequence := bitcoin.RelativeLockTimeBlocks(10)
+		script, err := bitcoin.CheckSequenceVerifyScript(sequence, pkhScript)
+		if err != nil {
+			panic(err)
+		}
+		scriptHash := sha256.Sum256(script)
+		wshAddr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+		if err != nil {
+			panic(err)
+		}
+		wshScript, err := txscript.PayToAddrScript(wshAddr)
+		if err != nil {
+			panic(err)
+		}
+		input := newInput(wshScript, script)
+
+		It("should succeed after the relative lock time", func() {
+			tx, err := txBuilder.WithSequences([]uint32{sequence}).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signAndExecute(tx, input)).To(Succeed())
+		})
+
+		It("should fail before the relative lock time", func() {
+			tx, err := txBuilder.WithSequences([]uint32{bitcoin.RelativeLockTimeBlocks(9)}).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signAndExecute(tx, input)).ToNot(Succeed())
+		})
+	})
+
+	Context("when building transactions with timelocks", func() {
+		input := newInput(pkhScript, nil)
+
+		It("should enforce the lock time", func() {
+			tx, err := txBuilder.WithLockTime(1000).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			msgTx := new(wire.MsgTx)
+			Expect(msgTx.Deserialize(bytes.NewReader(serialized))).To(Succeed())
+			Expect(msgTx.LockTime).To(Equal(uint32(1000)))
+			Expect(msgTx.TxIn[0].Sequence).To(Equal(uint32(wire.MaxTxInSequenceNum - 1)))
+		})
+
+		It("should return an error for the wrong number of sequences", func() {
+			_, err := txBuilder.WithSequences([]uint32{1, 2}).BuildTx([]utxo.Input{input}, recipients)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when computing relative lock times", func() {
+		It("should round seconds up to the granularity", func() {
+			sequence, err := bitcoin.RelativeLockTimeSeconds(1000)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sequence).To(Equal(uint32(wire.SequenceLockTimeIsSeconds | 2)))
+			_, err = bitcoin.RelativeLockTimeSeconds(bitcoin.MaxRelativeLockTimeSeconds + 1)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
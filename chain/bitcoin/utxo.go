@@ -3,14 +3,12 @@ package bitcoin
 import (
 	"bytes"
 	"fmt"
-	"math/big"
 
-	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/psbt"
 	"github.com/renproject/multichain/api/utxo"
 	"github.com/renproject/pack"
 )
@@ -18,10 +16,17 @@ import (
 // Version of Bitcoin transactions supported by the multichain.
 const Version int32 = 2
 
+// RBFSequenceNum is the sequence number used by inputs to signal that their
+// transaction can be replaced by a transaction with a higher fee (see BIP125).
+const RBFSequenceNum = wire.MaxTxInSequenceNum - 2
+
 // The TxBuilder is an implementation of a UTXO-compatible transaction builder
 // for Bitcoin.
 type TxBuilder struct {
-	params *chaincfg.Params
+	params    *chaincfg.Params
+	rbf       bool
+	lockTime  uint32
+	sequences []uint32
 }
 
 // NewTxBuilder returns a transaction builder that builds UTXO-compatible
@@ -32,13 +37,22 @@ func NewTxBuilder(params *chaincfg.Params) TxBuilder {
 	return TxBuilder{params: params}
 }
 
+// WithReplaceByFee returns a copy of the transaction builder that builds
+// transactions that signal whether or not they can be replaced by transactions
+// with a higher fee (see BIP125).
+func (txBuilder TxBuilder) WithReplaceByFee(rbf bool) TxBuilder {
+	txBuilder.rbf = rbf
+	return txBuilder
+}
+
 // BuildTx returns a Bitcoin transaction that consumes funds from the given
 // inputs, and sends them to the given recipients. The difference in the sum
 // value of the inputs and the sum value of the recipients is paid as a fee to
 // the Bitcoin network. This fee must be calculated independently of this
-// function. Outputs produced for recipients will use P2PKH, P2SH, P2WPKH, or
-// P2WSH scripts as the pubkey script, based on the format of the recipient
-// address.
+// function. Outputs produced for recipients will use P2PKH, P2SH, P2WPKH,
+// P2WSH, or P2TR scripts as the pubkey script, based on the format of the
+// recipient address. Outputs produced for recipients with data will use
+// OP_RETURN scripts that embed at most MaxNullDataSize bytes of data.
 func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipient) (utxo.Tx, error) {
 	msgTx := wire.NewMsgTx(Version)
 
@@ -47,16 +61,19 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 		hash := chainhash.Hash{}
 		copy(hash[:], input.Hash)
 		index := input.Index.Uint32()
-		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, index), nil, nil))
+		txIn := wire.NewTxIn(wire.NewOutPoint(&hash, index), nil, nil)
+		if txBuilder.rbf {
+			txIn.Sequence = RBFSequenceNum
+		}
+		msgTx.AddTxIn(txIn)
+	}
+	if err := SetTimelocks(msgTx, txBuilder.lockTime, txBuilder.sequences); err != nil {
+		return nil, err
 	}
 
 	// Outputs
 	for _, recipient := range recipients {
-		addr, err := btcutil.DecodeAddress(string(recipient.To), txBuilder.params)
-		if err != nil {
-			return nil, err
-		}
-		script, err := txscript.PayToAddrScript(addr)
+		script, err := txBuilder.recipientScript(recipient)
 		if err != nil {
 			return nil, err
 		}
@@ -70,6 +87,22 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, signed: false}, nil
 }
 
+// recipientScript returns the pubkey script of the output produced for the
+// recipient. Recipients with data produce null-data outputs.
+func (txBuilder TxBuilder) recipientScript(recipient utxo.Recipient) ([]byte, error) {
+	if len(recipient.Data) > 0 {
+		if recipient.To != "" {
+			return nil, fmt.Errorf("bad recipient: expected address or data, got both")
+		}
+		return NullDataScript(recipient.Data, MaxNullDataSize)
+	}
+	addr, err := DecodeAddress(string(recipient.To), txBuilder.params)
+	if err != nil {
+		return nil, err
+	}
+	return PayToAddrScript(addr)
+}
+
 // Tx represents a simple Bitcoin transaction that implements the Bitcoin Compat
 // API.
 type Tx struct {
@@ -78,6 +111,14 @@ type Tx struct {
 
 	msgTx *wire.MsgTx
 
+	// partialSigs are the signatures that have been collected for each input
+	// using a PSBT, but have not yet been finalized.
+	partialSigs [][]*psbt.PartialSig
+
+	// sigHashTypes are the sighash types of each input. If there are no
+	// sighash types, all inputs use the default sighash type.
+	sigHashTypes []utxo.SigHashType
+
 	signed bool
 }
 
@@ -102,6 +143,9 @@ func (tx *Tx) Outputs() ([]utxo.Output, error) {
 			Index: pack.NewU32(uint32(i)),
 		}
 		outputs[i].PubKeyScript = pack.Bytes(tx.msgTx.TxOut[i].PkScript)
+		if data, ok := NullData(tx.msgTx.TxOut[i].PkScript); ok {
+			outputs[i].Data = data
+		}
 		if tx.msgTx.TxOut[i].Value < 0 {
 			return nil, fmt.Errorf("bad output %v: value is less than zero", i)
 		}
@@ -114,6 +158,10 @@ func (tx *Tx) Outputs() ([]utxo.Output, error) {
 // can be submitted by the client. All transactions assume that the f
 func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 	sighashes := make([]pack.Bytes32, len(tx.inputs))
+	prevOuts := make([]*wire.TxOut, len(tx.inputs))
+	for i, txin := range tx.inputs {
+		prevOuts[i] = wire.NewTxOut(txin.Value.Int().Int64(), txin.PubKeyScript)
+	}
 
 	for i, txin := range tx.inputs {
 		pubKeyScript := txin.PubKeyScript
@@ -122,20 +170,26 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 		if value < 0 {
 			return []pack.Bytes32{}, fmt.Errorf("expected value >= 0, got value %v", value)
 		}
+		if len(pubKeyScript) == 0 && len(sigScript) == 0 {
+			return []pack.Bytes32{}, fmt.Errorf("bad input %v: missing pubkey script", i)
+		}
 
 		var hash []byte
 		var err error
+		sigHashType := tx.sigHashType(i)
 		if sigScript == nil {
-			if txscript.IsPayToWitnessPubKeyHash(pubKeyScript) {
-				hash, err = txscript.CalcWitnessSigHash(pubKeyScript, txscript.NewTxSigHashes(tx.msgTx), txscript.SigHashAll, tx.msgTx, i, value)
+			if IsPayToTaproot(pubKeyScript) {
+				hash, err = TaprootSigHash(tx.msgTx, prevOuts, i, sigHashType)
+			} else if txscript.IsPayToWitnessPubKeyHash(pubKeyScript) {
+				hash, err = txscript.CalcWitnessSigHash(pubKeyScript, txscript.NewTxSigHashes(tx.msgTx), sigHashType, tx.msgTx, i, value)
 			} else {
-				hash, err = txscript.CalcSignatureHash(pubKeyScript, txscript.SigHashAll, tx.msgTx, i)
+				hash, err = txscript.CalcSignatureHash(pubKeyScript, sigHashType, tx.msgTx, i)
 			}
 		} else {
 			if txscript.IsPayToWitnessScriptHash(pubKeyScript) {
-				hash, err = txscript.CalcWitnessSigHash(sigScript, txscript.NewTxSigHashes(tx.msgTx), txscript.SigHashAll, tx.msgTx, i, value)
+				hash, err = txscript.CalcWitnessSigHash(sigScript, txscript.NewTxSigHashes(tx.msgTx), sigHashType, tx.msgTx, i, value)
 			} else {
-				hash, err = txscript.CalcSignatureHash(sigScript, txscript.SigHashAll, tx.msgTx, i)
+				hash, err = txscript.CalcSignatureHash(sigScript, sigHashType, tx.msgTx, i)
 			}
 		}
 		if err != nil {
@@ -151,6 +205,17 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 }
 
 func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
+	return tx.SignInputs(SameKeySignatures(signatures, pubKey))
+}
+
+// SignInputs signs the transaction using one signature for each input. Each
+// input can be signed by a different key. The sighash type of each signature
+// must be the default sighash type, or the same as the sighash type of its
+// input (see SetSigHashType). P2TR inputs are spent using the key path, so
+// their signatures must be BIP340 Schnorr signatures by the output key, stored
+// in the first 64 bytes of the signature (the last byte is ignored), and their
+// pubkeys are ignored.
+func (tx *Tx) SignInputs(signatures []utxo.InputSignature) error {
 	if tx.signed {
 		return fmt.Errorf("already signed")
 	}
@@ -158,41 +223,21 @@ func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
 		return fmt.Errorf("expected %v signatures, got %v signatures", len(tx.msgTx.TxIn), len(signatures))
 	}
 
-	for i, rsv := range signatures {
-		var err error
-
-		// Decode the signature and the pubkey script.
-		r := new(big.Int).SetBytes(rsv[:32])
-		s := new(big.Int).SetBytes(rsv[32:64])
-		signature := btcec.Signature{
-			R: r,
-			S: s,
+	for i, signature := range signatures {
+		if signature.SigHashType != utxo.DefaultSigHashType && tx.resolveSigHashType(i, signature.SigHashType) != tx.sigHashType(i) {
+			return fmt.Errorf("bad input %v: expected sighash type %v, got sighash type %v", i, tx.sigHashType(i), signature.SigHashType)
 		}
-		pubKeyScript := tx.inputs[i].Output.PubKeyScript
-		sigScript := tx.inputs[i].SigScript
-
-		// Support segwit.
-		if sigScript == nil {
-			if txscript.IsPayToWitnessPubKeyHash(pubKeyScript) || txscript.IsPayToWitnessScriptHash(pubKeyScript) {
-				tx.msgTx.TxIn[i].Witness = wire.TxWitness([][]byte{append(signature.Serialize(), byte(txscript.SigHashAll)), pubKey})
-				continue
-			}
-		} else {
-			if txscript.IsPayToWitnessScriptHash(sigScript) || txscript.IsPayToWitnessScriptHash(sigScript) {
-				tx.msgTx.TxIn[i].Witness = wire.TxWitness([][]byte{append(signature.Serialize(), byte(txscript.SigHashAll)), pubKey, sigScript})
-				continue
+	}
+	for i, signature := range signatures {
+		if tx.isTaproot(i) {
+			sig := append([]byte{}, signature.Signature[:SchnorrSignatureSize]...)
+			if sigHashType := tx.sigHashType(i); sigHashType != SigHashDefault {
+				sig = append(sig, byte(sigHashType))
 			}
+			tx.msgTx.TxIn[i].Witness = wire.TxWitness{sig}
+			continue
 		}
-
-		// Support non-segwit
-		builder := txscript.NewScriptBuilder()
-		builder.AddData(append(signature.Serialize(), byte(txscript.SigHashAll)))
-		builder.AddData(pubKey)
-		if sigScript != nil {
-			builder.AddData(sigScript)
-		}
-		tx.msgTx.TxIn[i].SignatureScript, err = builder.Script()
-		if err != nil {
+		if err := tx.setSignature(i, EncodeSignature(signature.Signature, tx.sigHashType(i)), signature.PubKey); err != nil {
 			return err
 		}
 	}
@@ -201,6 +246,59 @@ func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
 	return nil
 }
 
+// IsSigned returns true if the transaction has been signed.
+func (tx *Tx) IsSigned() bool {
+	return tx.signed
+}
+
+// isTaproot returns true if the input at the given index spends a P2TR output
+// using the key path.
+func (tx *Tx) isTaproot(i int) bool {
+	return tx.inputs[i].SigScript == nil && IsPayToTaproot(tx.inputs[i].PubKeyScript)
+}
+
+// SameKeySignatures returns the signatures of inputs that are all signed by the
+// same public key, using the default sighash type.
+func SameKeySignatures(signatures []pack.Bytes65, pubKey pack.Bytes) []utxo.InputSignature {
+	inputSignatures := make([]utxo.InputSignature, len(signatures))
+	for i := range signatures {
+		inputSignatures[i] = utxo.InputSignature{Signature: signatures[i], PubKey: pubKey}
+	}
+	return inputSignatures
+}
+
+// setSignature sets the signature script, or witness, of the input at the given
+// index. The signature must be DER encoded, and must be followed by the sighash
+// type.
+func (tx *Tx) setSignature(i int, sig []byte, pubKey []byte) error {
+	var err error
+	pubKeyScript := tx.inputs[i].Output.PubKeyScript
+	sigScript := tx.inputs[i].SigScript
+
+	// Support segwit.
+	if sigScript == nil {
+		if txscript.IsPayToWitnessPubKeyHash(pubKeyScript) || txscript.IsPayToWitnessScriptHash(pubKeyScript) {
+			tx.msgTx.TxIn[i].Witness = wire.TxWitness([][]byte{sig, pubKey})
+			return nil
+		}
+	} else {
+		if txscript.IsPayToWitnessScriptHash(pubKeyScript) {
+			tx.msgTx.TxIn[i].Witness = wire.TxWitness([][]byte{sig, pubKey, sigScript})
+			return nil
+		}
+	}
+
+	// Support non-segwit
+	builder := txscript.NewScriptBuilder()
+	builder.AddData(sig)
+	builder.AddData(pubKey)
+	if sigScript != nil {
+		builder.AddData(sigScript)
+	}
+	tx.msgTx.TxIn[i].SignatureScript, err = builder.Script()
+	return err
+}
+
 func (tx *Tx) Serialize() (pack.Bytes, error) {
 	buf := new(bytes.Buffer)
 	if err := tx.msgTx.Serialize(buf); err != nil {
@@ -0,0 +1,58 @@
+package bitcoincash
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+)
+
+// DecodeTx returns the Bitcoin Cash transaction encoded by the given bytes, in
+// the format produced by Serialize. Serialized transactions do not include the
+// values, or pubkey scripts, of the outputs that they spend, so these must be
+// given as inputs (in the same order as the transaction) for Sighashes to
+// work. If no inputs are given, they are recovered from the outpoints of the
+// transaction with a value of zero, and Sighashes will return an error.
+func DecodeTx(params *chaincfg.Params, data pack.Bytes, inputs []utxo.Input) (*Tx, error) {
+	msgTx := new(wire.MsgTx)
+	r := bytes.NewReader(data)
+	if err := msgTx.BtcDecode(r, 0, wire.BaseEncoding); err != nil {
+		return nil, fmt.Errorf("bad tx: %v", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("bad tx: %v trailing bytes", r.Len())
+	}
+
+	inputs, err := bitcoin.InputsFromMsgTx(msgTx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := bitcoin.RecipientsFromMsgTx(msgTx, func(addr btcutil.Address) (address.Address, error) {
+		switch addr := addr.(type) {
+		case *btcutil.AddressPubKeyHash:
+			cashAddr, err := NewAddressPubKeyHash(addr.ScriptAddress(), params)
+			if err != nil {
+				return address.Address(""), err
+			}
+			return address.Address(cashAddr.EncodeAddress()), nil
+		case *btcutil.AddressScriptHash:
+			cashAddr, err := NewAddressScriptHashFromHash(addr.ScriptAddress(), params)
+			if err != nil {
+				return address.Address(""), err
+			}
+			return address.Address(cashAddr.EncodeAddress()), nil
+		default:
+			return address.Address(""), fmt.Errorf("unsupported address type %T", addr)
+		}
+	}, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, signed: bitcoin.IsSigned(msgTx)}, nil
+}
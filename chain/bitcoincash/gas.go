@@ -4,4 +4,10 @@ import "github.com/renproject/multichain/chain/bitcoin"
 
 type GasEstimator = bitcoin.GasEstimator
 
+type GasEstimatorOptions = bitcoin.GasEstimatorOptions
+
 var NewGasEstimator = bitcoin.NewGasEstimator
+
+var NewSmartGasEstimator = bitcoin.NewSmartGasEstimator
+
+var DefaultGasEstimatorOptions = bitcoin.DefaultGasEstimatorOptions
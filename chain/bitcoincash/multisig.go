@@ -0,0 +1,92 @@
+package bitcoincash
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+)
+
+// MultisigScript returns a sorted m-of-n multisig script for the given
+// compressed pubkeys.
+var MultisigScript = bitcoin.MultisigScript
+
+// MultisigAddress returns the P2SH address of the given multisig script, using
+// the cashaddr encoding.
+func MultisigAddress(script pack.Bytes, params *chaincfg.Params) (address.Address, error) {
+	addr, err := NewAddressScriptHash(script, params)
+	if err != nil {
+		return address.Address(""), err
+	}
+	return address.Address(addr.EncodeAddress()), nil
+}
+
+// SignMultisig signs the transaction using signatures from multiple keys for
+// each input. The signatures and pubkeys of each input are given in pairs, and
+// do not need to be in any particular order. Inputs with a multisig sig script
+// need at least as many signatures as the threshold of the script, and are
+// signed using an OP_0 <sig...> <redeemScript> signature script. All other
+// inputs need exactly one signature, and are signed in the same way as Sign.
+// Schnorr multisig uses a different signature script, and is not supported.
+func (tx *Tx) SignMultisig(signatures [][]pack.Bytes65, pubKeys [][]pack.Bytes) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if tx.schnorr {
+		return fmt.Errorf("multisig is not supported with schnorr signatures")
+	}
+	if len(signatures) != len(tx.msgTx.TxIn) || len(pubKeys) != len(tx.msgTx.TxIn) {
+		return fmt.Errorf("expected %v signatures and pubkeys, got %v signatures and %v pubkeys", len(tx.msgTx.TxIn), len(signatures), len(pubKeys))
+	}
+
+	signatureScripts := make([][]byte, len(signatures))
+	for i := range signatures {
+		if len(signatures[i]) != len(pubKeys[i]) {
+			return fmt.Errorf("bad input %v: expected %v signatures, got %v signatures", i, len(pubKeys[i]), len(signatures[i]))
+		}
+		sigs := make([][]byte, len(signatures[i]))
+		keys := make([][]byte, len(pubKeys[i]))
+		for j := range signatures[i] {
+			sigs[j] = tx.encodeSignature(signatures[i][j], tx.sigHashType(i))
+			keys[j] = pubKeys[i][j]
+		}
+
+		sigScript := tx.inputs[i].SigScript
+		if !bitcoin.IsMultisigScript(sigScript) {
+			if len(sigs) != 1 {
+				return fmt.Errorf("bad input %v: expected 1 signature, got %v signatures", i, len(sigs))
+			}
+			builder := txscript.NewScriptBuilder()
+			builder.AddData(sigs[0])
+			builder.AddData(keys[0])
+			if sigScript != nil {
+				builder.AddData(sigScript)
+			}
+			signatureScript, err := builder.Script()
+			if err != nil {
+				return fmt.Errorf("bad input %v: %v", i, err)
+			}
+			signatureScripts[i] = signatureScript
+			continue
+		}
+
+		ordered, err := bitcoin.OrderMultisigSignatures(sigScript, sigs, keys)
+		if err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+		signatureScript, err := bitcoin.MultisigSignatureScript(sigScript, ordered)
+		if err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+		signatureScripts[i] = signatureScript
+	}
+
+	for i := range signatureScripts {
+		tx.msgTx.TxIn[i].SignatureScript = signatureScripts[i]
+	}
+	tx.signed = true
+	return nil
+}
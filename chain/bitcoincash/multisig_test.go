@@ -0,0 +1,192 @@
+package bitcoincash_test
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoincash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multisig", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoincash.NewTxBuilder(params)
+
+	newKey := func() (*btcec.PrivateKey, pack.Bytes) {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			panic(err)
+		}
+		return privKey, pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	}
+
+	privKey1, pubKey1 := newKey()
+	privKey2, pubKey2 := newKey()
+	_, pubKey3 := newKey()
+
+	script, err := bitcoincash.MultisigScript(2, []pack.Bytes{pubKey1, pubKey2, pubKey3})
+	if err != nil {
+		panic(err)
+	}
+	shAddr, err := bitcoincash.MultisigAddress(script, params)
+	if err != nil {
+		panic(err)
+	}
+
+	Context("when getting a multisig address", func() {
+		It("should return a cashaddr P2SH address", func() {
+			addr, err := bitcoincash.DecodeAddress(string(shAddr), params)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr).To(BeAssignableToTypeOf(bitcoincash.AddressScriptHash{}))
+		})
+	})
+
+	Context("when signing with enough keys", func() {
+		It("should produce an OP_0 <sig...> <redeemScript> signature script with FORKID signatures", func() {
+			addr, err := bitcoincash.DecodeAddress(string(shAddr), params)
+			Expect(err).ToNot(HaveOccurred())
+			pubKeyScript, err := txscript.PayToAddrScript(addr.BitcoinAddress())
+			Expect(err).ToNot(HaveOccurred())
+			inputs := []utxo.Input{{
+				Output: utxo.Output{
+					Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+					Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+					PubKeyScript: pack.NewBytes(pubKeyScript),
+				},
+				SigScript: script,
+			}}
+			tx, err := txBuilder.BuildTx(inputs, nil)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+
+			hash := id.Hash(sighashes[0])
+			signature1, err := (*id.PrivKey)(privKey1.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signature2, err := (*id.PrivKey)(privKey2.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := [][]pack.Bytes65{{pack.NewBytes65(signature2), pack.NewBytes65(signature1)}}
+			pubKeys := [][]pack.Bytes{{pubKey2, pubKey1}}
+			Expect(tx.(*bitcoincash.Tx).SignMultisig(signatures, pubKeys)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).To(Succeed())
+
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			msgTx := new(wire.MsgTx)
+			Expect(msgTx.DeserializeNoWitness(bytes.NewReader(serialized))).To(Succeed())
+			pushes, err := txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pushes).To(HaveLen(4))
+			Expect(pushes[0]).To(BeEmpty())
+			Expect(pushes[3]).To(Equal([]byte(script)))
+
+			// The signatures must be in the same order as the pubkeys in the
+			// script.
+			scriptPubKeys, err := txscript.PushedData(script)
+			Expect(err).ToNot(HaveOccurred())
+			j := 0
+			for _, scriptPubKey := range scriptPubKeys {
+				if j >= 2 {
+					break
+				}
+				pubKey, err := btcec.ParsePubKey(scriptPubKey, btcec.S256())
+				Expect(err).ToNot(HaveOccurred())
+				sig := pushes[1+j]
+				Expect(sig[len(sig)-1]).To(Equal(byte(txscript.SigHashAll | bitcoincash.SighashForkID)))
+				parsed, err := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+				Expect(err).ToNot(HaveOccurred())
+				if parsed.Verify(sighashes[0][:], pubKey) {
+					j++
+				}
+			}
+			Expect(j).To(Equal(2))
+		})
+	})
+
+	Context("when selecting coins from a multisig address", func() {
+		It("should pay enough fees for the signed transaction", func() {
+			addr, err := bitcoincash.DecodeAddress(string(shAddr), params)
+			Expect(err).ToNot(HaveOccurred())
+			pubKeyScript, err := txscript.PayToAddrScript(addr.BitcoinAddress())
+			Expect(err).ToNot(HaveOccurred())
+			outputs := make([]utxo.Output, 3)
+			for i := range outputs {
+				outputs[i] = utxo.Output{
+					Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(append(make([]byte, 31), byte(i))), Index: pack.NewU32(0)},
+					Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+					PubKeyScript: pack.NewBytes(pubKeyScript),
+				}
+			}
+			recipients := []utxo.Recipient{{To: shAddr, Value: pack.NewU256FromU64(pack.NewU64(150000))}}
+			satsPerByte := pack.NewU256FromU64(pack.NewU64(10))
+
+			// The redeem script is needed to estimate the size of the inputs.
+			_, err = bitcoincash.SelectCoins(txBuilder, outputs, recipients, shAddr, satsPerByte, bitcoincash.DefaultCoinSelectorOptions())
+			Expect(err).To(HaveOccurred())
+
+			opts := bitcoincash.DefaultCoinSelectorOptions().WithSigScript(script)
+			tx, err := bitcoincash.SelectCoins(txBuilder, outputs, recipients, shAddr, satsPerByte, opts)
+			Expect(err).ToNot(HaveOccurred())
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs).To(HaveLen(2))
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([][]pack.Bytes65, len(sighashes))
+			pubKeys := make([][]pack.Bytes, len(sighashes))
+			for i := range sighashes {
+				hash := id.Hash(sighashes[i])
+				signature1, err := (*id.PrivKey)(privKey1.ToECDSA()).Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signature2, err := (*id.PrivKey)(privKey2.ToECDSA()).Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatures[i] = []pack.Bytes65{pack.NewBytes65(signature1), pack.NewBytes65(signature2)}
+				pubKeys[i] = []pack.Bytes{pubKey1, pubKey2}
+			}
+			Expect(tx.(*bitcoincash.Tx).SignMultisig(signatures, pubKeys)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).To(Succeed())
+			serialized, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			outs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			fee := uint64(200000)
+			for _, output := range outs {
+				fee -= output.Value.Int().Uint64()
+			}
+			Expect(fee).To(BeNumerically(">=", 10*uint64(len(serialized))))
+			// Signatures can be up to 3 bytes smaller than the maximum size.
+			Expect(fee).To(BeNumerically("<=", 10*uint64(len(serialized)+2*2*3)))
+		})
+	})
+
+	Context("when signing with too few keys", func() {
+		It("should return an error", func() {
+			inputs := []utxo.Input{{
+				Output: utxo.Output{
+					Outpoint: utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+					Value:    pack.NewU256FromU64(pack.NewU64(100000)),
+				},
+				SigScript: script,
+			}}
+			tx, err := txBuilder.BuildTx(inputs, nil)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			hash := id.Hash(sighashes[0])
+			signature1, err := (*id.PrivKey)(privKey1.ToECDSA()).Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			signatures := [][]pack.Bytes65{{pack.NewBytes65(signature1)}}
+			pubKeys := [][]pack.Bytes{{pubKey1}}
+			Expect(tx.(*bitcoincash.Tx).SignMultisig(signatures, pubKeys)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,50 @@
+package bitcoincash_test
+
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/multichain/chain/bitcoincash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Null data", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoincash.NewTxBuilder(params)
+
+	pkhAddr, err := bitcoincash.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr.BitcoinAddress())
+	if err != nil {
+		panic(err)
+	}
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+	}
+	zero := pack.NewU256FromU64(pack.NewU64(0))
+
+	Context("when a recipient has data", func() {
+		It("should allow more data than Bitcoin", func() {
+			data := pack.NewBytes(make([]byte, bitcoincash.MaxNullDataSize))
+			Expect(len(data)).To(BeNumerically(">", bitcoin.MaxNullDataSize))
+			tx, err := txBuilder.BuildTx(inputs, []utxo.Recipient{{Value: zero, Data: data}})
+			Expect(err).ToNot(HaveOccurred())
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs[0].Data).To(Equal(data))
+
+			_, err = txBuilder.BuildTx(inputs, []utxo.Recipient{{Value: zero, Data: make([]byte, bitcoincash.MaxNullDataSize+1)}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
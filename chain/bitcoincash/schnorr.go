@@ -0,0 +1,153 @@
+package bitcoincash
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/multichain/chain/bitcoin"
+)
+
+// SchnorrSignatureSize is the size of a Bitcoin Cash Schnorr signature,
+// excluding the sighash type.
+const SchnorrSignatureSize = 64
+
+// WithSchnorr returns a transaction builder that builds transactions which are
+// signed using Bitcoin Cash Schnorr signatures, instead of ECDSA signatures.
+// The first 64 bytes of each signature given to Sign are used as the [R || S]
+// Schnorr signature, and the last byte is ignored. The sighashes are the same
+// for both kinds of signature.
+func (txBuilder TxBuilder) WithSchnorr(schnorr bool) TxBuilder {
+	txBuilder.schnorr = schnorr
+	return txBuilder
+}
+
+// VerifySchnorr returns true if the Bitcoin Cash Schnorr signature of the
+// sighash is valid for the given serialized pubkey. The signature must not
+// include the sighash type.
+func VerifySchnorr(pubKey []byte, sighash []byte, signature []byte) bool {
+	if len(signature) != SchnorrSignatureSize {
+		return false
+	}
+	p, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return false
+	}
+	curve := btcec.S256()
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if r.Cmp(curve.P) >= 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// e = H(R.x || compressed(P) || m)
+	h := sha256.New()
+	h.Write(signature[:32])
+	h.Write(p.SerializeCompressed())
+	h.Write(sighash)
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	e.Mod(e, curve.N)
+
+	// R = s⋅G - e⋅P
+	sx, sy := curve.ScalarBaseMult(signature[32:])
+	ex, ey := curve.ScalarMult(p.X, p.Y, new(big.Int).Sub(curve.N, e).Bytes())
+	rx, ry := curve.Add(sx, sy, ex, ey)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return big.Jacobi(ry, curve.P) == 1 && rx.Cmp(r) == 0
+}
+
+// Verify the signatures of all inputs of the signed transaction against the
+// BIP143 sighashes (with SIGHASH_FORKID) returned by Sighashes. Signatures must
+// use the sighash type of their input (see SetSigHashType). Both ECDSA and
+// Schnorr signatures are supported. Inputs with a multisig sig script must
+// have been signed using SignMultisig.
+func (tx *Tx) Verify() error {
+	if !tx.signed {
+		return fmt.Errorf("not signed")
+	}
+	sighashes, err := tx.Sighashes()
+	if err != nil {
+		return err
+	}
+	for i, txIn := range tx.msgTx.TxIn {
+		pushes, err := txscript.PushedData(txIn.SignatureScript)
+		if err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+		if err := verifyInput(tx.inputs[i].SigScript, pushes, sighashes[i][:], tx.sigHashType(i)|SighashForkID); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyInput verifies the data pushed by the signature script of an input.
+func verifyInput(sigScript []byte, pushes [][]byte, sighash []byte, sigHashType txscript.SigHashType) error {
+	if !bitcoin.IsMultisigScript(sigScript) {
+		if len(pushes) < 2 {
+			return fmt.Errorf("expected signature and pubkey")
+		}
+		return verifySignature(pushes[1], sighash, pushes[0], sigHashType)
+	}
+
+	// OP_0 <sig...> <redeemScript>
+	_, m, err := txscript.CalcMultiSigStats(sigScript)
+	if err != nil {
+		return err
+	}
+	pubKeys, err := txscript.PushedData(sigScript)
+	if err != nil {
+		return err
+	}
+	if len(pushes) != m+2 || len(pushes[0]) != 0 {
+		return fmt.Errorf("expected OP_0 followed by %v signatures", m)
+	}
+	sigs := pushes[1 : m+1]
+	j := 0
+	for _, sig := range sigs {
+		for j < len(pubKeys) && verifySignature(pubKeys[j], sighash, sig, sigHashType) != nil {
+			j++
+		}
+		if j == len(pubKeys) {
+			return fmt.Errorf("invalid multisig signature")
+		}
+		j++
+	}
+	return nil
+}
+
+// verifySignature verifies a signature, followed by its sighash type, using
+// the given serialized pubkey and expected sighash type. The signature is assumed to be a Schnorr
+// signature when it is exactly 65 bytes long (see the Bitcoin Cash Schnorr
+// specification), and an ECDSA signature otherwise.
+func verifySignature(pubKey []byte, sighash []byte, sig []byte, sigHashType txscript.SigHashType) error {
+	if len(sig) == 0 {
+		return fmt.Errorf("empty signature")
+	}
+	if txscript.SigHashType(sig[len(sig)-1]) != sigHashType {
+		return fmt.Errorf("expected sighash type %v, got sighash type %v", sigHashType, txscript.SigHashType(sig[len(sig)-1]))
+	}
+	sig = sig[:len(sig)-1]
+	if len(sig) == SchnorrSignatureSize {
+		if !VerifySchnorr(pubKey, sighash, sig) {
+			return fmt.Errorf("invalid schnorr signature")
+		}
+		return nil
+	}
+	parsedPubKey, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("bad pubkey: %v", err)
+	}
+	parsedSig, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+	if !parsedSig.Verify(sighash, parsedPubKey) {
+		return fmt.Errorf("invalid ecdsa signature")
+	}
+	return nil
+}
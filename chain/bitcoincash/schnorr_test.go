@@ -0,0 +1,156 @@
+package bitcoincash_test
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoincash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schnorr", func() {
+	curve := btcec.S256()
+	params := &chaincfg.RegressionNetParams
+
+	bytes32 := func(x *big.Int) []byte {
+		b := make([]byte, 32)
+		xBytes := x.Bytes()
+		copy(b[32-len(xBytes):], xBytes)
+		return b
+	}
+
+	// signSchnorr returns the Bitcoin Cash Schnorr signature of the sighash in
+	// the [R || S || V] format expected by Sign.
+	signSchnorr := func(privKey *btcec.PrivateKey, sighash pack.Bytes32) pack.Bytes65 {
+		nonce, err := btcec.NewPrivateKey(curve)
+		Expect(err).ToNot(HaveOccurred())
+		k := new(big.Int).Set(nonce.D)
+		rx, ry := curve.ScalarBaseMult(bytes32(k))
+		if big.Jacobi(ry, curve.P) != 1 {
+			k.Sub(curve.N, k)
+		}
+		h := sha256.New()
+		h.Write(bytes32(rx))
+		h.Write(privKey.PubKey().SerializeCompressed())
+		h.Write(sighash[:])
+		e := new(big.Int).SetBytes(h.Sum(nil))
+		e.Mod(e, curve.N)
+		s := new(big.Int).Mul(e, privKey.D)
+		s.Add(s, k)
+		s.Mod(s, curve.N)
+
+		signature := pack.Bytes65{}
+		copy(signature[:32], bytes32(rx))
+		copy(signature[32:64], bytes32(s))
+		return signature
+	}
+
+	privKey, err := btcec.NewPrivateKey(curve)
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := bitcoincash.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr.BitcoinAddress())
+	if err != nil {
+		panic(err)
+	}
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(1)},
+			Value:        pack.NewU256FromU64(pack.NewU64(50000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(149000))},
+	}
+	txBuilder := bitcoincash.NewTxBuilder(params)
+
+	Context("when signing with Schnorr signatures", func() {
+		It("should produce signature scripts that verify", func() {
+			tx, err := txBuilder.WithSchnorr(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				signatures[i] = signSchnorr(privKey, sighashes[i])
+				Expect(bitcoincash.VerifySchnorr(pubKey, sighashes[i][:], signatures[i][:64])).To(BeTrue())
+			}
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).To(Succeed())
+		})
+
+		It("should reject signatures of the wrong sighash", func() {
+			tx, err := txBuilder.WithSchnorr(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := []pack.Bytes65{signSchnorr(privKey, sighashes[1]), signSchnorr(privKey, sighashes[0])}
+			Expect(bitcoincash.VerifySchnorr(pubKey, sighashes[0][:], signatures[0][:64])).To(BeFalse())
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).ToNot(Succeed())
+		})
+	})
+
+	Context("when signing with ECDSA signatures", func() {
+		It("should produce signature scripts that verify", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				hash := id.Hash(sighashes[i])
+				signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatures[i] = pack.NewBytes65(signature)
+			}
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).To(Succeed())
+		})
+	})
+
+	Context("when setting the sighash types of inputs", func() {
+		It("should produce signature scripts that verify", func() {
+			tx, err := txBuilder.WithSchnorr(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(0, utxo.SigHashType(txscript.SigHashSingle|txscript.SigHashAnyOneCanPay))).To(Succeed())
+			Expect(tx.(utxo.InputSigner).SetSigHashType(1, utxo.SigHashType(txscript.SigHashNone|bitcoincash.SighashForkID))).To(Succeed())
+			defaultSighashes, err := txBuilder.WithSchnorr(true).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			expected, err := defaultSighashes.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sighashes[0]).ToNot(Equal(expected[0]))
+			Expect(sighashes[1]).ToNot(Equal(expected[1]))
+
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				signatures[i] = signSchnorr(privKey, sighashes[i])
+			}
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			Expect(tx.(*bitcoincash.Tx).Verify()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package bitcoincash
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+)
+
+// SetSigHashType sets the sighash type of the input at the given index, so
+// that each signer can commit to only the parts of the transaction that they
+// care about (for example, their own inputs and outputs). It must be called
+// before computing the sighashes, and signatures of the input must use the
+// same sighash type. The SIGHASH_FORKID flag is always added, and does not
+// need to be given. By default, inputs use SIGHASH_ALL.
+func (tx *Tx) SetSigHashType(i int, sigHashType utxo.SigHashType) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if i < 0 || i >= len(tx.inputs) {
+		return fmt.Errorf("bad input: expected index < %v, got index %v", len(tx.inputs), i)
+	}
+	if sigHashType != utxo.DefaultSigHashType {
+		if err := bitcoin.CheckSigHashType(resolveSigHashType(sigHashType)); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	if len(tx.sigHashTypes) != len(tx.inputs) {
+		sigHashTypes := make([]utxo.SigHashType, len(tx.inputs))
+		copy(sigHashTypes, tx.sigHashTypes)
+		tx.sigHashTypes = sigHashTypes
+	}
+	tx.sigHashTypes[i] = sigHashType
+	return nil
+}
+
+// sigHashType returns the sighash type of the input at the given index,
+// without the SIGHASH_FORKID flag.
+func (tx *Tx) sigHashType(i int) txscript.SigHashType {
+	if i < len(tx.sigHashTypes) {
+		return resolveSigHashType(tx.sigHashTypes[i])
+	}
+	return resolveSigHashType(utxo.DefaultSigHashType)
+}
+
+// resolveSigHashType replaces the default sighash type with SIGHASH_ALL, and
+// removes the SIGHASH_FORKID flag.
+func resolveSigHashType(sigHashType utxo.SigHashType) txscript.SigHashType {
+	if sigHashType == utxo.DefaultSigHashType {
+		return txscript.SigHashAll
+	}
+	return txscript.SigHashType(sigHashType) &^ SighashForkID
+}
@@ -4,13 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"math/big"
 
-	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/renproject/multichain/api/gas"
 	"github.com/renproject/multichain/api/utxo"
 	"github.com/renproject/multichain/chain/bitcoin"
 	"github.com/renproject/pack"
@@ -23,6 +22,10 @@ const SighashForkID = txscript.SigHashType(0x40)
 // SighashMask used to mask hash types.
 const SighashMask = txscript.SigHashType(0x1F)
 
+// MaxNullDataSize is the maximum number of bytes of data that can be embedded
+// in a null-data output by standard Bitcoin Cash transactions.
+const MaxNullDataSize = 220
+
 // Version of Bitcoin Cash transactions supported by the multichain.
 const Version int32 = 1
 
@@ -36,17 +39,142 @@ type Client = bitcoin.Client
 
 var NewClient = bitcoin.NewClient
 
+var ErrTxNotFound = bitcoin.ErrTxNotFound
+
+var ErrTxAlreadyInChain = bitcoin.ErrTxAlreadyInChain
+
+var ErrTxAlreadyInMempool = bitcoin.ErrTxAlreadyInMempool
+
+var ErrMissingInputs = bitcoin.ErrMissingInputs
+
+var ErrInsufficientFee = bitcoin.ErrInsufficientFee
+
+var ErrMempoolConflict = bitcoin.ErrMempoolConflict
+
+type RPCError = bitcoin.RPCError
+
+type OutputResult = bitcoin.OutputResult
+
+type ConfirmationsResult = bitcoin.ConfirmationsResult
+
+type RetryPolicy = bitcoin.RetryPolicy
+
+type BackoffPolicy = bitcoin.BackoffPolicy
+
+var DefaultBackoffPolicy = bitcoin.DefaultBackoffPolicy
+
+type MultiClient = bitcoin.MultiClient
+
+type MultiClientOptions = bitcoin.MultiClientOptions
+
+var NewMultiClient = bitcoin.NewMultiClient
+
+var DefaultMultiClientOptions = bitcoin.DefaultMultiClientOptions
+
+type CoinSelector = bitcoin.CoinSelector
+
+type CoinSelectorOptions = bitcoin.CoinSelectorOptions
+
+var NewCoinSelector = bitcoin.NewCoinSelector
+
+// DefaultCoinSelectorOptions returns CoinSelectorOptions with the default
+// settings. Bitcoin Cash does not support segwit, so witness scripts are not
+// assumed when estimating the size of inputs.
+func DefaultCoinSelectorOptions() CoinSelectorOptions {
+	return bitcoin.DefaultCoinSelectorOptions().WithInputScript(InputScriptFromInput)
+}
+
+var SelectCoins = bitcoin.SelectCoins
+
+type InputScript = bitcoin.InputScript
+
+type ScriptType = bitcoin.ScriptType
+
+type InputScriptFunc = bitcoin.InputScriptFunc
+
+// InputScriptFromInput returns the InputScript that is assumed when spending
+// the given input. Segwit is not supported, so an error is returned if the
+// input needs a witness.
+var InputScriptFromInput = bitcoin.InputScriptFromInputWithoutWitness
+
+// EstimateSize returns an upper bound on the serialized size, and virtual
+// size, of the given unsigned transaction after it has been signed. Segwit is
+// not supported, so the serialized size and virtual size are always equal.
+var EstimateSize = bitcoin.EstimateSizeWithoutWitness
+
+type FeeError = bitcoin.FeeError
+
+type FeeGuardOptions = bitcoin.FeeGuardOptions
+
+type GuardedTxBuilder = bitcoin.GuardedTxBuilder
+
+type GuardedClient = bitcoin.GuardedClient
+
+var CheckFee = bitcoin.CheckFee
+
+// DefaultFeeGuardOptions returns FeeGuardOptions with the default settings.
+// Bitcoin Cash does not support segwit, so witness scripts are not assumed when
+// estimating the size of inputs.
+func DefaultFeeGuardOptions() FeeGuardOptions {
+	return bitcoin.DefaultFeeGuardOptions().WithInputScript(InputScriptFromInput)
+}
+
+var NewGuardedTxBuilder = bitcoin.NewGuardedTxBuilder
+
+var NewGuardedClient = bitcoin.NewGuardedClient
+
+type CPFPBuilder = bitcoin.CPFPBuilder
+
+// NewCPFPBuilder returns a CPFPBuilder that loads parent transactions using the
+// given client, estimates SATs-per-byte using the given gas estimator, and
+// builds child transactions using the given transaction builder. Bitcoin Cash does
+// not support segwit, so witness scripts are not assumed when estimating the
+// size of child transactions.
+func NewCPFPBuilder(client Client, txBuilder utxo.TxBuilder, gasEstimator gas.Estimator, dustThreshold pack.U256) CPFPBuilder {
+	return bitcoin.NewCPFPBuilder(client, txBuilder, gasEstimator, dustThreshold).WithInputScript(InputScriptFromInput)
+}
+
+var CheckLockTimeVerifyScript = bitcoin.CheckLockTimeVerifyScript
+
+var CheckSequenceVerifyScript = bitcoin.CheckSequenceVerifyScript
+
+var RelativeLockTimeBlocks = bitcoin.RelativeLockTimeBlocks
+
+var RelativeLockTimeSeconds = bitcoin.RelativeLockTimeSeconds
+
 type TxBuilder struct {
-	params *chaincfg.Params
+	params    *chaincfg.Params
+	schnorr   bool
+	lockTime  uint32
+	sequences []uint32
 }
 
 // NewTxBuilder returns an implementation of the transaction builder interface
 // from the Bitcoin Compat API, and exposes the functionality to build simple
 // Bitcoin Cash transactions.
-func NewTxBuilder(params *chaincfg.Params) utxo.TxBuilder {
+func NewTxBuilder(params *chaincfg.Params) TxBuilder {
 	return TxBuilder{params: params}
 }
 
+// WithLockTime returns a copy of the transaction builder that builds
+// transactions with the given lock time (nLockTime). Unless sequence numbers
+// are given, inputs use the sequence number MaxTxInSequenceNum - 1 so that the
+// lock time is enforced.
+func (txBuilder TxBuilder) WithLockTime(lockTime uint32) TxBuilder {
+	txBuilder.lockTime = lockTime
+	return txBuilder
+}
+
+// WithSequences returns a copy of the transaction builder that builds
+// transactions with the given sequence number (nSequence) for each input. The
+// number of sequence numbers must be the same as the number of inputs.
+// Relative lock times are only enforced for transactions with a version of at
+// least 2 (see BIP68), so these transactions use version 2.
+func (txBuilder TxBuilder) WithSequences(sequences []uint32) TxBuilder {
+	txBuilder.sequences = sequences
+	return txBuilder
+}
+
 // BuildTx returns a simple Bitcoin Cash transaction that consumes the funds
 // from the given outputs, and sends the to the given recipients. The difference
 // in the sum value of the inputs and the sum value of the recipients is paid as
@@ -59,8 +187,14 @@ func NewTxBuilder(params *chaincfg.Params) utxo.TxBuilder {
 //  builder.AddData(append(signature.Serialize(), byte(txscript.SigHashAll|SighashForkID)))
 //  builder.AddData(serializedPubKey)
 //
+// When building transactions that are signed using Schnorr signatures (see
+// WithSchnorr), the DER encoded signature is replaced by the 64 byte [R || S]
+// signature.
+//
 // Outputs produced for recipients will use P2PKH, or P2SH scripts as the pubkey
-// script, based on the format of the recipient address.
+// script, based on the format of the recipient address. Outputs produced for
+// recipients with data will use OP_RETURN scripts that embed at most
+// MaxNullDataSize bytes of data.
 func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipient) (utxo.Tx, error) {
 	msgTx := wire.NewMsgTx(Version)
 
@@ -71,14 +205,16 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 		index := input.Index.Uint32()
 		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, index), nil, nil))
 	}
+	if err := bitcoin.SetTimelocks(msgTx, txBuilder.lockTime, txBuilder.sequences); err != nil {
+		return nil, err
+	}
+	if len(txBuilder.sequences) > 0 {
+		msgTx.Version = 2
+	}
 
 	// Outputs
 	for _, recipient := range recipients {
-		addr, err := DecodeAddress(string(recipient.To), txBuilder.params)
-		if err != nil {
-			return &Tx{}, err
-		}
-		script, err := txscript.PayToAddrScript(addr.BitcoinAddress())
+		script, err := txBuilder.recipientScript(recipient)
 		if err != nil {
 			return &Tx{}, err
 		}
@@ -89,7 +225,23 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 		msgTx.AddTxOut(wire.NewTxOut(value, script))
 	}
 
-	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, signed: false}, nil
+	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, schnorr: txBuilder.schnorr, signed: false}, nil
+}
+
+// recipientScript returns the pubkey script of the output produced for the
+// recipient. Recipients with data produce null-data outputs.
+func (txBuilder TxBuilder) recipientScript(recipient utxo.Recipient) ([]byte, error) {
+	if len(recipient.Data) > 0 {
+		if recipient.To != "" {
+			return nil, fmt.Errorf("bad recipient: expected address or data, got both")
+		}
+		return bitcoin.NullDataScript(recipient.Data, MaxNullDataSize)
+	}
+	addr, err := DecodeAddress(string(recipient.To), txBuilder.params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr.BitcoinAddress())
 }
 
 // Tx represents a simple Bitcoin Cash transaction that implements the Bitcoin
@@ -100,6 +252,14 @@ type Tx struct {
 
 	msgTx *wire.MsgTx
 
+	// schnorr is true if the transaction is signed using Schnorr signatures,
+	// instead of ECDSA signatures.
+	schnorr bool
+
+	// sigHashTypes are the sighash types of each input. If there are no
+	// sighash types, all inputs use SIGHASH_ALL.
+	sigHashTypes []utxo.SigHashType
+
 	signed bool
 }
 
@@ -124,6 +284,9 @@ func (tx *Tx) Outputs() ([]utxo.Output, error) {
 			Index: pack.NewU32(uint32(i)),
 		}
 		outputs[i].PubKeyScript = pack.Bytes(tx.msgTx.TxOut[i].PkScript)
+		if data, ok := bitcoin.NullData(tx.msgTx.TxOut[i].PkScript); ok {
+			outputs[i].Data = data
+		}
 		if tx.msgTx.TxOut[i].Value < 0 {
 			return nil, fmt.Errorf("bad output %v: value is less than zero", i)
 		}
@@ -142,12 +305,15 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 		if value < 0 {
 			return []pack.Bytes32{}, fmt.Errorf("expected value >= 0, got value = %v", value)
 		}
+		if len(pubKeyScript) == 0 && len(sigScript) == 0 {
+			return []pack.Bytes32{}, fmt.Errorf("bad input %v: missing pubkey script", i)
+		}
 
 		var hash []byte
 		if sigScript == nil {
-			hash = CalculateBip143Sighash(pubKeyScript, txscript.NewTxSigHashes(tx.msgTx), txscript.SigHashAll, tx.msgTx, i, value)
+			hash = CalculateBip143Sighash(pubKeyScript, txscript.NewTxSigHashes(tx.msgTx), tx.sigHashType(i), tx.msgTx, i, value)
 		} else {
-			hash = CalculateBip143Sighash(sigScript, txscript.NewTxSigHashes(tx.msgTx), txscript.SigHashAll, tx.msgTx, i, value)
+			hash = CalculateBip143Sighash(sigScript, txscript.NewTxSigHashes(tx.msgTx), tx.sigHashType(i), tx.msgTx, i, value)
 		}
 
 		sighash := [32]byte{}
@@ -158,24 +324,30 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 }
 
 func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
+	return tx.SignInputs(bitcoin.SameKeySignatures(signatures, pubKey))
+}
+
+// SignInputs signs the transaction using one signature for each input. Each
+// input can be signed by a different key. The sighash type of each signature
+// must be the default sighash type, or the same as the sighash type of its
+// input (see SetSigHashType), with or without the SIGHASH_FORKID flag.
+func (tx *Tx) SignInputs(signatures []utxo.InputSignature) error {
 	if tx.signed {
 		return fmt.Errorf("already signed")
 	}
 	if len(signatures) != len(tx.msgTx.TxIn) {
 		return fmt.Errorf("expected %v signatures, got %v signatures", len(tx.msgTx.TxIn), len(signatures))
 	}
-
-	for i, rsv := range signatures {
-		r := new(big.Int).SetBytes(rsv[:32])
-		s := new(big.Int).SetBytes(rsv[32:64])
-		signature := btcec.Signature{
-			R: r,
-			S: s,
+	for i, signature := range signatures {
+		if signature.SigHashType != utxo.DefaultSigHashType && resolveSigHashType(signature.SigHashType) != tx.sigHashType(i) {
+			return fmt.Errorf("bad input %v: expected sighash type %v, got sighash type %v", i, tx.sigHashType(i), signature.SigHashType)
 		}
+	}
 
+	for i, signature := range signatures {
 		builder := txscript.NewScriptBuilder()
-		builder.AddData(append(signature.Serialize(), byte(txscript.SigHashAll|SighashForkID)))
-		builder.AddData(pubKey)
+		builder.AddData(tx.encodeSignature(signature.Signature, tx.sigHashType(i)))
+		builder.AddData(signature.PubKey)
 		if tx.inputs[i].SigScript != nil {
 			builder.AddData(tx.inputs[i].SigScript)
 		}
@@ -190,6 +362,21 @@ func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
 	return nil
 }
 
+// IsSigned returns true if the transaction has been signed.
+func (tx *Tx) IsSigned() bool {
+	return tx.signed
+}
+
+// encodeSignature returns the signature, followed by the sighash type with the
+// SIGHASH_FORKID flag, in the format used by the signature scripts of the
+// transaction.
+func (tx *Tx) encodeSignature(rsv pack.Bytes65, sigHashType txscript.SigHashType) []byte {
+	if tx.schnorr {
+		return append(append([]byte{}, rsv[:SchnorrSignatureSize]...), byte(sigHashType|SighashForkID))
+	}
+	return bitcoin.EncodeSignature(rsv, sigHashType|SighashForkID)
+}
+
 func (tx *Tx) Serialize() (pack.Bytes, error) {
 	buf := new(bytes.Buffer)
 	if err := tx.msgTx.Serialize(buf); err != nil {
@@ -1 +1,147 @@
 package bitcoincash_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/multichain/chain/bitcoincash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockClient is a client that serves outputs and raw transactions from memory.
+type mockClient struct {
+	bitcoincash.Client
+	outputs map[string]utxo.Output
+	txs     map[string]btcjson.TxRawResult
+}
+
+func (client *mockClient) Output(ctx context.Context, outpoint utxo.Outpoint) (utxo.Output, pack.U64, error) {
+	output, ok := client.outputs[fmt.Sprintf("%v:%v", hex.EncodeToString(outpoint.Hash), outpoint.Index.Uint32())]
+	if !ok {
+		return utxo.Output{}, pack.NewU64(0), fmt.Errorf("not found")
+	}
+	return output, pack.NewU64(0), nil
+}
+
+func (client *mockClient) RawTransaction(ctx context.Context, txHash pack.Bytes) (btcjson.TxRawResult, error) {
+	tx, ok := client.txs[hex.EncodeToString(txHash)]
+	if !ok {
+		return btcjson.TxRawResult{}, fmt.Errorf("not found")
+	}
+	return tx, nil
+}
+
+var _ = Describe("Size", func() {
+	Context("when estimating the size of signed transactions", func() {
+		It("should not support witness scripts", func() {
+			inputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint: utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:    pack.NewU256FromU64(pack.NewU64(100000)),
+			}}}
+			tx, err := bitcoincash.NewTxBuilder(&chaincfg.RegressionNetParams).BuildTx(inputs, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = bitcoincash.EstimateSize(tx, []bitcoincash.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = bitcoincash.EstimateSize(tx, []bitcoincash.InputScript{{Type: bitcoin.ScriptP2WPKH}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Child-pays-for-parent", func() {
+	params := &chaincfg.RegressionNetParams
+	txBuilder := bitcoincash.NewTxBuilder(params)
+	dust := pack.NewU256FromU64(pack.NewU64(546))
+
+	pubKeys := make([]*btcutil.AddressPubKey, 3)
+	for i := range pubKeys {
+		pubKey, err := btcutil.NewAddressPubKey((*btcec.PublicKey)(&id.NewPrivKey().PublicKey).SerializeCompressed(), params)
+		if err != nil {
+			panic(err)
+		}
+		pubKeys[i] = pubKey
+	}
+	multisigScript, err := txscript.MultiSigScript(pubKeys, 2)
+	if err != nil {
+		panic(err)
+	}
+	shAddr, err := bitcoincash.NewAddressScriptHash(multisigScript, params)
+	if err != nil {
+		panic(err)
+	}
+	shScript, err := txscript.PayToAddrScript(shAddr.BitcoinAddress())
+	if err != nil {
+		panic(err)
+	}
+
+	// The parent spends the grandparent output, pays a fee of 1000 SATs, and
+	// has a size of 400 bytes. Its change output is sent to a multisig
+	// address.
+	grandparentHash := chainhash.Hash{1}
+	parentHash := chainhash.Hash{2}
+	changeOutpoint := utxo.Outpoint{Hash: pack.NewBytes(parentHash[:]), Index: pack.NewU32(1)}
+	client := &mockClient{
+		outputs: map[string]utxo.Output{
+			fmt.Sprintf("%v:0", hex.EncodeToString(grandparentHash[:])): {
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(grandparentHash[:]), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+				PubKeyScript: pack.NewBytes(shScript),
+			},
+			fmt.Sprintf("%v:1", hex.EncodeToString(parentHash[:])): {
+				Outpoint:     changeOutpoint,
+				Value:        pack.NewU256FromU64(pack.NewU64(49000)),
+				PubKeyScript: pack.NewBytes(shScript),
+			},
+		},
+		txs: map[string]btcjson.TxRawResult{
+			hex.EncodeToString(parentHash[:]): {
+				Txid: parentHash.String(),
+				Size: 400,
+				Vin:  []btcjson.Vin{{Txid: grandparentHash.String(), Vout: 0}},
+				Vout: []btcjson.Vout{{Value: 0.0005, N: 0}, {Value: 0.00049, N: 1}},
+			},
+		},
+	}
+
+	Context("when the parent pays a low fee", func() {
+		It("should build a child that pays for the package using its redeem script", func() {
+			builder := bitcoincash.NewCPFPBuilder(client, txBuilder, bitcoincash.NewGasEstimator(pack.NewU256FromU64(pack.NewU64(20))), dust)
+			to := address.Address(shAddr.EncodeAddress())
+
+			// The redeem script is needed to estimate the size of the child.
+			_, err := builder.BuildTx(context.Background(), changeOutpoint, to)
+			Expect(err).To(HaveOccurred())
+
+			tx, err := builder.WithSigScript(multisigScript).BuildTx(context.Background(), changeOutpoint, to)
+			Expect(err).ToNot(HaveOccurred())
+			inputs, err := tx.Inputs()
+			Expect(err).ToNot(HaveOccurred())
+			script, err := bitcoincash.InputScriptFromInput(inputs[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(script.Type).To(Equal(bitcoin.ScriptP2SHMultisig))
+			size, _, err := bitcoincash.EstimateSize(tx, []bitcoincash.InputScript{script})
+			Expect(err).ToNot(HaveOccurred())
+
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outputs).To(HaveLen(1))
+			childFee := 49000 - outputs[0].Value.Int().Uint64()
+			Expect(childFee).To(Equal(20*(400+uint64(size)) - 1000))
+		})
+	})
+})
@@ -0,0 +1,16 @@
+package celo
+
+import "github.com/renproject/multichain/chain/ethereum"
+
+// A GasEstimator on the Celo chain is functionally identical to a gas estimator
+// on the Ethereum chain.
+type GasEstimator = ethereum.GasEstimator
+
+// GasEstimatorOptions on the Celo chain are functionally identical to options
+// on the Ethereum chain.
+type GasEstimatorOptions = ethereum.GasEstimatorOptions
+
+var (
+	NewGasEstimator            = ethereum.NewGasEstimator
+	DefaultGasEstimatorOptions = ethereum.DefaultGasEstimatorOptions
+)
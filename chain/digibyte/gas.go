@@ -3,3 +3,9 @@ package digibyte
 import "github.com/renproject/multichain/chain/bitcoin"
 
 type GasEstimator = bitcoin.GasEstimator
+
+type GasEstimatorOptions = bitcoin.GasEstimatorOptions
+
+var NewSmartGasEstimator = bitcoin.NewSmartGasEstimator
+
+var DefaultGasEstimatorOptions = bitcoin.DefaultGasEstimatorOptions
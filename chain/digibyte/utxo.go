@@ -7,10 +7,71 @@ type (
 	TxBuilder     = bitcoin.TxBuilder
 	Client        = bitcoin.Client
 	ClientOptions = bitcoin.ClientOptions
+	RetryPolicy   = bitcoin.RetryPolicy
+	BackoffPolicy = bitcoin.BackoffPolicy
+	RPCError      = bitcoin.RPCError
+
+	OutputResult        = bitcoin.OutputResult
+	ConfirmationsResult = bitcoin.ConfirmationsResult
+
+	MultiClient        = bitcoin.MultiClient
+	MultiClientOptions = bitcoin.MultiClientOptions
+
+	CoinSelector        = bitcoin.CoinSelector
+	CoinSelectorOptions = bitcoin.CoinSelectorOptions
+
+	InputScript     = bitcoin.InputScript
+	InputScriptFunc = bitcoin.InputScriptFunc
+	ScriptType      = bitcoin.ScriptType
+
+	FeeError         = bitcoin.FeeError
+	FeeGuardOptions  = bitcoin.FeeGuardOptions
+	GuardedTxBuilder = bitcoin.GuardedTxBuilder
+	GuardedClient    = bitcoin.GuardedClient
+
+	BumpFeeOptions = bitcoin.BumpFeeOptions
+
+	CPFPBuilder = bitcoin.CPFPBuilder
 )
 
 var (
 	NewTxBuilder         = bitcoin.NewTxBuilder
 	NewClient            = bitcoin.NewClient
 	DefaultClientOptions = bitcoin.DefaultClientOptions
+	DefaultBackoffPolicy = bitcoin.DefaultBackoffPolicy
+
+	NewMultiClient            = bitcoin.NewMultiClient
+	DefaultMultiClientOptions = bitcoin.DefaultMultiClientOptions
+
+	ErrTxNotFound         = bitcoin.ErrTxNotFound
+	ErrTxAlreadyInChain   = bitcoin.ErrTxAlreadyInChain
+	ErrTxAlreadyInMempool = bitcoin.ErrTxAlreadyInMempool
+	ErrMissingInputs      = bitcoin.ErrMissingInputs
+	ErrInsufficientFee    = bitcoin.ErrInsufficientFee
+	ErrMempoolConflict    = bitcoin.ErrMempoolConflict
+
+	NewCoinSelector            = bitcoin.NewCoinSelector
+	DefaultCoinSelectorOptions = bitcoin.DefaultCoinSelectorOptions
+	SelectCoins                = bitcoin.SelectCoins
+
+	CheckFee               = bitcoin.CheckFee
+	DefaultFeeGuardOptions = bitcoin.DefaultFeeGuardOptions
+	NewGuardedTxBuilder    = bitcoin.NewGuardedTxBuilder
+	NewGuardedClient       = bitcoin.NewGuardedClient
+
+	DefaultBumpFeeOptions = bitcoin.DefaultBumpFeeOptions
+
+	NewCPFPBuilder = bitcoin.NewCPFPBuilder
+
+	MultisigScript         = bitcoin.MultisigScript
+	MultisigAddress        = bitcoin.MultisigAddress
+	WitnessMultisigAddress = bitcoin.WitnessMultisigAddress
+
+	CheckLockTimeVerifyScript = bitcoin.CheckLockTimeVerifyScript
+	CheckSequenceVerifyScript = bitcoin.CheckSequenceVerifyScript
+	RelativeLockTimeBlocks    = bitcoin.RelativeLockTimeBlocks
+	RelativeLockTimeSeconds   = bitcoin.RelativeLockTimeSeconds
+
+	EstimateSize         = bitcoin.EstimateSize
+	InputScriptFromInput = bitcoin.InputScriptFromInput
 )
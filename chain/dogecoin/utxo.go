@@ -1,16 +1,115 @@
 package dogecoin
 
-import "github.com/renproject/multichain/chain/bitcoin"
+import (
+	"github.com/renproject/multichain/api/gas"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+)
 
 type (
 	Tx            = bitcoin.Tx
 	TxBuilder     = bitcoin.TxBuilder
 	Client        = bitcoin.Client
 	ClientOptions = bitcoin.ClientOptions
+	RetryPolicy   = bitcoin.RetryPolicy
+	BackoffPolicy = bitcoin.BackoffPolicy
+	RPCError      = bitcoin.RPCError
+
+	OutputResult        = bitcoin.OutputResult
+	ConfirmationsResult = bitcoin.ConfirmationsResult
+
+	MultiClient        = bitcoin.MultiClient
+	MultiClientOptions = bitcoin.MultiClientOptions
+
+	CoinSelector        = bitcoin.CoinSelector
+	CoinSelectorOptions = bitcoin.CoinSelectorOptions
+
+	InputScript     = bitcoin.InputScript
+	InputScriptFunc = bitcoin.InputScriptFunc
+	ScriptType      = bitcoin.ScriptType
+
+	FeeError         = bitcoin.FeeError
+	FeeGuardOptions  = bitcoin.FeeGuardOptions
+	GuardedTxBuilder = bitcoin.GuardedTxBuilder
+	GuardedClient    = bitcoin.GuardedClient
+
+	BumpFeeOptions = bitcoin.BumpFeeOptions
+
+	CPFPBuilder = bitcoin.CPFPBuilder
 )
 
 var (
 	NewTxBuilder         = bitcoin.NewTxBuilder
 	NewClient            = bitcoin.NewClient
 	DefaultClientOptions = bitcoin.DefaultClientOptions
+	DefaultBackoffPolicy = bitcoin.DefaultBackoffPolicy
+
+	NewMultiClient            = bitcoin.NewMultiClient
+	DefaultMultiClientOptions = bitcoin.DefaultMultiClientOptions
+
+	ErrTxNotFound         = bitcoin.ErrTxNotFound
+	ErrTxAlreadyInChain   = bitcoin.ErrTxAlreadyInChain
+	ErrTxAlreadyInMempool = bitcoin.ErrTxAlreadyInMempool
+	ErrMissingInputs      = bitcoin.ErrMissingInputs
+	ErrInsufficientFee    = bitcoin.ErrInsufficientFee
+	ErrMempoolConflict    = bitcoin.ErrMempoolConflict
+
+	NewCoinSelector = bitcoin.NewCoinSelector
+	SelectCoins     = bitcoin.SelectCoins
+
+	CheckFee            = bitcoin.CheckFee
+	NewGuardedTxBuilder = bitcoin.NewGuardedTxBuilder
+	NewGuardedClient    = bitcoin.NewGuardedClient
+
+	MultisigScript  = bitcoin.MultisigScript
+	MultisigAddress = bitcoin.MultisigAddress
+
+	CheckLockTimeVerifyScript = bitcoin.CheckLockTimeVerifyScript
+	CheckSequenceVerifyScript = bitcoin.CheckSequenceVerifyScript
+	RelativeLockTimeBlocks    = bitcoin.RelativeLockTimeBlocks
+	RelativeLockTimeSeconds   = bitcoin.RelativeLockTimeSeconds
+
+	EstimateSize         = bitcoin.EstimateSizeWithoutWitness
+	InputScriptFromInput = bitcoin.InputScriptFromInputWithoutWitness
 )
+
+// DefaultCoinSelectorOptions returns CoinSelectorOptions with the default
+// settings. Dogecoin has a much larger dust threshold than Bitcoin, and does
+// not support segwit, so witness scripts are not assumed when estimating the
+// size of inputs.
+func DefaultCoinSelectorOptions() CoinSelectorOptions {
+	return bitcoin.DefaultCoinSelectorOptions().
+		WithDustThreshold(pack.NewU256FromU64(pack.NewU64(1000000))).
+		WithInputScript(InputScriptFromInput)
+}
+
+// DefaultFeeGuardOptions returns FeeGuardOptions with the default settings.
+// Dogecoin fees are much larger than Bitcoin fees (when measured in the
+// smallest unit of the chain), so the limits are larger than on Bitcoin.
+// Witness scripts are not assumed when estimating the size of inputs.
+func DefaultFeeGuardOptions() FeeGuardOptions {
+	return bitcoin.DefaultFeeGuardOptions().
+		WithMaxFee(pack.NewU256FromU64(pack.NewU64(1000000000))).
+		WithMaxSatsPerByte(pack.NewU256FromU64(pack.NewU64(100000))).
+		WithInputScript(InputScriptFromInput)
+}
+
+// DefaultBumpFeeOptions returns BumpFeeOptions with the default settings.
+// Dogecoin has a much larger dust threshold than Bitcoin, and does not support
+// segwit, so witness scripts are not assumed when estimating the size of
+// inputs.
+func DefaultBumpFeeOptions() BumpFeeOptions {
+	return bitcoin.DefaultBumpFeeOptions().
+		WithDustThreshold(pack.NewU256FromU64(pack.NewU64(1000000))).
+		WithInputScript(InputScriptFromInput)
+}
+
+// NewCPFPBuilder returns a CPFPBuilder that loads parent transactions using the
+// given client, estimates SATs-per-byte using the given gas estimator, and
+// builds child transactions using the given transaction builder. Dogecoin does
+// not support segwit, so witness scripts are not assumed when estimating the
+// size of child transactions.
+func NewCPFPBuilder(client Client, txBuilder utxo.TxBuilder, gasEstimator gas.Estimator, dustThreshold pack.U256) CPFPBuilder {
+	return bitcoin.NewCPFPBuilder(client, txBuilder, gasEstimator, dustThreshold).WithInputScript(InputScriptFromInput)
+}
@@ -1 +1,29 @@
 package dogecoin_test
+
+import (
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/multichain/chain/dogecoin"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Size", func() {
+	Context("when estimating the size of signed transactions", func() {
+		It("should not support witness scripts", func() {
+			inputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint: utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:    pack.NewU256FromU64(pack.NewU64(100000)),
+			}}}
+			tx, err := dogecoin.NewTxBuilder(&dogecoin.RegressionNetParams).BuildTx(inputs, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = dogecoin.EstimateSize(tx, []dogecoin.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = dogecoin.EstimateSize(tx, []dogecoin.InputScript{{Type: bitcoin.ScriptP2WPKH}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -1 +1,411 @@
 package ethereum
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/renproject/multichain/api/account"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/contract"
+	"github.com/renproject/pack"
+)
+
+// TxType identifies the format of an Ethereum transaction. Typed transactions
+// are wrapped in an envelope that is prefixed by their type (see EIP-2718).
+type TxType uint8
+
+// Enumeration of supported transaction types.
+const (
+	// LegacyTxType is the type of RLP transactions that existed before typed
+	// transaction envelopes were introduced.
+	LegacyTxType = TxType(0x00)
+	// AccessListTxType is the type of transactions that declare the addresses
+	// and storage keys they will access (see EIP-2930).
+	AccessListTxType = TxType(0x01)
+	// DynamicFeeTxType is the type of transactions that pay a base fee and a
+	// priority fee instead of a gas price (see EIP-1559).
+	DynamicFeeTxType = TxType(0x02)
+)
+
+// An AccessTuple declares an address, and the storage keys of that address,
+// that will be accessed by a transaction.
+type AccessTuple struct {
+	Address     Address        `json:"address"`
+	StorageKeys []pack.Bytes32 `json:"storageKeys"`
+}
+
+// An AccessList declares all of the addresses and storage keys that will be
+// accessed by a transaction. Accessing them is cheaper than accessing addresses
+// and storage keys that have not been declared.
+type AccessList []AccessTuple
+
+// fields returns the RLP fields of the access list.
+func (accessList AccessList) fields() []interface{} {
+	fields := make([]interface{}, len(accessList))
+	for i, tuple := range accessList {
+		storageKeys := make([][]byte, len(tuple.StorageKeys))
+		for j := range tuple.StorageKeys {
+			storageKeys[j] = tuple.StorageKeys[j][:]
+		}
+		fields[i] = []interface{}{tuple.Address[:], storageKeys}
+	}
+	return fields
+}
+
+// The TxBuilder is an implementation of an account-compatible transaction
+// builder for Ethereum. By default, it builds legacy RLP transactions that are
+// replay protected using the chain ID (see EIP-155). It can also be configured
+// to build typed transactions that use an access list, or dynamic fees.
+type TxBuilder struct {
+	chainID  pack.U256
+	gasLimit pack.U256
+	gasPrice pack.U256
+
+	txType               TxType
+	maxFeePerGas         pack.U256
+	maxPriorityFeePerGas pack.U256
+	accessList           AccessList
+}
+
+// NewTxBuilder returns a transaction builder that builds account-compatible
+// Ethereum transactions for the given chain ID, using the given gas limit and
+// gas price (in WEI-per-gas). A chain ID of zero builds transactions that are
+// not replay protected, and should only be used for local deployments of the
+// multichain. Because the gas limit and gas price are not part of the Account
+// API, they must be specified during construction of the builder.
+func NewTxBuilder(chainID, gasLimit, gasPrice pack.U256) TxBuilder {
+	return TxBuilder{chainID: chainID, gasLimit: gasLimit, gasPrice: gasPrice, txType: LegacyTxType}
+}
+
+// WithDynamicFee returns a copy of the transaction builder that builds dynamic
+// fee transactions (see EIP-1559). The transactions will pay at most the given
+// maximum fee (in WEI-per-gas), of which at most the given priority fee is paid
+// to the miner. The gas price of the builder is ignored.
+func (txBuilder TxBuilder) WithDynamicFee(maxFeePerGas, maxPriorityFeePerGas pack.U256) TxBuilder {
+	txBuilder.txType = DynamicFeeTxType
+	txBuilder.maxFeePerGas = maxFeePerGas
+	txBuilder.maxPriorityFeePerGas = maxPriorityFeePerGas
+	return txBuilder
+}
+
+// WithAccessList returns a copy of the transaction builder that includes the
+// given access list in all transactions (see EIP-2930). Unless the builder also
+// builds dynamic fee transactions, it will build access list transactions.
+func (txBuilder TxBuilder) WithAccessList(accessList AccessList) TxBuilder {
+	if txBuilder.txType == LegacyTxType {
+		txBuilder.txType = AccessListTxType
+	}
+	txBuilder.accessList = accessList
+	return txBuilder
+}
+
+// BuildTx returns an Ethereum transaction that transfers value from the given
+// sender to the given recipient, and passes the payload as call data to the
+// recipient. If the recipient is empty, the transaction is a contract creation
+// and the payload is interpreted as the contract initialisation code.
+func (txBuilder TxBuilder) BuildTx(from, to address.Address, value, nonce pack.U256, payload pack.Bytes) (account.Tx, error) {
+	if txBuilder.txType != LegacyTxType && txBuilder.chainID.Int().Sign() == 0 {
+		return nil, fmt.Errorf("bad chain id: typed transactions must be replay protected")
+	}
+	fromAddr, err := NewAddressFromHex(string(from))
+	if err != nil {
+		return nil, fmt.Errorf("bad from address: %v", err)
+	}
+	var toAddr *Address
+	if to != "" {
+		addr, err := NewAddressFromHex(string(to))
+		if err != nil {
+			return nil, fmt.Errorf("bad to address: %v", err)
+		}
+		toAddr = &addr
+	}
+	return &Tx{
+		txType:               txBuilder.txType,
+		chainID:              txBuilder.chainID,
+		from:                 fromAddr,
+		to:                   toAddr,
+		value:                value,
+		nonce:                nonce,
+		gasLimit:             txBuilder.gasLimit,
+		gasPrice:             txBuilder.gasPrice,
+		maxFeePerGas:         txBuilder.maxFeePerGas,
+		maxPriorityFeePerGas: txBuilder.maxPriorityFeePerGas,
+		accessList:           txBuilder.accessList,
+		payload:              payload,
+		signed:               false,
+	}, nil
+}
+
+// Tx represents an Ethereum transaction that implements the Account API.
+type Tx struct {
+	txType     TxType
+	chainID    pack.U256
+	from       Address
+	to         *Address
+	value      pack.U256
+	nonce      pack.U256
+	gasLimit   pack.U256
+	payload    pack.Bytes
+	accessList AccessList
+
+	// The gas price is used by legacy and access list transactions, and the
+	// maximum fees are used by dynamic fee transactions.
+	gasPrice             pack.U256
+	maxFeePerGas         pack.U256
+	maxPriorityFeePerGas pack.U256
+
+	v, r, s *big.Int
+
+	signed bool
+}
+
+// Hash returns the Keccak256 hash of the serialized transaction. This is only
+// the hash by which the transaction will be known once it has been signed.
+func (tx *Tx) Hash() pack.Bytes {
+	serial, err := tx.Serialize()
+	if err != nil {
+		return nil
+	}
+	return pack.NewBytes(crypto.Keccak256(serial))
+}
+
+// From returns the address from which value is being sent.
+func (tx *Tx) From() address.Address {
+	return address.Address(tx.from.String())
+}
+
+// To returns the address to which value is being sent. It is empty for
+// contract creation transactions.
+func (tx *Tx) To() address.Address {
+	if tx.to == nil {
+		return address.Address("")
+	}
+	return address.Address(tx.to.String())
+}
+
+// Value being sent from one address to another.
+func (tx *Tx) Value() pack.U256 {
+	return tx.value
+}
+
+// Nonce of the sender at the time the transaction is submitted.
+func (tx *Tx) Nonce() pack.U256 {
+	return tx.nonce
+}
+
+// Payload returns the call data that is passed to the recipient.
+func (tx *Tx) Payload() contract.CallData {
+	return contract.CallData(tx.payload)
+}
+
+// Type of the transaction envelope.
+func (tx *Tx) Type() TxType {
+	return tx.txType
+}
+
+// ChainID that protects the transaction from being replayed on other chains.
+func (tx *Tx) ChainID() pack.U256 {
+	return tx.chainID
+}
+
+// GasLimit returns the maximum amount of gas that the transaction can consume.
+func (tx *Tx) GasLimit() pack.U256 {
+	return tx.gasLimit
+}
+
+// GasPrice returns the WEI-per-gas that will be paid for consumed gas. For
+// dynamic fee transactions, this is the maximum WEI-per-gas that can be paid.
+func (tx *Tx) GasPrice() pack.U256 {
+	if tx.txType == DynamicFeeTxType {
+		return tx.maxFeePerGas
+	}
+	return tx.gasPrice
+}
+
+// MaxFeePerGas returns the maximum WEI-per-gas that a dynamic fee transaction
+// will pay, including the priority fee. For other transactions, this is the gas
+// price.
+func (tx *Tx) MaxFeePerGas() pack.U256 {
+	return tx.GasPrice()
+}
+
+// MaxPriorityFeePerGas returns the maximum WEI-per-gas that a dynamic fee
+// transaction will pay to the miner, on top of the base fee. For other
+// transactions, this is the gas price.
+func (tx *Tx) MaxPriorityFeePerGas() pack.U256 {
+	if tx.txType == DynamicFeeTxType {
+		return tx.maxPriorityFeePerGas
+	}
+	return tx.gasPrice
+}
+
+// AccessList returns the addresses and storage keys that the transaction
+// declares it will access. It is always empty for legacy transactions.
+func (tx *Tx) AccessList() AccessList {
+	return tx.accessList
+}
+
+// Sighashes returns the digest that must be signed before the transaction can
+// be submitted by the client. Ethereum transactions always have exactly one
+// sighash.
+func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
+	fields, err := tx.fields()
+	if err != nil {
+		return nil, err
+	}
+	if tx.txType == LegacyTxType && tx.isProtected() {
+		fields = append(fields, tx.chainID.Int(), uint(0), uint(0))
+	}
+	data, err := tx.encode(fields)
+	if err != nil {
+		return nil, fmt.Errorf("encoding sighash: %v", err)
+	}
+	sighash := [32]byte{}
+	copy(sighash[:], crypto.Keccak256(data))
+	return []pack.Bytes32{pack.NewBytes32(sighash)}, nil
+}
+
+// Sign the transaction by injecting the signature for its sighash. The
+// signature must be in the [R || S || V] format, where V is the recovery
+// identifier (0 or 1). The public key is not needed, because it is recovered
+// from the signature. An error is returned if the recovered public key does not
+// belong to the sender.
+func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
+	if tx.signed {
+		return fmt.Errorf("already signed")
+	}
+	if len(signatures) != 1 {
+		return fmt.Errorf("expected 1 signature, got %v signatures", len(signatures))
+	}
+	sighashes, err := tx.Sighashes()
+	if err != nil {
+		return err
+	}
+
+	sig := signatures[0]
+	recoveryID := sig[64]
+	if recoveryID >= 27 {
+		recoveryID -= 27
+	}
+	if recoveryID > 1 {
+		return fmt.Errorf("bad recovery id: %v", sig[64])
+	}
+	rawSig := make([]byte, 65)
+	copy(rawSig, sig[:64])
+	rawSig[64] = recoveryID
+
+	// Check that the signature belongs to the sender, otherwise the
+	// transaction will be interpreted as being sent from a different address.
+	signer, err := crypto.SigToPub(sighashes[0][:], rawSig)
+	if err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+	if signerAddr := crypto.PubkeyToAddress(*signer); signerAddr != common.Address(tx.from) {
+		return fmt.Errorf("bad signature: expected signer %v, got signer %v", tx.from, Address(signerAddr))
+	}
+
+	// Typed transactions use the recovery identifier directly, whereas legacy
+	// transactions encode the chain ID into it.
+	v := new(big.Int).SetUint64(uint64(recoveryID))
+	if tx.txType == LegacyTxType {
+		if tx.isProtected() {
+			v.Add(v, new(big.Int).Mul(tx.chainID.Int(), big.NewInt(2)))
+			v.Add(v, big.NewInt(35))
+		} else {
+			v.Add(v, big.NewInt(27))
+		}
+	}
+	tx.v = v
+	tx.r = new(big.Int).SetBytes(sig[:32])
+	tx.s = new(big.Int).SetBytes(sig[32:64])
+	tx.signed = true
+	return nil
+}
+
+// Serialize the transaction into its canonical encoding. For legacy
+// transactions, this is an RLP list. For typed transactions, this is the type
+// followed by an RLP list. This is the format in which the transaction will be
+// submitted by the client. Unsigned transactions are serialized with zero
+// signature values.
+func (tx *Tx) Serialize() (pack.Bytes, error) {
+	fields, err := tx.fields()
+	if err != nil {
+		return pack.Bytes{}, err
+	}
+	v, r, s := big.NewInt(0), big.NewInt(0), big.NewInt(0)
+	if tx.signed {
+		v, r, s = tx.v, tx.r, tx.s
+	}
+	data, err := tx.encode(append(fields, v, r, s))
+	if err != nil {
+		return pack.Bytes{}, fmt.Errorf("encoding tx: %v", err)
+	}
+	return pack.NewBytes(data), nil
+}
+
+// isProtected returns true if the transaction is replay protected by its chain
+// ID.
+func (tx *Tx) isProtected() bool {
+	return tx.chainID.Int().Sign() != 0
+}
+
+// fields returns the RLP fields that are common to the sighash and the
+// serialization of the transaction. It does not include signature values.
+func (tx *Tx) fields() ([]interface{}, error) {
+	to := []byte{}
+	if tx.to != nil {
+		to = tx.to[:]
+	}
+	switch tx.txType {
+	case LegacyTxType:
+		return []interface{}{
+			tx.nonce.Int(),
+			tx.gasPrice.Int(),
+			tx.gasLimit.Int(),
+			to,
+			tx.value.Int(),
+			[]byte(tx.payload),
+		}, nil
+	case AccessListTxType:
+		return []interface{}{
+			tx.chainID.Int(),
+			tx.nonce.Int(),
+			tx.gasPrice.Int(),
+			tx.gasLimit.Int(),
+			to,
+			tx.value.Int(),
+			[]byte(tx.payload),
+			tx.accessList.fields(),
+		}, nil
+	case DynamicFeeTxType:
+		return []interface{}{
+			tx.chainID.Int(),
+			tx.nonce.Int(),
+			tx.maxPriorityFeePerGas.Int(),
+			tx.maxFeePerGas.Int(),
+			tx.gasLimit.Int(),
+			to,
+			tx.value.Int(),
+			[]byte(tx.payload),
+			tx.accessList.fields(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("non-exhaustive pattern: tx type %v", tx.txType)
+	}
+}
+
+// encode the fields into an RLP list. Typed transactions prefix the list with
+// their type.
+func (tx *Tx) encode(fields []interface{}) ([]byte, error) {
+	data, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return nil, err
+	}
+	if tx.txType == LegacyTxType {
+		return data, nil
+	}
+	return append([]byte{byte(tx.txType)}, data...), nil
+}
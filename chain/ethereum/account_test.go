@@ -1 +1,257 @@
 package ethereum_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/account"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/chain/ethereum"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// accessTuple is the RLP representation of an access tuple.
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+// accessListTx is the RLP representation of an access list transaction, after
+// its type prefix has been removed.
+type accessListTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+	V, R, S    *big.Int
+}
+
+// dynamicFeeTx is the RLP representation of a dynamic fee transaction, after
+// its type prefix has been removed.
+type dynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+	V, R, S    *big.Int
+}
+
+var _ = Describe("Account", func() {
+	chainID := pack.NewU256FromU64(pack.NewU64(1337))
+	gasLimit := pack.NewU256FromU64(pack.NewU64(21000))
+	gasPrice := pack.NewU256FromU64(pack.NewU64(20000000000))
+
+	buildAndSign := func(txBuilder ethereum.TxBuilder, privKey *id.PrivKey, to address.Address) account.Tx {
+		from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+		tx, err := txBuilder.BuildTx(from, to, pack.NewU256FromU64(pack.NewU64(1000)), pack.NewU256FromU64(pack.NewU64(7)), pack.Bytes{0xde, 0xad})
+		Expect(err).ToNot(HaveOccurred())
+
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sighashes).To(HaveLen(1))
+		hash := id.Hash(sighashes[0])
+		signature, err := privKey.Sign(&hash)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).To(Succeed())
+		return tx
+	}
+
+	Context("when building legacy transactions", func() {
+		It("should produce transactions that are compatible with EIP-155", func() {
+			privKey := id.NewPrivKey()
+			to := address.Address("0x0102030405060708091011121314151617181920")
+			tx := buildAndSign(ethereum.NewTxBuilder(chainID, gasLimit, gasPrice), privKey, to)
+
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			ethTx := new(types.Transaction)
+			Expect(rlp.DecodeBytes(serial, ethTx)).To(Succeed())
+
+			sender, err := types.Sender(types.NewEIP155Signer(chainID.Int()), ethTx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sender).To(Equal(crypto.PubkeyToAddress(privKey.PublicKey)))
+			Expect(ethTx.Hash().Bytes()).To(Equal([]byte(tx.Hash())))
+			Expect(ethTx.Nonce()).To(Equal(uint64(7)))
+			Expect(ethTx.Value()).To(Equal(big.NewInt(1000)))
+			Expect(ethTx.Gas()).To(Equal(uint64(21000)))
+			Expect(ethTx.GasPrice()).To(Equal(gasPrice.Int()))
+			Expect(ethTx.Data()).To(Equal([]byte{0xde, 0xad}))
+			Expect(ethTx.To().Hex()).To(Equal("0x0102030405060708091011121314151617181920"))
+			Expect(tx.To()).To(Equal(address.Address("0102030405060708091011121314151617181920")))
+		})
+
+		It("should produce transactions without replay protection when the chain ID is zero", func() {
+			privKey := id.NewPrivKey()
+			txBuilder := ethereum.NewTxBuilder(pack.NewU256FromU64(pack.NewU64(0)), gasLimit, gasPrice)
+			tx := buildAndSign(txBuilder, privKey, address.Address("0x0102030405060708091011121314151617181920"))
+
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			ethTx := new(types.Transaction)
+			Expect(rlp.DecodeBytes(serial, ethTx)).To(Succeed())
+			Expect(ethTx.Protected()).To(BeFalse())
+
+			sender, err := types.Sender(types.HomesteadSigner{}, ethTx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sender).To(Equal(crypto.PubkeyToAddress(privKey.PublicKey)))
+		})
+
+		It("should produce contract creations when the recipient is empty", func() {
+			privKey := id.NewPrivKey()
+			tx := buildAndSign(ethereum.NewTxBuilder(chainID, gasLimit, gasPrice), privKey, address.Address(""))
+
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			ethTx := new(types.Transaction)
+			Expect(rlp.DecodeBytes(serial, ethTx)).To(Succeed())
+			Expect(ethTx.To()).To(BeNil())
+		})
+	})
+
+	Context("when building typed transactions", func() {
+		accessList := ethereum.AccessList{
+			{
+				Address:     ethereum.Address{0x01},
+				StorageKeys: []pack.Bytes32{{0x02}, {0x03}},
+			},
+		}
+		expectedAccessList := []accessTuple{
+			{
+				Address:     common.Address{0x01},
+				StorageKeys: []common.Hash{{0x02}, {0x03}},
+			},
+		}
+
+		It("should produce access list transactions", func() {
+			privKey := id.NewPrivKey()
+			txBuilder := ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).WithAccessList(accessList)
+			tx := buildAndSign(txBuilder, privKey, address.Address("0x0102030405060708091011121314151617181920"))
+			Expect(tx.(*ethereum.Tx).Type()).To(Equal(ethereum.AccessListTxType))
+
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serial[0]).To(Equal(byte(ethereum.AccessListTxType)))
+			Expect([]byte(tx.Hash())).To(Equal(crypto.Keccak256(serial)))
+
+			decoded := accessListTx{}
+			Expect(rlp.DecodeBytes(serial[1:], &decoded)).To(Succeed())
+			Expect(decoded.ChainID).To(Equal(chainID.Int()))
+			Expect(decoded.Nonce).To(Equal(uint64(7)))
+			Expect(decoded.GasPrice).To(Equal(gasPrice.Int()))
+			Expect(decoded.Gas).To(Equal(uint64(21000)))
+			Expect(decoded.Value).To(Equal(big.NewInt(1000)))
+			Expect(decoded.Data).To(Equal([]byte{0xde, 0xad}))
+			Expect(decoded.AccessList).To(Equal(expectedAccessList))
+
+			// The signature values should recover the sender from the
+			// sighash, which commits to the type prefix.
+			unsigned, err := rlp.EncodeToBytes([]interface{}{decoded.ChainID, decoded.Nonce, decoded.GasPrice, decoded.Gas, decoded.To, decoded.Value, decoded.Data, decoded.AccessList})
+			Expect(err).ToNot(HaveOccurred())
+			sighash := crypto.Keccak256(append([]byte{0x01}, unsigned...))
+			Expect(decoded.V.Uint64()).To(BeNumerically("<=", 1))
+			sig := append(append(common.LeftPadBytes(decoded.R.Bytes(), 32), common.LeftPadBytes(decoded.S.Bytes(), 32)...), byte(decoded.V.Uint64()))
+			signer, err := crypto.SigToPub(sighash, sig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(crypto.PubkeyToAddress(*signer)).To(Equal(crypto.PubkeyToAddress(privKey.PublicKey)))
+		})
+
+		It("should produce dynamic fee transactions", func() {
+			maxFeePerGas := pack.NewU256FromU64(pack.NewU64(100000000000))
+			maxPriorityFeePerGas := pack.NewU256FromU64(pack.NewU64(2000000000))
+
+			privKey := id.NewPrivKey()
+			txBuilder := ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).WithDynamicFee(maxFeePerGas, maxPriorityFeePerGas).WithAccessList(accessList)
+			tx := buildAndSign(txBuilder, privKey, address.Address(""))
+			Expect(tx.(*ethereum.Tx).Type()).To(Equal(ethereum.DynamicFeeTxType))
+			Expect(tx.(*ethereum.Tx).GasPrice()).To(Equal(maxFeePerGas))
+			Expect(tx.(*ethereum.Tx).MaxPriorityFeePerGas()).To(Equal(maxPriorityFeePerGas))
+
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serial[0]).To(Equal(byte(ethereum.DynamicFeeTxType)))
+			Expect([]byte(tx.Hash())).To(Equal(crypto.Keccak256(serial)))
+
+			decoded := dynamicFeeTx{}
+			Expect(rlp.DecodeBytes(serial[1:], &decoded)).To(Succeed())
+			Expect(decoded.ChainID).To(Equal(chainID.Int()))
+			Expect(decoded.GasTipCap).To(Equal(maxPriorityFeePerGas.Int()))
+			Expect(decoded.GasFeeCap).To(Equal(maxFeePerGas.Int()))
+			Expect(decoded.To).To(BeNil())
+			Expect(decoded.AccessList).To(Equal(expectedAccessList))
+
+			unsigned, err := rlp.EncodeToBytes([]interface{}{decoded.ChainID, decoded.Nonce, decoded.GasTipCap, decoded.GasFeeCap, decoded.Gas, []byte{}, decoded.Value, decoded.Data, decoded.AccessList})
+			Expect(err).ToNot(HaveOccurred())
+			sighash := crypto.Keccak256(append([]byte{0x02}, unsigned...))
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sighashes[0][:]).To(Equal(sighash))
+			sig := append(append(common.LeftPadBytes(decoded.R.Bytes(), 32), common.LeftPadBytes(decoded.S.Bytes(), 32)...), byte(decoded.V.Uint64()))
+			signer, err := crypto.SigToPub(sighash, sig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(crypto.PubkeyToAddress(*signer)).To(Equal(crypto.PubkeyToAddress(privKey.PublicKey)))
+		})
+
+		It("should return an error if the chain ID is zero", func() {
+			privKey := id.NewPrivKey()
+			from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+			txBuilder := ethereum.NewTxBuilder(pack.NewU256FromU64(pack.NewU64(0)), gasLimit, gasPrice).WithAccessList(accessList)
+			_, err := txBuilder.BuildTx(from, from, pack.NewU256FromU64(pack.NewU64(1)), pack.NewU256FromU64(pack.NewU64(0)), nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when signing transactions", func() {
+		It("should return an error if the signature does not belong to the sender", func() {
+			privKey := id.NewPrivKey()
+			otherPrivKey := id.NewPrivKey()
+			from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+			tx, err := ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).BuildTx(from, from, pack.NewU256FromU64(pack.NewU64(1)), pack.NewU256FromU64(pack.NewU64(0)), nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			hash := id.Hash(sighashes[0])
+			signature, err := otherPrivKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).ToNot(Succeed())
+		})
+
+		It("should return an error if the transaction is already signed", func() {
+			privKey := id.NewPrivKey()
+			tx := buildAndSign(ethereum.NewTxBuilder(chainID, gasLimit, gasPrice), privKey, address.Address(""))
+			Expect(tx.Sign([]pack.Bytes65{{}}, nil)).ToNot(Succeed())
+		})
+
+		It("should return an error if the number of signatures is wrong", func() {
+			privKey := id.NewPrivKey()
+			from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+			tx, err := ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).BuildTx(from, from, pack.NewU256FromU64(pack.NewU64(1)), pack.NewU256FromU64(pack.NewU64(0)), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Sign([]pack.Bytes65{}, nil)).ToNot(Succeed())
+			Expect(tx.Sign([]pack.Bytes65{{}, {}}, nil)).ToNot(Succeed())
+		})
+	})
+
+	Context("when the sender is invalid", func() {
+		It("should return an error", func() {
+			_, err := ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).BuildTx(address.Address("0xinvalid"), address.Address(""), pack.NewU256FromU64(pack.NewU64(1)), pack.NewU256FromU64(pack.NewU64(0)), nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -1 +1,218 @@
 package ethereum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/renproject/multichain/api/account"
+	"github.com/renproject/pack"
+)
+
+const (
+	// DefaultClientTimeout used by the Client.
+	DefaultClientTimeout = time.Minute
+	// DefaultClientHost used by the Client. This should only be used for local
+	// deployments of the multichain.
+	DefaultClientHost = "http://127.0.0.1:8545"
+)
+
+// ClientOptions are used to parameterise the behaviour of the Client.
+type ClientOptions struct {
+	Timeout time.Duration
+	Host    string
+}
+
+// DefaultClientOptions returns ClientOptions with the default settings. These
+// settings are valid for use with the default local deployment of the
+// multichain. In production, the host should be changed.
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout: DefaultClientTimeout,
+		Host:    DefaultClientHost,
+	}
+}
+
+// WithHost sets the URL of the Ethereum node.
+func (opts ClientOptions) WithHost(host string) ClientOptions {
+	opts.Host = host
+	return opts
+}
+
+// WithTimeout sets the timeout used for each request to the Ethereum node.
+func (opts ClientOptions) WithTimeout(timeout time.Duration) ClientOptions {
+	opts.Timeout = timeout
+	return opts
+}
+
+// A Client interacts with an instance of the Ethereum network using the
+// JSON-RPC interface exposed by an Ethereum node. It implements the Account
+// API.
+type Client struct {
+	opts      ClientOptions
+	rpcClient *rpc.Client
+}
+
+// NewClient returns a new Client. No connection is established with the node
+// until the first request is made.
+func NewClient(opts ClientOptions) (*Client, error) {
+	rpcClient, err := rpc.DialHTTPWithClient(opts.Host, &http.Client{Timeout: opts.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("dialing %v: %v", opts.Host, err)
+	}
+	return &Client{opts: opts, rpcClient: rpcClient}, nil
+}
+
+// Tx returns the transaction uniquely identified by the given transaction hash,
+// and its number of confirmations. Transactions that are still pending have
+// zero confirmations.
+func (client *Client) Tx(ctx context.Context, txHash pack.Bytes) (account.Tx, pack.U64, error) {
+	resp := (*rpcTx)(nil)
+	if err := client.rpcClient.CallContext(ctx, &resp, "eth_getTransactionByHash", common.BytesToHash(txHash)); err != nil {
+		return nil, pack.NewU64(0), fmt.Errorf("bad \"eth_getTransactionByHash\": %v", err)
+	}
+	if resp == nil {
+		return nil, pack.NewU64(0), fmt.Errorf("bad \"eth_getTransactionByHash\": tx %v not found", hexutil.Encode(txHash))
+	}
+	tx, err := resp.tx()
+	if err != nil {
+		return nil, pack.NewU64(0), fmt.Errorf("bad tx: %v", err)
+	}
+	if !bytes.Equal(tx.Hash(), txHash) {
+		return nil, pack.NewU64(0), fmt.Errorf("bad tx: expected hash %v, got hash %v", hexutil.Encode(txHash), hexutil.Encode(tx.Hash()))
+	}
+	if resp.BlockNumber == nil {
+		return tx, pack.NewU64(0), nil
+	}
+
+	latest := hexutil.Uint64(0)
+	if err := client.rpcClient.CallContext(ctx, &latest, "eth_blockNumber"); err != nil {
+		return nil, pack.NewU64(0), fmt.Errorf("bad \"eth_blockNumber\": %v", err)
+	}
+	if uint64(latest) < uint64(*resp.BlockNumber) {
+		return tx, pack.NewU64(0), nil
+	}
+	return tx, pack.NewU64(uint64(latest) - uint64(*resp.BlockNumber) + 1), nil
+}
+
+// SubmitTx to the Ethereum network. The transaction must be signed.
+func (client *Client) SubmitTx(ctx context.Context, tx account.Tx) error {
+	serial, err := tx.Serialize()
+	if err != nil {
+		return fmt.Errorf("bad tx: %v", err)
+	}
+	resp := common.Hash{}
+	if err := client.rpcClient.CallContext(ctx, &resp, "eth_sendRawTransaction", hexutil.Bytes(serial)); err != nil {
+		return fmt.Errorf("bad \"eth_sendRawTransaction\": %v", err)
+	}
+	return nil
+}
+
+// rpcTx is the JSON representation of a transaction that is returned by the
+// "eth_getTransactionByHash" method.
+type rpcTx struct {
+	BlockNumber          *hexutil.Uint64  `json:"blockNumber"`
+	Type                 *hexutil.Uint64  `json:"type"`
+	ChainID              *hexutil.Big     `json:"chainId"`
+	From                 common.Address   `json:"from"`
+	To                   *common.Address  `json:"to"`
+	Nonce                hexutil.Big      `json:"nonce"`
+	Gas                  hexutil.Big      `json:"gas"`
+	GasPrice             *hexutil.Big     `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big     `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big     `json:"maxPriorityFeePerGas"`
+	Value                hexutil.Big      `json:"value"`
+	Input                hexutil.Bytes    `json:"input"`
+	AccessList           []rpcAccessTuple `json:"accessList"`
+	V                    hexutil.Big      `json:"v"`
+	R                    hexutil.Big      `json:"r"`
+	S                    hexutil.Big      `json:"s"`
+}
+
+// rpcAccessTuple is the JSON representation of an AccessTuple.
+type rpcAccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// tx converts the JSON representation of a signed transaction into a Tx. For
+// legacy transactions, the chain ID is recovered from the V value of the
+// signature.
+func (resp *rpcTx) tx() (*Tx, error) {
+	txType := LegacyTxType
+	if resp.Type != nil {
+		txType = TxType(*resp.Type)
+	}
+
+	v := (*big.Int)(&resp.V)
+	chainID := big.NewInt(0)
+	switch txType {
+	case LegacyTxType:
+		switch {
+		case v.Cmp(big.NewInt(35)) >= 0:
+			chainID.Sub(v, big.NewInt(35))
+			chainID.Rsh(chainID, 1)
+		case v.Cmp(big.NewInt(27)) == 0, v.Cmp(big.NewInt(28)) == 0:
+		default:
+			return nil, fmt.Errorf("bad v: %v", v)
+		}
+	case AccessListTxType, DynamicFeeTxType:
+		if resp.ChainID == nil {
+			return nil, fmt.Errorf("bad chain id: expected chain id for tx type %v", txType)
+		}
+		chainID.Set((*big.Int)(resp.ChainID))
+	default:
+		return nil, fmt.Errorf("non-exhaustive pattern: tx type %v", txType)
+	}
+
+	var to *Address
+	if resp.To != nil {
+		addr := Address(*resp.To)
+		to = &addr
+	}
+	var accessList AccessList
+	if resp.AccessList != nil {
+		accessList = make(AccessList, len(resp.AccessList))
+		for i, tuple := range resp.AccessList {
+			storageKeys := make([]pack.Bytes32, len(tuple.StorageKeys))
+			for j := range tuple.StorageKeys {
+				storageKeys[j] = pack.NewBytes32(tuple.StorageKeys[j])
+			}
+			accessList[i] = AccessTuple{Address: Address(tuple.Address), StorageKeys: storageKeys}
+		}
+	}
+	tx := &Tx{
+		txType:     txType,
+		chainID:    pack.NewU256FromInt(chainID),
+		from:       Address(resp.From),
+		to:         to,
+		value:      pack.NewU256FromInt((*big.Int)(&resp.Value)),
+		nonce:      pack.NewU256FromInt((*big.Int)(&resp.Nonce)),
+		gasLimit:   pack.NewU256FromInt((*big.Int)(&resp.Gas)),
+		payload:    pack.NewBytes(resp.Input),
+		accessList: accessList,
+		v:          new(big.Int).Set(v),
+		r:          new(big.Int).Set((*big.Int)(&resp.R)),
+		s:          new(big.Int).Set((*big.Int)(&resp.S)),
+		signed:     true,
+	}
+	if txType == DynamicFeeTxType {
+		if resp.MaxFeePerGas == nil || resp.MaxPriorityFeePerGas == nil {
+			return nil, fmt.Errorf("bad fees: expected max fee and max priority fee")
+		}
+		tx.maxFeePerGas = pack.NewU256FromInt((*big.Int)(resp.MaxFeePerGas))
+		tx.maxPriorityFeePerGas = pack.NewU256FromInt((*big.Int)(resp.MaxPriorityFeePerGas))
+	} else {
+		if resp.GasPrice == nil {
+			return nil, fmt.Errorf("bad fees: expected gas price")
+		}
+		tx.gasPrice = pack.NewU256FromInt((*big.Int)(resp.GasPrice))
+	}
+	return tx, nil
+}
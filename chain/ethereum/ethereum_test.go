@@ -1 +1,220 @@
 package ethereum_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/chain/ethereum"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockEthService is an in-process stand-in for the "eth" namespace of the
+// JSON-RPC interface exposed by an Ethereum node. Submitted transactions are
+// included in the next block that is mined.
+type mockEthService struct {
+	mu      *sync.Mutex
+	chainID *big.Int
+	height  uint64
+	pending []*types.Transaction
+	txs     map[common.Hash]*types.Transaction
+	blocks  map[common.Hash]uint64
+
+	gasPrice    *big.Int
+	baseFees    []*big.Int
+	rewards     []*big.Int
+	percentiles []float64
+}
+
+func newMockEthService(chainID *big.Int) *mockEthService {
+	return &mockEthService{
+		mu:      new(sync.Mutex),
+		chainID: chainID,
+		txs:     map[common.Hash]*types.Transaction{},
+		blocks:  map[common.Hash]uint64{},
+	}
+}
+
+func (service *mockEthService) mine(n uint64) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for _, tx := range service.pending {
+		service.blocks[tx.Hash()] = service.height + 1
+	}
+	service.pending = nil
+	service.height += n
+}
+
+func (service *mockEthService) BlockNumber() hexutil.Uint64 {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	return hexutil.Uint64(service.height)
+}
+
+func (service *mockEthService) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := types.Sender(types.NewEIP155Signer(service.chainID), tx); err != nil {
+		return common.Hash{}, err
+	}
+	if _, ok := service.txs[tx.Hash()]; ok {
+		return common.Hash{}, fmt.Errorf("known transaction: %x", tx.Hash())
+	}
+	service.txs[tx.Hash()] = tx
+	service.pending = append(service.pending, tx)
+	return tx.Hash(), nil
+}
+
+func (service *mockEthService) GasPrice() *hexutil.Big {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	return (*hexutil.Big)(service.gasPrice)
+}
+
+func (service *mockEthService) FeeHistory(blockCount hexutil.Uint64, newestBlock string, percentiles []float64) (map[string]interface{}, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if len(percentiles) != 1 {
+		return nil, fmt.Errorf("expected one percentile, got %v", len(percentiles))
+	}
+	service.percentiles = percentiles
+	baseFees := make([]*hexutil.Big, len(service.baseFees))
+	for i := range service.baseFees {
+		baseFees[i] = (*hexutil.Big)(service.baseFees[i])
+	}
+	rewards := make([][]*hexutil.Big, len(service.rewards))
+	for i := range service.rewards {
+		rewards[i] = []*hexutil.Big{(*hexutil.Big)(service.rewards[i])}
+	}
+	return map[string]interface{}{
+		"oldestBlock":   hexutil.Uint64(service.height - uint64(len(rewards)) + 1),
+		"baseFeePerGas": baseFees,
+		"reward":        rewards,
+	}, nil
+}
+
+func (service *mockEthService) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	tx, ok := service.txs[hash]
+	if !ok {
+		return nil, nil
+	}
+	from, err := types.Sender(types.NewEIP155Signer(service.chainID), tx)
+	if err != nil {
+		return nil, err
+	}
+	v, r, s := tx.RawSignatureValues()
+	resp := map[string]interface{}{
+		"blockNumber": nil,
+		"from":        from,
+		"to":          tx.To(),
+		"nonce":       hexutil.Uint64(tx.Nonce()),
+		"gas":         hexutil.Uint64(tx.Gas()),
+		"gasPrice":    (*hexutil.Big)(tx.GasPrice()),
+		"value":       (*hexutil.Big)(tx.Value()),
+		"input":       hexutil.Bytes(tx.Data()),
+		"hash":        tx.Hash(),
+		"v":           (*hexutil.Big)(v),
+		"r":           (*hexutil.Big)(r),
+		"s":           (*hexutil.Big)(s),
+	}
+	if block, ok := service.blocks[hash]; ok {
+		resp["blockNumber"] = hexutil.Uint64(block)
+	}
+	return resp, nil
+}
+
+var _ = Describe("Client", func() {
+	chainID := pack.NewU256FromU64(pack.NewU64(1337))
+
+	var service *mockEthService
+	var server *httptest.Server
+	var client *ethereum.Client
+
+	BeforeEach(func() {
+		service = newMockEthService(chainID.Int())
+		rpcServer := rpc.NewServer()
+		Expect(rpcServer.RegisterName("eth", service)).To(Succeed())
+		server = httptest.NewServer(rpcServer)
+
+		var err error
+		client, err = ethereum.NewClient(ethereum.DefaultClientOptions().WithHost(server.URL))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when submitting transactions", func() {
+		It("should return the transaction with the number of confirmations", func() {
+			privKey := id.NewPrivKey()
+			from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+			to := address.Address("0x0102030405060708091011121314151617181920")
+			txBuilder := ethereum.NewTxBuilder(chainID, pack.NewU256FromU64(pack.NewU64(21000)), pack.NewU256FromU64(pack.NewU64(1)))
+			tx, err := txBuilder.BuildTx(from, to, pack.NewU256FromU64(pack.NewU64(1000)), pack.NewU256FromU64(pack.NewU64(0)), pack.Bytes{0x01})
+			Expect(err).ToNot(HaveOccurred())
+
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			hash := id.Hash(sighashes[0])
+			signature, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).To(Succeed())
+			Expect(client.SubmitTx(context.Background(), tx)).To(Succeed())
+
+			// The transaction is pending, so it should have no confirmations.
+			pendingTx, confs, err := client.Tx(context.Background(), tx.Hash())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(pack.NewU64(0)))
+			Expect(pendingTx.Hash()).To(Equal(tx.Hash()))
+
+			service.mine(3)
+			confirmedTx, confs, err := client.Tx(context.Background(), tx.Hash())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confs).To(Equal(pack.NewU64(3)))
+			Expect(confirmedTx.Hash()).To(Equal(tx.Hash()))
+			Expect(confirmedTx.From()).To(Equal(tx.From()))
+			Expect(confirmedTx.To()).To(Equal(tx.To()))
+			Expect(confirmedTx.Value()).To(Equal(tx.Value()))
+			Expect(confirmedTx.Nonce()).To(Equal(tx.Nonce()))
+			Expect(confirmedTx.Payload()).To(Equal(tx.Payload()))
+			Expect(confirmedTx.(*ethereum.Tx).ChainID()).To(Equal(chainID))
+
+			// Submitting the same transaction again should be rejected by the
+			// node.
+			Expect(client.SubmitTx(context.Background(), tx)).ToNot(Succeed())
+		})
+	})
+
+	Context("when the transaction does not exist", func() {
+		It("should return an error", func() {
+			_, _, err := client.Tx(context.Background(), pack.NewBytes(make([]byte, 32)))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
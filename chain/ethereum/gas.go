@@ -1 +1,153 @@
 package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/renproject/pack"
+)
+
+const (
+	// DefaultGasEstimatorFeeHistoryBlocks used by the GasEstimator. It is the
+	// number of recent blocks that are used to estimate the priority fee.
+	DefaultGasEstimatorFeeHistoryBlocks = uint64(20)
+	// DefaultGasEstimatorFeeHistoryPercentile used by the GasEstimator. It is
+	// the percentile of priority fees, in each block, that is used to estimate
+	// the priority fee.
+	DefaultGasEstimatorFeeHistoryPercentile = float64(50)
+)
+
+// weiPerGwei is the number of WEI in one GWEI.
+var weiPerGwei = big.NewInt(1e9)
+
+// GasEstimatorOptions are used to parameterise the behaviour of the
+// GasEstimator.
+type GasEstimatorOptions struct {
+	DynamicFee           bool
+	FeeHistoryBlocks     uint64
+	FeeHistoryPercentile float64
+}
+
+// DefaultGasEstimatorOptions returns GasEstimatorOptions with the default
+// settings. By default, the "eth_gasPrice" method is used, because it is
+// supported by all Ethereum-compatible chains.
+func DefaultGasEstimatorOptions() GasEstimatorOptions {
+	return GasEstimatorOptions{
+		DynamicFee:           false,
+		FeeHistoryBlocks:     DefaultGasEstimatorFeeHistoryBlocks,
+		FeeHistoryPercentile: DefaultGasEstimatorFeeHistoryPercentile,
+	}
+}
+
+// WithDynamicFee sets whether or not the "eth_feeHistory" method is used to
+// estimate gas prices. This should only be enabled for chains that support
+// EIP-1559.
+func (opts GasEstimatorOptions) WithDynamicFee(dynamicFee bool) GasEstimatorOptions {
+	opts.DynamicFee = dynamicFee
+	return opts
+}
+
+// WithFeeHistoryBlocks sets the number of recent blocks that are used to
+// estimate the priority fee.
+func (opts GasEstimatorOptions) WithFeeHistoryBlocks(blocks uint64) GasEstimatorOptions {
+	opts.FeeHistoryBlocks = blocks
+	return opts
+}
+
+// WithFeeHistoryPercentile sets the percentile of priority fees, in each block,
+// that is used to estimate the priority fee.
+func (opts GasEstimatorOptions) WithFeeHistoryPercentile(percentile float64) GasEstimatorOptions {
+	opts.FeeHistoryPercentile = percentile
+	return opts
+}
+
+// A GasEstimator returns the GWEI-per-gas that is needed in order to confirm
+// transactions within one of the next few blocks. For legacy chains, this is
+// the gas price suggested by the node. For EIP-1559 chains, this is the base
+// fee of the next block, plus the median of recent priority fees.
+type GasEstimator struct {
+	client *Client
+	opts   GasEstimatorOptions
+}
+
+// NewGasEstimator returns a GasEstimator that uses the given client to query
+// recent gas prices.
+func NewGasEstimator(client *Client, opts GasEstimatorOptions) GasEstimator {
+	return GasEstimator{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// EstimateGasPrice returns the number of GWEI-per-gas that is needed in order
+// to confirm transactions within one of the next few blocks. The estimate is
+// rounded up to the nearest GWEI.
+func (gasEstimator GasEstimator) EstimateGasPrice(ctx context.Context) (pack.U256, error) {
+	var weiPerGas *big.Int
+	var err error
+	if gasEstimator.opts.DynamicFee {
+		weiPerGas, err = gasEstimator.estimateDynamicFee(ctx)
+	} else {
+		weiPerGas, err = gasEstimator.estimateGasPrice(ctx)
+	}
+	if err != nil {
+		return pack.U256{}, err
+	}
+
+	gweiPerGas := new(big.Int).Add(weiPerGas, new(big.Int).Sub(weiPerGwei, big.NewInt(1)))
+	gweiPerGas.Div(gweiPerGas, weiPerGwei)
+	return pack.NewU256FromInt(gweiPerGas), nil
+}
+
+// estimateGasPrice returns the WEI-per-gas suggested by the "eth_gasPrice"
+// method.
+func (gasEstimator GasEstimator) estimateGasPrice(ctx context.Context) (*big.Int, error) {
+	resp := hexutil.Big{}
+	if err := gasEstimator.client.rpcClient.CallContext(ctx, &resp, "eth_gasPrice"); err != nil {
+		return nil, fmt.Errorf("bad \"eth_gasPrice\": %v", err)
+	}
+	return (*big.Int)(&resp), nil
+}
+
+// estimateDynamicFee returns the WEI-per-gas computed from the "eth_feeHistory"
+// method. It is the base fee of the next block, plus the median of the
+// priority fees at the configured percentile of each recent block.
+func (gasEstimator GasEstimator) estimateDynamicFee(ctx context.Context) (*big.Int, error) {
+	resp := rpcFeeHistory{}
+	if err := gasEstimator.client.rpcClient.CallContext(ctx, &resp, "eth_feeHistory", hexutil.Uint64(gasEstimator.opts.FeeHistoryBlocks), "latest", []float64{gasEstimator.opts.FeeHistoryPercentile}); err != nil {
+		return nil, fmt.Errorf("bad \"eth_feeHistory\": %v", err)
+	}
+	// The base fee of the next block is the last base fee in the history.
+	if len(resp.BaseFeePerGas) == 0 {
+		return nil, fmt.Errorf("bad \"eth_feeHistory\": expected base fee")
+	}
+	baseFee := (*big.Int)(resp.BaseFeePerGas[len(resp.BaseFeePerGas)-1])
+
+	tips := make([]*big.Int, 0, len(resp.Reward))
+	for _, reward := range resp.Reward {
+		if len(reward) == 0 {
+			continue
+		}
+		tips = append(tips, (*big.Int)(reward[0]))
+	}
+	tip := big.NewInt(0)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool {
+			return tips[i].Cmp(tips[j]) < 0
+		})
+		tip = tips[len(tips)/2]
+	}
+	return new(big.Int).Add(baseFee, tip), nil
+}
+
+// rpcFeeHistory is the JSON representation of the result of the
+// "eth_feeHistory" method.
+type rpcFeeHistory struct {
+	OldestBlock   *hexutil.Big     `json:"oldestBlock"`
+	BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64        `json:"gasUsedRatio"`
+	Reward        [][]*hexutil.Big `json:"reward"`
+}
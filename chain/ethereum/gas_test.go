@@ -1 +1,84 @@
 package ethereum_test
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/renproject/multichain/chain/ethereum"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gas", func() {
+	var service *mockEthService
+	var server *httptest.Server
+	var client *ethereum.Client
+
+	BeforeEach(func() {
+		service = newMockEthService(big.NewInt(1337))
+		rpcServer := rpc.NewServer()
+		Expect(rpcServer.RegisterName("eth", service)).To(Succeed())
+		server = httptest.NewServer(rpcServer)
+
+		var err error
+		client, err = ethereum.NewClient(ethereum.DefaultClientOptions().WithHost(server.URL))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	gwei := func(n int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+	}
+
+	Context("when estimating gas for legacy chains", func() {
+		It("should return the gas price suggested by the node", func() {
+			service.gasPrice = gwei(42)
+			gasEstimator := ethereum.NewGasEstimator(client, ethereum.DefaultGasEstimatorOptions())
+			gweiPerGas, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gweiPerGas).To(Equal(pack.NewU256FromU64(pack.NewU64(42))))
+		})
+
+		It("should round up to the nearest GWEI", func() {
+			service.gasPrice = new(big.Int).Add(gwei(42), big.NewInt(1))
+			gasEstimator := ethereum.NewGasEstimator(client, ethereum.DefaultGasEstimatorOptions())
+			gweiPerGas, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gweiPerGas).To(Equal(pack.NewU256FromU64(pack.NewU64(43))))
+		})
+	})
+
+	Context("when estimating gas for EIP-1559 chains", func() {
+		It("should return the next base fee plus the median priority fee", func() {
+			service.height = 100
+			service.baseFees = []*big.Int{gwei(10), gwei(11), gwei(12), gwei(13)}
+			service.rewards = []*big.Int{gwei(3), gwei(1), gwei(2)}
+
+			opts := ethereum.DefaultGasEstimatorOptions().
+				WithDynamicFee(true).
+				WithFeeHistoryBlocks(3).
+				WithFeeHistoryPercentile(60)
+			gasEstimator := ethereum.NewGasEstimator(client, opts)
+			gweiPerGas, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gweiPerGas).To(Equal(pack.NewU256FromU64(pack.NewU64(15))))
+			Expect(service.percentiles).To(Equal([]float64{60}))
+		})
+
+		It("should return the next base fee when there are no priority fees", func() {
+			service.baseFees = []*big.Int{gwei(13)}
+
+			gasEstimator := ethereum.NewGasEstimator(client, ethereum.DefaultGasEstimatorOptions().WithDynamicFee(true))
+			gweiPerGas, err := gasEstimator.EstimateGasPrice(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gweiPerGas).To(Equal(pack.NewU256FromU64(pack.NewU64(13))))
+		})
+	})
+})
@@ -0,0 +1,156 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+)
+
+// marshalledTx is the representation of a Tx that is used for binary and JSON
+// marshalling. The recipient is empty for contract creation transactions, and
+// the signature values are zero for unsigned transactions.
+type marshalledTx struct {
+	Type                 TxType          `json:"type"`
+	ChainID              pack.U256       `json:"chainID"`
+	From                 Address         `json:"from"`
+	To                   address.Address `json:"to"`
+	Value                pack.U256       `json:"value"`
+	Nonce                pack.U256       `json:"nonce"`
+	GasLimit             pack.U256       `json:"gasLimit"`
+	Payload              pack.Bytes      `json:"payload"`
+	AccessList           AccessList      `json:"accessList"`
+	GasPrice             pack.U256       `json:"gasPrice"`
+	MaxFeePerGas         pack.U256       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas pack.U256       `json:"maxPriorityFeePerGas"`
+	V                    pack.U256       `json:"v"`
+	R                    pack.U256       `json:"r"`
+	S                    pack.U256       `json:"s"`
+	Signed               bool            `json:"signed"`
+}
+
+// SizeHint returns the number of bytes required to represent the transaction
+// in binary.
+func (tx *Tx) SizeHint() int {
+	return surge.SizeHint(tx.marshalled())
+}
+
+// Marshal the transaction to binary. All fields of the transaction, including
+// its type and whether or not it is signed, are preserved.
+func (tx *Tx) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	return surge.Marshal(tx.marshalled(), buf, rem)
+}
+
+// Unmarshal the transaction from binary.
+func (tx *Tx) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	marshalled := marshalledTx{}
+	buf, rem, err := surge.Unmarshal(&marshalled, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if err := tx.unmarshalled(marshalled); err != nil {
+		return buf, rem, err
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface.
+func (tx *Tx) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tx.marshalled())
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface.
+func (tx *Tx) UnmarshalJSON(data []byte) error {
+	marshalled := marshalledTx{}
+	if err := json.Unmarshal(data, &marshalled); err != nil {
+		return err
+	}
+	return tx.unmarshalled(marshalled)
+}
+
+func (tx *Tx) marshalled() marshalledTx {
+	return marshalledTx{
+		Type:                 tx.txType,
+		ChainID:              u256OrZero(tx.chainID),
+		From:                 tx.from,
+		To:                   tx.To(),
+		Value:                u256OrZero(tx.value),
+		Nonce:                u256OrZero(tx.nonce),
+		GasLimit:             u256OrZero(tx.gasLimit),
+		Payload:              tx.payload,
+		AccessList:           tx.accessList,
+		GasPrice:             u256OrZero(tx.gasPrice),
+		MaxFeePerGas:         u256OrZero(tx.maxFeePerGas),
+		MaxPriorityFeePerGas: u256OrZero(tx.maxPriorityFeePerGas),
+		V:                    bigIntOrZero(tx.v),
+		R:                    bigIntOrZero(tx.r),
+		S:                    bigIntOrZero(tx.s),
+		Signed:               tx.signed,
+	}
+}
+
+func (tx *Tx) unmarshalled(marshalled marshalledTx) error {
+	switch marshalled.Type {
+	case LegacyTxType, AccessListTxType, DynamicFeeTxType:
+	default:
+		return fmt.Errorf("bad tx type: %v", marshalled.Type)
+	}
+	var to *Address
+	if marshalled.To != "" {
+		addr, err := NewAddressFromHex(string(marshalled.To))
+		if err != nil {
+			return fmt.Errorf("bad to address: %v", err)
+		}
+		to = &addr
+	}
+	var payload pack.Bytes
+	if len(marshalled.Payload) > 0 {
+		payload = marshalled.Payload
+	}
+	var accessList AccessList
+	if len(marshalled.AccessList) > 0 {
+		accessList = marshalled.AccessList
+	}
+
+	*tx = Tx{
+		txType:               marshalled.Type,
+		chainID:              marshalled.ChainID,
+		from:                 marshalled.From,
+		to:                   to,
+		value:                marshalled.Value,
+		nonce:                marshalled.Nonce,
+		gasLimit:             marshalled.GasLimit,
+		payload:              payload,
+		accessList:           accessList,
+		gasPrice:             marshalled.GasPrice,
+		maxFeePerGas:         marshalled.MaxFeePerGas,
+		maxPriorityFeePerGas: marshalled.MaxPriorityFeePerGas,
+		signed:               marshalled.Signed,
+	}
+	if marshalled.Signed {
+		tx.v = marshalled.V.Int()
+		tx.r = marshalled.R.Int()
+		tx.s = marshalled.S.Int()
+	}
+	return nil
+}
+
+// u256OrZero returns the integer, or zero if the integer has not been
+// initialised.
+func u256OrZero(x pack.U256) pack.U256 {
+	if x == (pack.U256{}) {
+		return pack.NewU256FromU64(pack.NewU64(0))
+	}
+	return x
+}
+
+// bigIntOrZero returns the integer as a U256, or zero if the integer is nil.
+func bigIntOrZero(x *big.Int) pack.U256 {
+	if x == nil {
+		return pack.NewU256FromU64(pack.NewU64(0))
+	}
+	return pack.NewU256FromInt(x)
+}
@@ -0,0 +1,108 @@
+package ethereum_test
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/chain/ethereum"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Marshalling", func() {
+	chainID := pack.NewU256FromU64(pack.NewU64(1337))
+	gasLimit := pack.NewU256FromU64(pack.NewU64(21000))
+	gasPrice := pack.NewU256FromU64(pack.NewU64(20000000000))
+	privKey := id.NewPrivKey()
+	from := address.Address(crypto.PubkeyToAddress(privKey.PublicKey).Hex())
+	accessList := ethereum.AccessList{{
+		Address:     ethereum.Address{1, 2, 3},
+		StorageKeys: []pack.Bytes32{{4, 5, 6}},
+	}}
+
+	txBuilders := map[string]ethereum.TxBuilder{
+		"legacy":      ethereum.NewTxBuilder(chainID, gasLimit, gasPrice),
+		"access list": ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).WithAccessList(accessList),
+		"dynamic fee": ethereum.NewTxBuilder(chainID, gasLimit, gasPrice).WithDynamicFee(gasPrice, pack.NewU256FromU64(pack.NewU64(1000000000))),
+	}
+
+	expectEqual := func(tx, other *ethereum.Tx) {
+		Expect(other.Type()).To(Equal(tx.Type()))
+		Expect(other.From()).To(Equal(tx.From()))
+		Expect(other.To()).To(Equal(tx.To()))
+		Expect(other.Value()).To(Equal(tx.Value()))
+		Expect(other.Nonce()).To(Equal(tx.Nonce()))
+		Expect(other.Payload()).To(Equal(tx.Payload()))
+		sighashes, err := tx.Sighashes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Sighashes()).To(Equal(sighashes))
+		serial, err := tx.Serialize()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Serialize()).To(Equal(serial))
+	}
+
+	for name, txBuilder := range txBuilders {
+		name, txBuilder := name, txBuilder
+		Context("when marshalling "+name+" transactions", func() {
+			for _, to := range []address.Address{"0x0102030405060708091011121314151617181920", ""} {
+				to := to
+				It("should unmarshal the same unsigned and signed transactions", func() {
+					tx, err := txBuilder.BuildTx(from, to, pack.NewU256FromU64(pack.NewU64(1000)), pack.NewU256FromU64(pack.NewU64(7)), pack.Bytes{0xde, 0xad})
+					Expect(err).ToNot(HaveOccurred())
+
+					for _, sign := range []bool{false, true} {
+						if sign {
+							sighashes, err := tx.Sighashes()
+							Expect(err).ToNot(HaveOccurred())
+							hash := id.Hash(sighashes[0])
+							signature, err := privKey.Sign(&hash)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).To(Succeed())
+						}
+
+						data, err := surge.ToBinary(tx)
+						Expect(err).ToNot(HaveOccurred())
+						fromBinary := new(ethereum.Tx)
+						Expect(surge.FromBinary(fromBinary, data)).To(Succeed())
+						expectEqual(tx.(*ethereum.Tx), fromBinary)
+
+						data, err = json.Marshal(tx)
+						Expect(err).ToNot(HaveOccurred())
+						fromJSON := new(ethereum.Tx)
+						Expect(json.Unmarshal(data, fromJSON)).To(Succeed())
+						expectEqual(tx.(*ethereum.Tx), fromJSON)
+
+						// Unsigned transactions can still be signed after
+						// unmarshalling, and signed transactions cannot.
+						sighashes, err := fromJSON.Sighashes()
+						Expect(err).ToNot(HaveOccurred())
+						hash := id.Hash(sighashes[0])
+						signature, err := privKey.Sign(&hash)
+						Expect(err).ToNot(HaveOccurred())
+						if sign {
+							Expect(fromJSON.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).ToNot(Succeed())
+						} else {
+							Expect(fromJSON.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, nil)).To(Succeed())
+						}
+					}
+				})
+			}
+		})
+	}
+
+	Context("when unmarshalling malformed data", func() {
+		It("should return an error", func() {
+			tx, err := txBuilders["legacy"].BuildTx(from, "", pack.NewU256FromU64(pack.NewU64(1000)), pack.NewU256FromU64(pack.NewU64(7)), nil)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := surge.ToBinary(tx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(surge.FromBinary(new(ethereum.Tx), data[:len(data)-1])).ToNot(Succeed())
+			Expect(json.Unmarshal([]byte(`{"type":3}`), new(ethereum.Tx))).ToNot(Succeed())
+		})
+	})
+})
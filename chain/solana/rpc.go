@@ -2,6 +2,7 @@ package solana
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -40,6 +41,12 @@ type Error struct {
 
 // SendData sends data to method via jsonrpc
 func SendData(method string, data []byte, url string) (Response, error) {
+	return SendDataContext(context.Background(), method, data, url)
+}
+
+// SendDataContext is the same as SendData but the request is cancelled when the
+// context is done
+func SendDataContext(ctx context.Context, method string, data []byte, url string) (Response, error) {
 	request := Request{
 		Version: "2.0",
 		ID:      1,
@@ -47,25 +54,21 @@ func SendData(method string, data []byte, url string) (Response, error) {
 		Params:  data,
 	}
 	// Send request to lightnode
-	response, err := SendRequest(request, url)
+	response, err := SendRequestContext(ctx, request, url)
 	if err != nil {
 		return Response{}, err
 	}
-
-	var resp Response
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(response.Body)
-	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
-		return Response{}, fmt.Errorf("cannot decode %v response body = %s, err = %v", method, buf.String(), err)
-	}
-	if resp.Error != nil {
-		return Response{}, fmt.Errorf("got err back from %v request, err = %v", method, resp.Error)
-	}
-	return resp, nil
+	return decodeResponse(method, response)
 }
 
 // SendDataWithRetry is the same as SendData but will retry if sending the request failed
 func SendDataWithRetry(method string, data []byte, url string) (Response, error) {
+	return SendDataWithRetryContext(context.Background(), method, data, url)
+}
+
+// SendDataWithRetryContext is the same as SendDataWithRetry but the request,
+// and any retries, are cancelled when the context is done
+func SendDataWithRetryContext(ctx context.Context, method string, data []byte, url string) (Response, error) {
 	request := Request{
 		Version: "2.0",
 		ID:      1,
@@ -73,10 +76,17 @@ func SendDataWithRetry(method string, data []byte, url string) (Response, error)
 		Params:  data,
 	}
 	// Send request to lightnode with retry (max 10 times)
-	response, err := SendRequestWithRetry(request, url, 10, 10)
+	response, err := SendRequestWithRetryContext(ctx, request, url, 10, 10)
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to send request, err = %v", err)
 	}
+	return decodeResponse(method, response)
+}
+
+// decodeResponse decodes the JSON-RPC 2.0 response to a request for the method,
+// and returns an error if the response contains an error.
+func decodeResponse(method string, response *http.Response) (Response, error) {
+	defer response.Body.Close()
 
 	var resp Response
 	buf := new(bytes.Buffer)
@@ -90,13 +100,76 @@ func SendDataWithRetry(method string, data []byte, url string) (Response, error)
 	return resp, nil
 }
 
+// SendBatch sends the requests to the url as a JSON-RPC 2.0 batch, and returns
+// the response to each request, in the same order as the requests. Responses
+// are correlated with requests by their ID, so the IDs of the requests are
+// replaced by their index in the batch while they are sent. Requests that do
+// not get a response get a response with an error.
+func SendBatch(requests []Request, url string) ([]Response, error) {
+	return SendBatchContext(context.Background(), requests, url)
+}
+
+// SendBatchContext is the same as SendBatch but the request is cancelled when
+// the context is done.
+func SendBatchContext(ctx context.Context, requests []Request, url string) ([]Response, error) {
+	if len(requests) == 0 {
+		return []Response{}, nil
+	}
+	batch := make([]Request, len(requests))
+	for i := range requests {
+		batch[i] = requests[i]
+		batch[i].ID = i
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	response, err := SendRawPostContext(ctx, data, url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var resps []Response
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(response.Body)
+	if err := json.Unmarshal(buf.Bytes(), &resps); err != nil {
+		return nil, fmt.Errorf("cannot decode batch response body = %s, err = %v", buf.String(), err)
+	}
+
+	responses := make([]Response, len(requests))
+	for i := range responses {
+		responses[i] = Response{
+			Version: "2.0",
+			ID:      requests[i].ID,
+			Error:   &Error{Code: -32603, Message: "missing response"},
+		}
+	}
+	for _, resp := range resps {
+		// IDs are decoded as float64 values.
+		id, ok := resp.ID.(float64)
+		if !ok || id < 0 || int(id) >= len(requests) || float64(int(id)) != id {
+			continue
+		}
+		resp.ID = requests[int(id)].ID
+		responses[int(id)] = resp
+	}
+	return responses, nil
+}
+
 // SendRequest sends the JSON-2.0 request to the target url and returns the response and any error.
 func SendRequest(request Request, url string) (*http.Response, error) {
+	return SendRequestContext(context.Background(), request, url)
+}
+
+// SendRequestContext is the same as SendRequest but the request is cancelled
+// when the context is done
+func SendRequestContext(ctx context.Context, request Request, url string) (*http.Response, error) {
 	data, err := json.Marshal(request)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := SendRawPost(data, url)
+	resp, err := SendRawPostContext(ctx, data, url)
 	if err != nil {
 		fmt.Printf("Sending %s to %s resulted in an error: %v\n", string(data), url, err)
 		return nil, err
@@ -106,31 +179,47 @@ func SendRequest(request Request, url string) (*http.Response, error) {
 
 // SendRawPost sends a raw bytes as a POST request to the URL specified
 func SendRawPost(data []byte, url string) (*http.Response, error) {
+	return SendRawPostContext(context.Background(), data, url)
+}
+
+// SendRawPostContext is the same as SendRawPost but the request is cancelled
+// when the context is done
+func SendRawPostContext(ctx context.Context, data []byte, url string) (*http.Response, error) {
 	if !strings.HasPrefix(url, "http") {
 		url = "http://" + url
 	}
 	client := newClient(10 * time.Second)
 	buff := bytes.NewBuffer(data)
-	req, err := http.NewRequest("POST", url, buff)
-	req.Header.Set("Content-Type", "application/json")
+	req, err := http.NewRequestWithContext(ctx, "POST", url, buff)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 	return client.Do(req)
 }
 
 // SendRequestWithRetry calls SendRequest but with configurable retry logic
 func SendRequestWithRetry(request Request, url string, timeoutInSecs int, retries int) (response *http.Response, err error) {
+	return SendRequestWithRetryContext(context.Background(), request, url, timeoutInSecs, retries)
+}
+
+// SendRequestWithRetryContext is the same as SendRequestWithRetry but stops
+// retrying when the context is done
+func SendRequestWithRetryContext(ctx context.Context, request Request, url string, timeoutInSecs int, retries int) (response *http.Response, err error) {
 	failures := 0
 	for failures < retries {
-		response, err = SendRequest(request, url)
+		response, err = SendRequestContext(ctx, request, url)
 		if err != nil {
 			failures++
 			if failures >= retries {
 				return nil, err
 			}
 			fmt.Printf("%s errored: %v. Retrying after %d seconds\n", url, err, timeoutInSecs)
-			time.Sleep(time.Duration(timeoutInSecs) * time.Second)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(timeoutInSecs) * time.Second):
+			}
 			continue
 		}
 		break
@@ -1,9 +1,12 @@
 package solana
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/renproject/multichain/api/address"
@@ -11,11 +14,25 @@ import (
 	"go.uber.org/zap"
 )
 
-const DefaultClientRPCURL = "http://localhost:8899"
+const (
+	DefaultClientRPCURL = "http://localhost:8899"
+	DefaultClientQuorum = 1
+
+	DefaultClientHealthCheckInterval = 30 * time.Second
+)
 
 type ClientOptions struct {
 	Logger *zap.Logger
 	RPCURL string
+	// FallbackRPCURLs are used, healthy endpoints first, when requests to the
+	// RPC URL fail.
+	FallbackRPCURLs []string
+	// Quorum is the number of endpoints that must return the same account
+	// data before it is returned by CallContract. A quorum of one disables
+	// quorum reads.
+	Quorum int
+	// HealthCheckInterval is the duration between health checks made by Run.
+	HealthCheckInterval time.Duration
 }
 
 func DefaultClientOptions() ClientOptions {
@@ -26,15 +43,100 @@ func DefaultClientOptions() ClientOptions {
 	return ClientOptions{
 		Logger: logger,
 		RPCURL: DefaultClientRPCURL,
+		Quorum: DefaultClientQuorum,
+
+		HealthCheckInterval: DefaultClientHealthCheckInterval,
 	}
 }
 
+// WithFallbackRPCURLs sets the endpoints that are used when requests to the RPC
+// URL fail.
+func (opts ClientOptions) WithFallbackRPCURLs(urls ...string) ClientOptions {
+	opts.FallbackRPCURLs = urls
+	return opts
+}
+
+// WithQuorum sets the number of endpoints that must agree on the result of
+// CallContract.
+func (opts ClientOptions) WithQuorum(quorum int) ClientOptions {
+	opts.Quorum = quorum
+	return opts
+}
+
+// WithHealthCheckInterval sets the duration between health checks made by Run.
+func (opts ClientOptions) WithHealthCheckInterval(interval time.Duration) ClientOptions {
+	opts.HealthCheckInterval = interval
+	return opts
+}
+
 type Client struct {
 	opts ClientOptions
+
+	unhealthyMu *sync.RWMutex
+	unhealthy   map[string]bool
 }
 
-func NewClient(opts ClientOptions) *Client {
-	return &Client{opts: opts}
+// NewClient returns a Client that sends requests to the RPC URL, and to the
+// fallback RPC URLs when they are configured. All endpoints are assumed to be
+// healthy until a request to them fails. An error is returned if the quorum is
+// not between one and the number of endpoints.
+func NewClient(opts ClientOptions) (*Client, error) {
+	if opts.Quorum < 1 || opts.Quorum > 1+len(opts.FallbackRPCURLs) {
+		return nil, fmt.Errorf("bad quorum: expected 1 <= quorum <= %v, got quorum = %v", 1+len(opts.FallbackRPCURLs), opts.Quorum)
+	}
+	return &Client{
+		opts: opts,
+
+		unhealthyMu: new(sync.RWMutex),
+		unhealthy:   map[string]bool{},
+	}, nil
+}
+
+// Run health checks every health check interval, until the context is done.
+// Without it, endpoints that become unhealthy are only used again after all
+// healthy endpoints have failed.
+func (client *Client) Run(ctx context.Context) {
+	interval := client.opts.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultClientHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		client.HealthCheck(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthCheck calls "getHealth" on every endpoint, and returns the number of
+// endpoints that are healthy. Endpoints that are unhealthy are only used after
+// all healthy endpoints have failed.
+func (client *Client) HealthCheck(ctx context.Context) int {
+	urls := client.endpoints()
+	errs := make([]error, len(urls))
+	wg := new(sync.WaitGroup)
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = SendDataContext(ctx, "getHealth", nil, urls[i])
+			client.setHealthy(urls[i], errs[i] == nil)
+		}(i)
+	}
+	wg.Wait()
+
+	healthy := 0
+	for _, err := range errs {
+		if err == nil {
+			healthy++
+		}
+	}
+	return healthy
 }
 
 func (client *Client) CallContract(ctx context.Context, contract address.Address, input pack.Bytes) (output pack.Bytes, err error) {
@@ -48,7 +150,56 @@ func (client *Client) CallContract(ctx context.Context, contract address.Address
 	if err != nil {
 		return pack.Bytes(nil), fmt.Errorf("encoding params: %v", err)
 	}
-	res, err := SendDataWithRetry("getAccountInfo", params, client.opts.RPCURL)
+	urls := client.endpoints()
+	if client.opts.Quorum <= 1 {
+		// Fail over to the next endpoint until one of them succeeds.
+		for _, url := range urls {
+			if output, err = client.accountData(ctx, params, url); err == nil {
+				return output, nil
+			}
+		}
+		return pack.Bytes(nil), err
+	}
+
+	// Call every endpoint, and return the data that a quorum of them agree on.
+	outputs := make([]pack.Bytes, len(urls))
+	errs := make([]error, len(urls))
+	wg := new(sync.WaitGroup)
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = client.accountData(ctx, params, urls[i])
+		}(i)
+	}
+	wg.Wait()
+	for i := range outputs {
+		if errs[i] != nil {
+			continue
+		}
+		agreed := 0
+		for j := range outputs {
+			if errs[j] == nil && bytes.Equal(outputs[i], outputs[j]) {
+				agreed++
+			}
+		}
+		if agreed >= client.opts.Quorum {
+			return outputs[i], nil
+		}
+	}
+	return pack.Bytes(nil), fmt.Errorf("bad quorum: expected %v of %v endpoints to agree, got errors %v", client.opts.Quorum, len(urls), errs)
+}
+
+// accountData returns the data associated with the account, as returned by
+// the given endpoint. Requests are only retried if there are no other
+// endpoints to fail over to.
+func (client *Client) accountData(ctx context.Context, params []byte, url string) (pack.Bytes, error) {
+	send := SendDataContext
+	if len(client.opts.FallbackRPCURLs) == 0 {
+		send = SendDataWithRetryContext
+	}
+	res, err := send(ctx, "getAccountInfo", params, url)
+	client.setHealthy(url, err == nil)
 	if err != nil {
 		return pack.Bytes(nil), fmt.Errorf("calling rpc method \"getAccountInfo\": %v", err)
 	}
@@ -70,3 +221,33 @@ func (client *Client) CallContract(ctx context.Context, contract address.Address
 	}
 	return pack.NewBytes(data), nil
 }
+
+// endpoints returns the RPC URL and the fallback RPC URLs, with healthy
+// endpoints first.
+func (client *Client) endpoints() []string {
+	client.unhealthyMu.RLock()
+	defer client.unhealthyMu.RUnlock()
+
+	urls := append([]string{client.opts.RPCURL}, client.opts.FallbackRPCURLs...)
+	healthy := make([]string, 0, len(urls))
+	unhealthy := []string{}
+	for _, url := range urls {
+		if client.unhealthy[url] {
+			unhealthy = append(unhealthy, url)
+		} else {
+			healthy = append(healthy, url)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (client *Client) setHealthy(url string, healthy bool) {
+	client.unhealthyMu.Lock()
+	defer client.unhealthyMu.Unlock()
+
+	if healthy {
+		delete(client.unhealthy, url)
+	} else {
+		client.unhealthy[url] = true
+	}
+}
@@ -2,7 +2,12 @@ package solana_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/renproject/multichain/api/address"
 	"github.com/renproject/multichain/chain/solana"
 	"github.com/renproject/pack"
@@ -14,8 +19,9 @@ import (
 var _ = Describe("Solana", func() {
 	Context("...", func() {
 		It("...", func() {
-			client := solana.NewClient(solana.DefaultClientOptions())
-			_, err := client.CallContract(
+			client, err := solana.NewClient(solana.DefaultClientOptions())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.CallContract(
 				context.Background(),
 				address.Address(pack.NewString("JBUjNGPApBQ3gw6w2UQPYr1978rkFEGqH1Zs3PZBrHec")),
 				pack.NewBytes([]byte{}),
@@ -25,3 +31,199 @@ var _ = Describe("Solana", func() {
 		})
 	})
 })
+
+// newAccountServer returns a server that responds to "getAccountInfo" with the
+// given account data, and to "getHealth" with "ok".
+func newAccountServer(data []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := solana.Request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := json.RawMessage(`"ok"`)
+		if req.Method == "getAccountInfo" {
+			info, err := json.Marshal(solana.ResponseGetAccountInfo{Value: solana.AccountValue{Data: base58.Encode(data)}})
+			if err != nil {
+				panic(err)
+			}
+			result = json.RawMessage(info)
+		}
+		json.NewEncoder(w).Encode(solana.Response{Version: "2.0", ID: req.ID, Result: &result})
+	}))
+}
+
+var _ = Describe("Client", func() {
+	contract := address.Address(pack.NewString("JBUjNGPApBQ3gw6w2UQPYr1978rkFEGqH1Zs3PZBrHec"))
+
+	// deadURL returns the URL of a server that has been closed.
+	deadURL := func() string {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		return server.URL
+	}
+
+	Context("when the RPC URL is not available", func() {
+		It("should fail over to the fallback RPC URLs", func() {
+			server := newAccountServer([]byte{1, 2, 3})
+			defer server.Close()
+
+			dead := deadURL()
+			opts := solana.DefaultClientOptions()
+			opts.RPCURL = dead
+			client, err := solana.NewClient(opts.WithFallbackRPCURLs(server.URL))
+			Expect(err).ToNot(HaveOccurred())
+			output, err := client.CallContract(context.Background(), contract, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(pack.NewBytes([]byte{1, 2, 3})))
+
+			Expect(client.HealthCheck(context.Background())).To(Equal(1))
+		})
+	})
+
+	Context("when the context is done", func() {
+		It("should cancel requests", func() {
+			done := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer server.Close()
+			defer close(done)
+
+			opts := solana.DefaultClientOptions()
+			opts.RPCURL = server.URL
+			client, err := solana.NewClient(opts)
+			Expect(err).ToNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err = client.CallContract(ctx, contract, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(client.HealthCheck(ctx)).To(Equal(0))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+	})
+
+	Context("when the quorum is greater than one", func() {
+		It("should return the account data that a quorum of endpoints agree on", func() {
+			servers := []*httptest.Server{
+				newAccountServer([]byte{1, 2, 3}),
+				newAccountServer([]byte{4, 5, 6}),
+				newAccountServer([]byte{1, 2, 3}),
+			}
+			for _, server := range servers {
+				defer server.Close()
+			}
+
+			opts := solana.DefaultClientOptions()
+			opts.RPCURL = servers[0].URL
+			opts = opts.WithFallbackRPCURLs(servers[1].URL, servers[2].URL)
+			client, err := solana.NewClient(opts.WithQuorum(2))
+			Expect(err).ToNot(HaveOccurred())
+			output, err := client.CallContract(context.Background(), contract, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(pack.NewBytes([]byte{1, 2, 3})))
+
+			client, err = solana.NewClient(opts.WithQuorum(3))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.CallContract(context.Background(), contract, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the quorum is invalid", func() {
+		It("should return an error", func() {
+			opts := solana.DefaultClientOptions().WithFallbackRPCURLs(deadURL())
+			_, err := solana.NewClient(opts.WithQuorum(0))
+			Expect(err).To(HaveOccurred())
+			_, err = solana.NewClient(opts.WithQuorum(3))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when running", func() {
+		It("should check the health of the endpoints until the context is done", func() {
+			server := newAccountServer([]byte{1, 2, 3})
+			defer server.Close()
+
+			opts := solana.DefaultClientOptions()
+			opts.RPCURL = deadURL()
+			client, err := solana.NewClient(opts.WithFallbackRPCURLs(server.URL).WithHealthCheckInterval(10 * time.Millisecond))
+			Expect(err).ToNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				client.Run(ctx)
+			}()
+			Eventually(done, time.Second).Should(BeClosed())
+		})
+	})
+})
+
+var _ = Describe("SendBatch", func() {
+	It("should return the response to each request in order", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqs := []solana.Request{}
+			if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// Respond in the reverse order, with an error for the second
+			// request, and no response for the last request.
+			resps := []solana.Response{}
+			for i := len(reqs) - 2; i >= 0; i-- {
+				if i == 1 {
+					resps = append(resps, solana.Response{Version: "2.0", ID: reqs[i].ID, Error: &solana.Error{Code: -32601, Message: "Method not found"}})
+					continue
+				}
+				result := json.RawMessage(`"` + reqs[i].Method + `"`)
+				resps = append(resps, solana.Response{Version: "2.0", ID: reqs[i].ID, Result: &result})
+			}
+			json.NewEncoder(w).Encode(resps)
+		}))
+		defer server.Close()
+
+		reqs := []solana.Request{
+			{Version: "2.0", ID: "a", Method: "getHealth"},
+			{Version: "2.0", ID: "b", Method: "getFoo"},
+			{Version: "2.0", ID: "c", Method: "getSlot"},
+			{Version: "2.0", ID: "d", Method: "getBar"},
+		}
+		resps, err := solana.SendBatch(reqs, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resps).To(HaveLen(4))
+		for i, resp := range resps {
+			Expect(resp.ID).To(Equal(reqs[i].ID))
+		}
+		Expect(string(*resps[0].Result)).To(Equal(`"getHealth"`))
+		Expect(resps[1].Error.Code).To(Equal(-32601))
+		Expect(string(*resps[2].Result)).To(Equal(`"getSlot"`))
+		Expect(resps[3].Error).ToNot(BeNil())
+	})
+
+	It("should cancel the request when the context is done", func() {
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}))
+		defer server.Close()
+		defer close(done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := solana.SendBatchContext(ctx, []solana.Request{{Version: "2.0", ID: "a", Method: "getHealth"}}, server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+})
@@ -0,0 +1,169 @@
+package zcash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+)
+
+// DecodeTx returns the Zcash transaction encoded by the given bytes, in the
+// format produced by Serialize. Only transparent Overwinter and Sapling
+// transactions are supported, so transactions with JoinSplits, shielded spends,
+// shielded outputs, or a non-zero value balance cannot be decoded. Serialized
+// transactions do not include the values, or pubkey scripts, of the outputs
+// that they spend, so these must be given as inputs (in the same order as the
+// transaction) for Sighashes to work. If no inputs are given, they are
+// recovered from the outpoints of the transaction with a value of zero, and
+// Sighashes will return an error.
+func DecodeTx(params *Params, data pack.Bytes, inputs []utxo.Input) (*Tx, error) {
+	r := bytes.NewReader(data)
+	msgTx, expiryHeight, err := readTx(r)
+	if err != nil {
+		return nil, fmt.Errorf("bad tx: %v", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("bad tx: %v trailing bytes", r.Len())
+	}
+
+	inputs, err = bitcoin.InputsFromMsgTx(msgTx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := bitcoin.RecipientsFromMsgTx(msgTx, func(addr btcutil.Address) (address.Address, error) {
+		switch addr := addr.(type) {
+		case *btcutil.AddressPubKeyHash:
+			zecAddr, err := NewAddressPubKeyHash(addr.ScriptAddress(), params)
+			if err != nil {
+				return address.Address(""), err
+			}
+			return address.Address(zecAddr.EncodeAddress()), nil
+		case *btcutil.AddressScriptHash:
+			zecAddr, err := NewAddressScriptHashFromHash(addr.ScriptAddress(), params)
+			if err != nil {
+				return address.Address(""), err
+			}
+			return address.Address(zecAddr.EncodeAddress()), nil
+		default:
+			return address.Address(""), fmt.Errorf("unsupported address type %T", addr)
+		}
+	}, params.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, params: params, expiryHeight: expiryHeight, signed: bitcoin.IsSigned(msgTx)}, nil
+}
+
+// readTx reads a transparent Overwinter or Sapling transaction, and returns it
+// along with its expiry height.
+func readTx(r io.Reader) (*wire.MsgTx, uint32, error) {
+	pver := uint32(0)
+
+	// Header and version group ID.
+	var header uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, 0, err
+	}
+	if header&(1<<31) == 0 {
+		return nil, 0, fmt.Errorf("expected overwintered transaction")
+	}
+	version := int32(header &^ (1 << 31))
+	var versionGroupID uint32
+	if err := binary.Read(r, binary.LittleEndian, &versionGroupID); err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case version == versionOverwinter && versionGroupID == versionOverwinterGroupID:
+	case version == versionSapling && versionGroupID == versionSaplingGroupID:
+	default:
+		return nil, 0, fmt.Errorf("unsupported version %v with version group id %x", version, versionGroupID)
+	}
+	msgTx := wire.NewMsgTx(version)
+
+	// Inputs.
+	count, err := wire.ReadVarInt(r, pver)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := uint64(0); i < count; i++ {
+		txIn := new(wire.TxIn)
+		if _, err := io.ReadFull(r, txIn.PreviousOutPoint.Hash[:]); err != nil {
+			return nil, 0, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &txIn.PreviousOutPoint.Index); err != nil {
+			return nil, 0, err
+		}
+		if txIn.SignatureScript, err = wire.ReadVarBytes(r, pver, txscript.MaxScriptSize, "sigScript"); err != nil {
+			return nil, 0, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &txIn.Sequence); err != nil {
+			return nil, 0, err
+		}
+		msgTx.AddTxIn(txIn)
+	}
+
+	// Outputs.
+	if count, err = wire.ReadVarInt(r, pver); err != nil {
+		return nil, 0, err
+	}
+	for i := uint64(0); i < count; i++ {
+		txOut := new(wire.TxOut)
+		if err := binary.Read(r, binary.LittleEndian, &txOut.Value); err != nil {
+			return nil, 0, err
+		}
+		if txOut.PkScript, err = wire.ReadVarBytes(r, pver, txscript.MaxScriptSize, "pkScript"); err != nil {
+			return nil, 0, err
+		}
+		msgTx.AddTxOut(txOut)
+	}
+
+	// Lock time and expiry height.
+	if err := binary.Read(r, binary.LittleEndian, &msgTx.LockTime); err != nil {
+		return nil, 0, err
+	}
+	var expiryHeight uint32
+	if err := binary.Read(r, binary.LittleEndian, &expiryHeight); err != nil {
+		return nil, 0, err
+	}
+
+	if version == versionSapling {
+		// valueBalance
+		var valueBalance int64
+		if err := binary.Read(r, binary.LittleEndian, &valueBalance); err != nil {
+			return nil, 0, err
+		}
+		if valueBalance != 0 {
+			return nil, 0, fmt.Errorf("expected value balance 0, got value balance %v", valueBalance)
+		}
+
+		// nShieldedSpend and nShieldedOutput
+		for _, field := range []string{"shielded spends", "shielded outputs"} {
+			n, err := wire.ReadVarInt(r, pver)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n != 0 {
+				return nil, 0, fmt.Errorf("expected 0 %v, got %v", field, n)
+			}
+		}
+	}
+
+	// nJoinSplit
+	n, err := wire.ReadVarInt(r, pver)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n != 0 {
+		return nil, 0, fmt.Errorf("expected 0 joinsplits, got %v", n)
+	}
+
+	return msgTx, expiryHeight, nil
+}
@@ -0,0 +1,110 @@
+package zcash_test
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/zcash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoding", func() {
+	params := &zcash.RegressionNetParams
+	txBuilder := zcash.NewTxBuilder(params, 1000)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := zcash.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr.BitcoinAddress())
+	if err != nil {
+		panic(err)
+	}
+	shAddr, err := zcash.NewAddressScriptHash(pkhScript, params)
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(shAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(50000))},
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(49000))},
+	}
+
+	Context("when decoding a signed transaction", func() {
+		It("should return the same transaction", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			signatures := make([]pack.Bytes65, len(sighashes))
+			for i := range sighashes {
+				hash := id.Hash(sighashes[i])
+				signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+				Expect(err).ToNot(HaveOccurred())
+				signatures[i] = pack.NewBytes65(signature)
+			}
+			Expect(tx.Sign(signatures, pubKey)).To(Succeed())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := zcash.DecodeTx(params, serial, inputs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Inputs()).To(Equal(inputs))
+			Expect(decoded.Serialize()).To(Equal(serial))
+			Expect(decoded.Sighashes()).To(Equal(sighashes))
+			txHash, err := tx.Hash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Hash()).To(Equal(txHash))
+			outputs, err := tx.Outputs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.Outputs()).To(Equal(outputs))
+			Expect(decoded.Sign(signatures, pubKey)).ToNot(Succeed())
+		})
+	})
+
+	Context("when decoding transactions with shielded components", func() {
+		It("should return an error", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			// The last byte is the number of JoinSplits.
+			serial[len(serial)-1] = 1
+			_, err = zcash.DecodeTx(params, serial, inputs)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when decoding without inputs", func() {
+		It("should not return sighashes", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := zcash.DecodeTx(params, serial, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = decoded.Sighashes()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
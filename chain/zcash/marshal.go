@@ -0,0 +1,137 @@
+package zcash
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+)
+
+// marshalledTx is the representation of a Tx that is used for binary and JSON
+// marshalling. The network is identified by the name of its parameters, so
+// only transactions for MainNetParams, TestNet3Params, and RegressionNetParams
+// can be marshalled.
+type marshalledTx struct {
+	Inputs       []utxo.Input       `json:"inputs"`
+	Recipients   []utxo.Recipient   `json:"recipients"`
+	MsgTx        pack.Bytes         `json:"msgTx"`
+	Network      string             `json:"network"`
+	ExpiryHeight uint32             `json:"expiryHeight"`
+	SigHashTypes []utxo.SigHashType `json:"sigHashTypes"`
+	Signed       bool               `json:"signed"`
+}
+
+// SizeHint returns the number of bytes required to represent the transaction
+// in binary.
+func (tx *Tx) SizeHint() int {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return 0
+	}
+	return surge.SizeHint(marshalled)
+}
+
+// Marshal the transaction to binary. Inputs, recipients, the network, the
+// expiry height, sighash types, and whether or not the transaction is signed,
+// are all preserved.
+func (tx *Tx) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return buf, rem, err
+	}
+	return surge.Marshal(marshalled, buf, rem)
+}
+
+// Unmarshal the transaction from binary.
+func (tx *Tx) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	marshalled := marshalledTx{}
+	buf, rem, err := surge.Unmarshal(&marshalled, buf, rem)
+	if err != nil {
+		return buf, rem, err
+	}
+	if err := tx.unmarshalled(marshalled); err != nil {
+		return buf, rem, err
+	}
+	return buf, rem, nil
+}
+
+// MarshalJSON implements the JSON marshaler interface.
+func (tx *Tx) MarshalJSON() ([]byte, error) {
+	marshalled, err := tx.marshalled()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(marshalled)
+}
+
+// UnmarshalJSON implements the JSON unmarshaler interface.
+func (tx *Tx) UnmarshalJSON(data []byte) error {
+	marshalled := marshalledTx{}
+	if err := json.Unmarshal(data, &marshalled); err != nil {
+		return err
+	}
+	return tx.unmarshalled(marshalled)
+}
+
+func (tx *Tx) marshalled() (marshalledTx, error) {
+	if tx.params == nil || paramsByName(tx.params.Name) != tx.params {
+		return marshalledTx{}, fmt.Errorf("bad params: unknown network")
+	}
+	msgTx, _, err := bitcoin.EncodeMsgTx(tx.msgTx)
+	if err != nil {
+		return marshalledTx{}, err
+	}
+	return marshalledTx{
+		Inputs:       tx.inputs,
+		Recipients:   tx.recipients,
+		MsgTx:        msgTx,
+		Network:      tx.params.Name,
+		ExpiryHeight: tx.expiryHeight,
+		SigHashTypes: tx.sigHashTypes,
+		Signed:       tx.signed,
+	}, nil
+}
+
+func (tx *Tx) unmarshalled(marshalled marshalledTx) error {
+	params := paramsByName(marshalled.Network)
+	if params == nil {
+		return fmt.Errorf("bad params: unknown network %v", marshalled.Network)
+	}
+	msgTx, err := bitcoin.DecodeMsgTx(marshalled.MsgTx, nil)
+	if err != nil {
+		return err
+	}
+	if len(marshalled.Inputs) != len(msgTx.TxIn) {
+		return fmt.Errorf("expected %v inputs, got %v inputs", len(msgTx.TxIn), len(marshalled.Inputs))
+	}
+	if len(marshalled.SigHashTypes) > 0 && len(marshalled.SigHashTypes) != len(msgTx.TxIn) {
+		return fmt.Errorf("expected %v sighash types, got %v sighash types", len(msgTx.TxIn), len(marshalled.SigHashTypes))
+	}
+	for i, sigHashType := range marshalled.SigHashTypes {
+		if err := bitcoin.CheckSigHashType(resolveSigHashType(sigHashType)); err != nil {
+			return fmt.Errorf("bad input %v: %v", i, err)
+		}
+	}
+	tx.inputs = bitcoin.NormaliseInputs(marshalled.Inputs)
+	tx.recipients = bitcoin.NormaliseRecipients(marshalled.Recipients)
+	tx.msgTx = msgTx
+	tx.params = params
+	tx.expiryHeight = marshalled.ExpiryHeight
+	tx.sigHashTypes = marshalled.SigHashTypes
+	tx.signed = marshalled.Signed
+	return nil
+}
+
+// paramsByName returns the network parameters with the given name, or nil if
+// there are no such parameters.
+func paramsByName(name string) *Params {
+	for _, params := range []*Params{&MainNetParams, &TestNet3Params, &RegressionNetParams} {
+		if params.Name == name {
+			return params
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package zcash_test
+
+import (
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/zcash"
+	"github.com/renproject/pack"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Marshalling", func() {
+	params := &zcash.RegressionNetParams
+	txBuilder := zcash.NewTxBuilder(params, 1000)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		panic(err)
+	}
+	pubKey := pack.NewBytes(privKey.PubKey().SerializeCompressed())
+	pkhAddr, err := zcash.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		panic(err)
+	}
+	pkhScript, err := txscript.PayToAddrScript(pkhAddr.BitcoinAddress())
+	if err != nil {
+		panic(err)
+	}
+
+	inputs := []utxo.Input{
+		{Output: utxo.Output{
+			Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+			Value:        pack.NewU256FromU64(pack.NewU64(100000)),
+			PubKeyScript: pack.NewBytes(pkhScript),
+		}},
+	}
+	recipients := []utxo.Recipient{
+		{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(99000))},
+	}
+
+	Context("when marshalling transactions for unknown networks", func() {
+		It("should return an error", func() {
+			unknownParams := zcash.RegressionNetParams
+			tx, err := zcash.NewTxBuilder(&unknownParams, 1000).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = surge.ToBinary(tx)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshalling transactions", func() {
+		It("should unmarshal the same unsigned and signed transactions", func() {
+			tx, err := txBuilder.BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, sign := range []bool{false, true} {
+				if sign {
+					hash := id.Hash(sighashes[0])
+					signature, err := (*id.PrivKey)(privKey.ToECDSA()).Sign(&hash)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, pubKey)).To(Succeed())
+				}
+				serial, err := tx.Serialize()
+				Expect(err).ToNot(HaveOccurred())
+				txHash, err := tx.Hash()
+				Expect(err).ToNot(HaveOccurred())
+
+				data, err := surge.ToBinary(tx)
+				Expect(err).ToNot(HaveOccurred())
+				fromBinary := new(zcash.Tx)
+				Expect(surge.FromBinary(fromBinary, data)).To(Succeed())
+
+				data, err = json.Marshal(tx)
+				Expect(err).ToNot(HaveOccurred())
+				fromJSON := new(zcash.Tx)
+				Expect(json.Unmarshal(data, fromJSON)).To(Succeed())
+
+				for _, unmarshalled := range []*zcash.Tx{fromBinary, fromJSON} {
+					Expect(unmarshalled.Inputs()).To(Equal(inputs))
+					Expect(unmarshalled.Sighashes()).To(Equal(sighashes))
+					Expect(unmarshalled.Serialize()).To(Equal(serial))
+					Expect(unmarshalled.Hash()).To(Equal(txHash))
+					if sign {
+						Expect(unmarshalled.Sign([]pack.Bytes65{{}}, pubKey)).ToNot(Succeed())
+					}
+				}
+			}
+		})
+	})
+})
@@ -6,13 +6,12 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/big"
 
-	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/codahale/blake2"
+	"github.com/renproject/multichain/api/gas"
 	"github.com/renproject/multichain/api/utxo"
 	"github.com/renproject/multichain/chain/bitcoin"
 	"github.com/renproject/pack"
@@ -21,6 +20,10 @@ import (
 // Version of Zcash transactions supported by the multichain.
 const Version int32 = 4
 
+// MaxNullDataSize is the maximum number of bytes of data that can be embedded
+// in a null-data output by standard Zcash transactions.
+const MaxNullDataSize = bitcoin.MaxNullDataSize
+
 type ClientOptions = bitcoin.ClientOptions
 
 func DefaultClientOptions() ClientOptions {
@@ -31,18 +34,136 @@ type Client = bitcoin.Client
 
 var NewClient = bitcoin.NewClient
 
+var ErrTxNotFound = bitcoin.ErrTxNotFound
+
+var ErrTxAlreadyInChain = bitcoin.ErrTxAlreadyInChain
+
+var ErrTxAlreadyInMempool = bitcoin.ErrTxAlreadyInMempool
+
+var ErrMissingInputs = bitcoin.ErrMissingInputs
+
+var ErrInsufficientFee = bitcoin.ErrInsufficientFee
+
+var ErrMempoolConflict = bitcoin.ErrMempoolConflict
+
+type RPCError = bitcoin.RPCError
+
+type OutputResult = bitcoin.OutputResult
+
+type ConfirmationsResult = bitcoin.ConfirmationsResult
+
+type RetryPolicy = bitcoin.RetryPolicy
+
+type BackoffPolicy = bitcoin.BackoffPolicy
+
+var DefaultBackoffPolicy = bitcoin.DefaultBackoffPolicy
+
+type MultiClient = bitcoin.MultiClient
+
+type MultiClientOptions = bitcoin.MultiClientOptions
+
+var NewMultiClient = bitcoin.NewMultiClient
+
+var DefaultMultiClientOptions = bitcoin.DefaultMultiClientOptions
+
+type CoinSelector = bitcoin.CoinSelector
+
+type CoinSelectorOptions = bitcoin.CoinSelectorOptions
+
+var NewCoinSelector = bitcoin.NewCoinSelector
+
+// DefaultCoinSelectorOptions returns CoinSelectorOptions with the default
+// settings. Zcash does not support segwit, so witness scripts are not
+// assumed when estimating the size of inputs.
+func DefaultCoinSelectorOptions() CoinSelectorOptions {
+	return bitcoin.DefaultCoinSelectorOptions().WithInputScript(InputScriptFromInput)
+}
+
+var SelectCoins = bitcoin.SelectCoins
+
+type InputScript = bitcoin.InputScript
+
+type ScriptType = bitcoin.ScriptType
+
+type InputScriptFunc = bitcoin.InputScriptFunc
+
+// InputScriptFromInput returns the InputScript that is assumed when spending
+// the given input. Segwit is not supported, so an error is returned if the
+// input needs a witness.
+var InputScriptFromInput = bitcoin.InputScriptFromInputWithoutWitness
+
+// EstimateSize returns an upper bound on the serialized size, and virtual
+// size, of the given unsigned transaction after it has been signed. Segwit is
+// not supported, so the serialized size and virtual size are always equal.
+var EstimateSize = bitcoin.EstimateSizeWithoutWitness
+
+type FeeError = bitcoin.FeeError
+
+type FeeGuardOptions = bitcoin.FeeGuardOptions
+
+type GuardedTxBuilder = bitcoin.GuardedTxBuilder
+
+type GuardedClient = bitcoin.GuardedClient
+
+var CheckFee = bitcoin.CheckFee
+
+// DefaultFeeGuardOptions returns FeeGuardOptions with the default settings.
+// Zcash does not support segwit, so witness scripts are not assumed when
+// estimating the size of inputs.
+func DefaultFeeGuardOptions() FeeGuardOptions {
+	return bitcoin.DefaultFeeGuardOptions().WithInputScript(InputScriptFromInput)
+}
+
+var NewGuardedTxBuilder = bitcoin.NewGuardedTxBuilder
+
+var NewGuardedClient = bitcoin.NewGuardedClient
+
+type CPFPBuilder = bitcoin.CPFPBuilder
+
+// NewCPFPBuilder returns a CPFPBuilder that loads parent transactions using the
+// given client, estimates SATs-per-byte using the given gas estimator, and
+// builds child transactions using the given transaction builder. Zcash does
+// not support segwit, so witness scripts are not assumed when estimating the
+// size of child transactions.
+func NewCPFPBuilder(client Client, txBuilder utxo.TxBuilder, gasEstimator gas.Estimator, dustThreshold pack.U256) CPFPBuilder {
+	return bitcoin.NewCPFPBuilder(client, txBuilder, gasEstimator, dustThreshold).WithInputScript(InputScriptFromInput)
+}
+
+var CheckLockTimeVerifyScript = bitcoin.CheckLockTimeVerifyScript
+
 type TxBuilder struct {
 	params       *Params
 	expiryHeight uint32
+	lockTime     uint32
+	sequences    []uint32
 }
 
 // NewTxBuilder returns an implementation the transaction builder interface from
 // the Bitcoin Compat API, and exposes the functionality to build simple Zcash
 // transactions.
-func NewTxBuilder(params *Params, expiryHeight uint32) utxo.TxBuilder {
+func NewTxBuilder(params *Params, expiryHeight uint32) TxBuilder {
 	return TxBuilder{params: params, expiryHeight: expiryHeight}
 }
 
+// WithLockTime returns a copy of the transaction builder that builds
+// transactions with the given lock time (nLockTime). Unless sequence numbers
+// are given, inputs use the sequence number MaxTxInSequenceNum - 1 so that the
+// lock time is enforced.
+func (txBuilder TxBuilder) WithLockTime(lockTime uint32) TxBuilder {
+	txBuilder.lockTime = lockTime
+	return txBuilder
+}
+
+// WithSequences returns a copy of the transaction builder that builds
+// transactions with the given sequence number (nSequence) for each input. The
+// number of sequence numbers must be the same as the number of inputs. Zcash
+// does not enforce relative lock times (see BIP68), so sequence numbers only
+// determine whether or not the lock time is enforced.
+func (txBuilder TxBuilder) WithSequences(sequences []uint32) TxBuilder {
+	txBuilder.sequences = sequences
+	return txBuilder
+}
+
 // BuildTx returns a simple Zcash transaction that consumes the funds from the
 // given outputs, and sends the to the given recipients. The difference in the
 // sum value of the inputs and the sum value of the recipients is paid as a fee
@@ -56,7 +177,9 @@ func NewTxBuilder(params *Params, expiryHeight uint32) utxo.TxBuilder {
 //  builder.AddData(serializedPubKey)
 //
 // Outputs produced for recipients will use P2PKH, or P2SH scripts as the pubkey
-// script, based on the format of the recipient address.
+// script, based on the format of the recipient address. Outputs produced for
+// recipients with data will use OP_RETURN scripts that embed at most
+// MaxNullDataSize bytes of data.
 func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipient) (utxo.Tx, error) {
 	msgTx := wire.NewMsgTx(Version)
 
@@ -67,14 +190,13 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 		index := input.Output.Outpoint.Index.Uint32()
 		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, index), nil, nil))
 	}
+	if err := bitcoin.SetTimelocks(msgTx, txBuilder.lockTime, txBuilder.sequences); err != nil {
+		return nil, err
+	}
 
 	// Outputs
 	for _, recipient := range recipients {
-		addr, err := DecodeAddress(string(recipient.To))
-		if err != nil {
-			return &Tx{}, err
-		}
-		script, err := txscript.PayToAddrScript(addr.BitcoinAddress())
+		script, err := recipientScript(recipient)
 		if err != nil {
 			return &Tx{}, err
 		}
@@ -87,6 +209,22 @@ func (txBuilder TxBuilder) BuildTx(inputs []utxo.Input, recipients []utxo.Recipi
 	return &Tx{inputs: inputs, recipients: recipients, msgTx: msgTx, params: txBuilder.params, expiryHeight: txBuilder.expiryHeight, signed: false}, nil
 }
 
+// recipientScript returns the pubkey script of the output produced for the
+// recipient. Recipients with data produce null-data outputs.
+func recipientScript(recipient utxo.Recipient) ([]byte, error) {
+	if len(recipient.Data) > 0 {
+		if recipient.To != "" {
+			return nil, fmt.Errorf("bad recipient: expected address or data, got both")
+		}
+		return bitcoin.NullDataScript(recipient.Data, MaxNullDataSize)
+	}
+	addr, err := DecodeAddress(string(recipient.To))
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr.BitcoinAddress())
+}
+
 // Tx represents a simple Zcash transaction that implements the Bitcoin Compat
 // API.
 type Tx struct {
@@ -97,6 +235,10 @@ type Tx struct {
 	params       *Params
 	expiryHeight uint32
 
+	// sigHashTypes are the sighash types of each input. If there are no
+	// sighash types, all inputs use SIGHASH_ALL.
+	sigHashTypes []utxo.SigHashType
+
 	signed bool
 }
 
@@ -124,6 +266,9 @@ func (tx *Tx) Outputs() ([]utxo.Output, error) {
 			Index: pack.NewU32(uint32(i)),
 		}
 		outputs[i].PubKeyScript = pack.Bytes(tx.msgTx.TxOut[i].PkScript)
+		if data, ok := bitcoin.NullData(tx.msgTx.TxOut[i].PkScript); ok {
+			outputs[i].Data = data
+		}
 		if tx.msgTx.TxOut[i].Value < 0 {
 			return nil, fmt.Errorf("bad output %v: value is less than zero", i)
 		}
@@ -141,13 +286,16 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 		if value < 0 {
 			return []pack.Bytes32{}, fmt.Errorf("expected value >= 0, got value = %v", value)
 		}
+		if len(pubKeyScript) == 0 && len(sigScript) == 0 {
+			return []pack.Bytes32{}, fmt.Errorf("bad input %v: missing pubkey script", i)
+		}
 
 		var hash []byte
 		var err error
 		if sigScript == nil {
-			hash, err = calculateSighash(tx.params, pubKeyScript, txscript.SigHashAll, tx.msgTx, i, value, tx.expiryHeight)
+			hash, err = calculateSighash(tx.params, pubKeyScript, tx.sigHashType(i), tx.msgTx, i, value, tx.expiryHeight)
 		} else {
-			hash, err = calculateSighash(tx.params, sigScript, txscript.SigHashAll, tx.msgTx, i, value, tx.expiryHeight)
+			hash, err = calculateSighash(tx.params, sigScript, tx.sigHashType(i), tx.msgTx, i, value, tx.expiryHeight)
 		}
 		if err != nil {
 			return []pack.Bytes32{}, err
@@ -161,24 +309,30 @@ func (tx *Tx) Sighashes() ([]pack.Bytes32, error) {
 }
 
 func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
+	return tx.SignInputs(bitcoin.SameKeySignatures(signatures, pubKey))
+}
+
+// SignInputs signs the transaction using one signature for each input. Each
+// input can be signed by a different key. The sighash type of each signature
+// must be the default sighash type, or the same as the sighash type of its
+// input (see SetSigHashType).
+func (tx *Tx) SignInputs(signatures []utxo.InputSignature) error {
 	if tx.signed {
 		return fmt.Errorf("already signed")
 	}
 	if len(signatures) != len(tx.msgTx.TxIn) {
 		return fmt.Errorf("expected %v signatures, got %v signatures", len(tx.msgTx.TxIn), len(signatures))
 	}
-
-	for i, rsv := range signatures {
-		r := new(big.Int).SetBytes(rsv[:32])
-		s := new(big.Int).SetBytes(rsv[32:64])
-		signature := btcec.Signature{
-			R: r,
-			S: s,
+	for i, signature := range signatures {
+		if signature.SigHashType != utxo.DefaultSigHashType && resolveSigHashType(signature.SigHashType) != tx.sigHashType(i) {
+			return fmt.Errorf("bad input %v: expected sighash type %v, got sighash type %v", i, tx.sigHashType(i), signature.SigHashType)
 		}
+	}
 
+	for i, signature := range signatures {
 		builder := txscript.NewScriptBuilder()
-		builder.AddData(append(signature.Serialize(), byte(txscript.SigHashAll)))
-		builder.AddData(pubKey)
+		builder.AddData(bitcoin.EncodeSignature(signature.Signature, tx.sigHashType(i)))
+		builder.AddData(signature.PubKey)
 		if tx.inputs[i].SigScript != nil {
 			builder.AddData(tx.inputs[i].SigScript)
 		}
@@ -192,6 +346,11 @@ func (tx *Tx) Sign(signatures []pack.Bytes65, pubKey pack.Bytes) error {
 	return nil
 }
 
+// IsSigned returns true if the transaction has been signed.
+func (tx *Tx) IsSigned() bool {
+	return tx.signed
+}
+
 func (tx *Tx) Serialize() (pack.Bytes, error) {
 	w := new(bytes.Buffer)
 	pver := uint32(0)
@@ -1 +1,55 @@
 package zcash_test
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/renproject/id"
+	"github.com/renproject/multichain/api/address"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/multichain/chain/bitcoin"
+	"github.com/renproject/multichain/chain/zcash"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Size", func() {
+	Context("when estimating the size of signed transactions", func() {
+		It("should include the overheads of Zcash transactions", func() {
+			privKey := id.NewPrivKey()
+			pubKey := (*btcec.PublicKey)(&privKey.PublicKey).SerializeCompressed()
+			pkhAddr, err := zcash.NewAddressPubKeyHash(btcutil.Hash160(pubKey), &zcash.RegressionNetParams)
+			Expect(err).ToNot(HaveOccurred())
+			script, err := txscript.PayToAddrScript(pkhAddr.BitcoinAddress())
+			Expect(err).ToNot(HaveOccurred())
+
+			inputs := []utxo.Input{{Output: utxo.Output{
+				Outpoint:     utxo.Outpoint{Hash: pack.NewBytes(make([]byte, 32)), Index: pack.NewU32(0)},
+				Value:        pack.NewU256FromU64(pack.NewU64(100000000)),
+				PubKeyScript: pack.NewBytes(script),
+			}}}
+			recipients := []utxo.Recipient{{To: address.Address(pkhAddr.EncodeAddress()), Value: pack.NewU256FromU64(pack.NewU64(90000000))}}
+			tx, err := zcash.NewTxBuilder(&zcash.RegressionNetParams, 1000000).BuildTx(inputs, recipients)
+			Expect(err).ToNot(HaveOccurred())
+			size, _, err := zcash.EstimateSize(tx, []zcash.InputScript{{Type: bitcoin.ScriptP2PKH}})
+			Expect(err).ToNot(HaveOccurred())
+
+			sighashes, err := tx.Sighashes()
+			Expect(err).ToNot(HaveOccurred())
+			hash := id.Hash(sighashes[0])
+			signature, err := privKey.Sign(&hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Sign([]pack.Bytes65{pack.NewBytes65(signature)}, pack.NewBytes(pubKey))).To(Succeed())
+			serial, err := tx.Serialize()
+			Expect(err).ToNot(HaveOccurred())
+
+			// The serialization starts with the overwintered version 4 header,
+			// and includes the expiry height and the empty Sapling fields.
+			Expect([]byte(serial[:4])).To(Equal([]byte{0x04, 0x00, 0x00, 0x80}))
+			Expect(size).To(BeNumerically(">=", len(serial)))
+			Expect(size).To(BeNumerically("<=", len(serial)+3))
+		})
+	})
+})
@@ -0,0 +1,228 @@
+// Package quantise implements a decorator for the Gas API that makes gas
+// prices safe to use in distributed networks that collectively build, sign,
+// and submit transactions. Independent nodes will rarely estimate exactly the
+// same gas price, so the decorator quantises gas prices into geometric
+// buckets, caches the gas price for each block height, and only changes
+// bucket when the underlying estimate moves beyond a hysteresis band. This
+// allows independent nodes to converge on identical gas prices.
+package quantise
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/renproject/multichain/api/gas"
+	"github.com/renproject/pack"
+)
+
+// precision of the floating-point arithmetic used to compute buckets. Buckets
+// are computed using arbitrary-precision arithmetic (rather than the math
+// package) so that all nodes compute identical buckets, regardless of their
+// architecture.
+const precision = 256
+
+const (
+	// DefaultStep used by the Estimator. Each bucket is 12.5% larger than the
+	// previous bucket.
+	DefaultStep = 1.125
+	// DefaultHysteresis used by the Estimator. The underlying estimate must
+	// move half-way into a neighbouring bucket before the bucket changes.
+	DefaultHysteresis = 0.5
+)
+
+var (
+	// DefaultMin used by the Estimator. This is the value of the smallest
+	// bucket.
+	DefaultMin = pack.NewU256FromU64(pack.NewU64(1))
+)
+
+// A HeightFetcher returns the latest block height of the underlying chain. It
+// is used to cache gas prices for each block height.
+type HeightFetcher interface {
+	LatestBlockHeight(context.Context) (pack.U64, error)
+}
+
+// Options are used to parameterise the behaviour of the Estimator.
+type Options struct {
+	// Step is the ratio between consecutive buckets, and must be greater than
+	// one.
+	Step float64
+	// Hysteresis is the fraction of a neighbouring bucket that the underlying
+	// estimate must move into before the bucket changes, and must be in the
+	// range [0, 1).
+	Hysteresis float64
+	// Min is the value of the smallest bucket, and must be greater than zero.
+	// Gas prices are never quantised to less than this value.
+	Min pack.U256
+}
+
+// DefaultOptions returns Options with the default settings.
+func DefaultOptions() Options {
+	return Options{
+		Step:       DefaultStep,
+		Hysteresis: DefaultHysteresis,
+		Min:        DefaultMin,
+	}
+}
+
+// WithStep sets the ratio between consecutive buckets.
+func (opts Options) WithStep(step float64) Options {
+	opts.Step = step
+	return opts
+}
+
+// WithHysteresis sets the fraction of a neighbouring bucket that the
+// underlying estimate must move into before the bucket changes.
+func (opts Options) WithHysteresis(hysteresis float64) Options {
+	opts.Hysteresis = hysteresis
+	return opts
+}
+
+// WithMin sets the value of the smallest bucket.
+func (opts Options) WithMin(min pack.U256) Options {
+	opts.Min = min
+	return opts
+}
+
+// An Estimator wraps another gas.Estimator and quantises its gas prices. The
+// bucket k has the value ceil(min * step^k), and gas prices are rounded up to
+// the nearest bucket so that they are never less than the underlying estimate
+// (except within the hysteresis band).
+type Estimator struct {
+	estimator gas.Estimator
+	heights   HeightFetcher
+	opts      Options
+
+	mu       *sync.Mutex
+	ok       bool
+	bucket   int
+	height   pack.U64
+	gasPrice pack.U256
+}
+
+// NewEstimator returns an Estimator that quantises the gas prices returned by
+// the given estimator. If the height fetcher is nil, then gas prices are not
+// cached, and the underlying estimator is called every time.
+func NewEstimator(estimator gas.Estimator, heights HeightFetcher, opts Options) *Estimator {
+	return &Estimator{
+		estimator: estimator,
+		heights:   heights,
+		opts:      opts,
+
+		mu: new(sync.Mutex),
+	}
+}
+
+// EstimateGasPrice returns the quantised gas price. If the block height has
+// not changed since the last call, then the cached gas price is returned.
+func (estimator *Estimator) EstimateGasPrice(ctx context.Context) (pack.U256, error) {
+	if estimator.opts.Step <= 1 {
+		return pack.U256{}, fmt.Errorf("bad step: expected > 1, got %v", estimator.opts.Step)
+	}
+	if estimator.opts.Hysteresis < 0 || estimator.opts.Hysteresis >= 1 {
+		return pack.U256{}, fmt.Errorf("bad hysteresis: expected >= 0 and < 1, got %v", estimator.opts.Hysteresis)
+	}
+	if estimator.opts.Min.Int().Sign() <= 0 {
+		return pack.U256{}, fmt.Errorf("bad min: expected > 0, got %v", estimator.opts.Min)
+	}
+
+	estimator.mu.Lock()
+	defer estimator.mu.Unlock()
+
+	var height pack.U64
+	if estimator.heights != nil {
+		var err error
+		height, err = estimator.heights.LatestBlockHeight(ctx)
+		if err != nil {
+			return pack.U256{}, fmt.Errorf("fetching height: %v", err)
+		}
+		if estimator.ok && height.Equal(estimator.height) {
+			return estimator.gasPrice, nil
+		}
+	}
+
+	gasPrice, err := estimator.estimator.EstimateGasPrice(ctx)
+	if err != nil {
+		return pack.U256{}, err
+	}
+	bucket := estimator.index(gasPrice.Int())
+	if estimator.ok && estimator.within(estimator.bucket, gasPrice.Int()) {
+		bucket = estimator.bucket
+	}
+
+	estimator.ok = true
+	estimator.bucket = bucket
+	estimator.height = height
+	estimator.gasPrice = pack.MaxU256
+	if value := estimator.value(bucket); value.Cmp(pack.MaxU256.Int()) < 0 {
+		estimator.gasPrice = pack.NewU256FromInt(value)
+	}
+	return estimator.gasPrice, nil
+}
+
+// index returns the smallest bucket with a value that is greater than, or
+// equal to, the gas price.
+func (estimator *Estimator) index(gasPrice *big.Int) int {
+	step := new(big.Float).SetPrec(precision).SetFloat64(estimator.opts.Step)
+	v := new(big.Float).SetPrec(precision).SetInt(estimator.opts.Min.Int())
+	k := 0
+	for ceil(v).Cmp(gasPrice) < 0 {
+		v.Mul(v, step)
+		k++
+	}
+	return k
+}
+
+// within returns true if the gas price is within the hysteresis band around
+// bucket k. The band extends from bucket k-1, minus a fraction of the width of
+// bucket k-1, up to bucket k, plus a fraction of the width of bucket k+1.
+func (estimator *Estimator) within(k int, gasPrice *big.Int) bool {
+	hysteresis := new(big.Float).SetPrec(precision).SetFloat64(estimator.opts.Hysteresis)
+	price := new(big.Float).SetPrec(precision).SetInt(gasPrice)
+
+	// Compute the upper bound of the band.
+	curr := new(big.Float).SetPrec(precision).SetInt(estimator.value(k))
+	next := new(big.Float).SetPrec(precision).SetInt(estimator.value(k + 1))
+	upper := new(big.Float).SetPrec(precision).Sub(next, curr)
+	upper.Mul(upper, hysteresis)
+	upper.Add(upper, curr)
+	if price.Cmp(upper) > 0 {
+		return false
+	}
+	if k == 0 {
+		return true
+	}
+
+	// Compute the lower bound of the band. The bucket before the first bucket
+	// is treated as zero.
+	prev := new(big.Float).SetPrec(precision).SetInt(estimator.value(k - 1))
+	prevprev := new(big.Float).SetPrec(precision)
+	if k > 1 {
+		prevprev.SetInt(estimator.value(k - 2))
+	}
+	lower := new(big.Float).SetPrec(precision).Sub(prev, prevprev)
+	lower.Mul(lower, hysteresis)
+	lower.Sub(prev, lower)
+	return price.Cmp(lower) > 0
+}
+
+// value returns the value of bucket k, which is ceil(min * step^k).
+func (estimator *Estimator) value(k int) *big.Int {
+	step := new(big.Float).SetPrec(precision).SetFloat64(estimator.opts.Step)
+	v := new(big.Float).SetPrec(precision).SetInt(estimator.opts.Min.Int())
+	for i := 0; i < k; i++ {
+		v.Mul(v, step)
+	}
+	return ceil(v)
+}
+
+// ceil returns the smallest integer that is greater than, or equal to, x.
+func ceil(x *big.Float) *big.Int {
+	z, acc := x.Int(nil)
+	if acc == big.Below {
+		z.Add(z, big.NewInt(1))
+	}
+	return z
+}
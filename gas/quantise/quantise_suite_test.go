@@ -0,0 +1,13 @@
+package quantise_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuantise(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Quantise Suite")
+}
@@ -0,0 +1,136 @@
+package quantise_test
+
+import (
+	"context"
+
+	"github.com/renproject/multichain/gas/quantise"
+	"github.com/renproject/pack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockEstimator struct {
+	gasPrice uint64
+	calls    int
+}
+
+func (estimator *mockEstimator) EstimateGasPrice(context.Context) (pack.U256, error) {
+	estimator.calls++
+	return pack.NewU256FromU64(pack.NewU64(estimator.gasPrice)), nil
+}
+
+type mockHeightFetcher struct {
+	height uint64
+}
+
+func (fetcher *mockHeightFetcher) LatestBlockHeight(context.Context) (pack.U64, error) {
+	return pack.NewU64(fetcher.height), nil
+}
+
+var _ = Describe("Quantise", func() {
+	// With a min of 1000 and a step of 1.125, the buckets are 1000, 1125,
+	// 1266, 1424, 1602, and so on.
+	opts := quantise.DefaultOptions().WithMin(pack.NewU256FromU64(pack.NewU64(1000)))
+
+	estimate := func(estimator *quantise.Estimator) uint64 {
+		gasPrice, err := estimator.EstimateGasPrice(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		return gasPrice.Int().Uint64()
+	}
+
+	Context("when quantising gas prices", func() {
+		It("should round up to the nearest bucket", func() {
+			for _, test := range []struct {
+				gasPrice, expected uint64
+			}{
+				{1, 1000},
+				{1000, 1000},
+				{1001, 1125},
+				{1125, 1125},
+				{1200, 1266},
+				{1424, 1424},
+				{1500, 1602},
+			} {
+				estimator := quantise.NewEstimator(&mockEstimator{gasPrice: test.gasPrice}, nil, opts)
+				Expect(estimate(estimator)).To(Equal(test.expected))
+			}
+		})
+
+		It("should return identical gas prices for nearby estimates", func() {
+			for gasPrice := uint64(1126); gasPrice <= 1266; gasPrice++ {
+				estimator := quantise.NewEstimator(&mockEstimator{gasPrice: gasPrice}, nil, opts)
+				Expect(estimate(estimator)).To(Equal(uint64(1266)))
+			}
+		})
+	})
+
+	Context("when the underlying estimate changes", func() {
+		It("should only change bucket when the estimate crosses the hysteresis band", func() {
+			underlying := &mockEstimator{gasPrice: 1200}
+			estimator := quantise.NewEstimator(underlying, nil, opts)
+			Expect(estimate(estimator)).To(Equal(uint64(1266)))
+
+			// The band extends up to half-way into the next bucket (1266 +
+			// (1424 - 1266) / 2 = 1345), and down to half-way into the previous
+			// bucket (1125 - (1125 - 1000) / 2 = 1062.5).
+			for _, gasPrice := range []uint64{1300, 1345, 1100, 1063} {
+				underlying.gasPrice = gasPrice
+				Expect(estimate(estimator)).To(Equal(uint64(1266)))
+			}
+
+			underlying.gasPrice = 1346
+			Expect(estimate(estimator)).To(Equal(uint64(1424)))
+
+			underlying.gasPrice = 1300
+			Expect(estimate(estimator)).To(Equal(uint64(1424)))
+
+			underlying.gasPrice = 1062
+			Expect(estimate(estimator)).To(Equal(uint64(1125)))
+		})
+
+		It("should not use a band when the hysteresis is zero", func() {
+			underlying := &mockEstimator{gasPrice: 1200}
+			estimator := quantise.NewEstimator(underlying, nil, opts.WithHysteresis(0))
+			Expect(estimate(estimator)).To(Equal(uint64(1266)))
+
+			underlying.gasPrice = 1267
+			Expect(estimate(estimator)).To(Equal(uint64(1424)))
+
+			underlying.gasPrice = 1266
+			Expect(estimate(estimator)).To(Equal(uint64(1266)))
+		})
+	})
+
+	Context("when the block height is known", func() {
+		It("should cache the gas price until the block height changes", func() {
+			underlying := &mockEstimator{gasPrice: 1000}
+			heights := &mockHeightFetcher{height: 1}
+			estimator := quantise.NewEstimator(underlying, heights, opts)
+			Expect(estimate(estimator)).To(Equal(uint64(1000)))
+
+			underlying.gasPrice = 2000
+			Expect(estimate(estimator)).To(Equal(uint64(1000)))
+			Expect(underlying.calls).To(Equal(1))
+
+			heights.height = 2
+			Expect(estimate(estimator)).To(Equal(uint64(2028)))
+			Expect(underlying.calls).To(Equal(2))
+		})
+	})
+
+	Context("when the options are invalid", func() {
+		It("should return an error", func() {
+			underlying := &mockEstimator{gasPrice: 1000}
+			for _, opts := range []quantise.Options{
+				opts.WithStep(1),
+				opts.WithHysteresis(1),
+				opts.WithHysteresis(-0.5),
+				opts.WithMin(pack.NewU256FromU64(pack.NewU64(0))),
+			} {
+				_, err := quantise.NewEstimator(underlying, nil, opts).EstimateGasPrice(context.Background())
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+})
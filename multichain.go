@@ -26,13 +26,16 @@ type (
 )
 
 type (
-	UTXOutpoint   = utxo.Outpoint
-	UTXOutput     = utxo.Output
-	UTXOInput     = utxo.Input
-	UTXORecipient = utxo.Recipient
-	UTXOTx        = utxo.Tx
-	UTXOTxBuilder = utxo.TxBuilder
-	UTXOClient    = utxo.Client
+	UTXOutpoint        = utxo.Outpoint
+	UTXOutput          = utxo.Output
+	UTXOInput          = utxo.Input
+	UTXORecipient      = utxo.Recipient
+	UTXOInputSignature = utxo.InputSignature
+	UTXOInputSigner    = utxo.InputSigner
+	UTXOSigHashType    = utxo.SigHashType
+	UTXOTx             = utxo.Tx
+	UTXOTxBuilder      = utxo.TxBuilder
+	UTXOClient         = utxo.Client
 )
 
 type (
@@ -58,7 +61,7 @@ const (
 	DGB  = Asset("DGB")  // DigiByte
 	DOGE = Asset("DOGE") // Dogecoin
 	ETH  = Asset("ETH")  // Ether
-	FIL  = Asset("FIL")  // Filecoin	
+	FIL  = Asset("FIL")  // Filecoin
 	FTM  = Asset("FTM")  // Fantom
 	SOL  = Asset("SOL")  // Solana
 	LUNA = Asset("LUNA") // Luna